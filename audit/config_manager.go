@@ -0,0 +1,344 @@
+package audit
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+const (
+	OperationAddPeer       = "add_peer"
+	OperationRemovePeer    = "remove_peer"
+	OperationReaddressPeer = "readdress_peer"
+	OperationDisablePeer   = "disable_peer"
+	OperationEnablePeer    = "enable_peer"
+)
+
+// Actor is a user on whose behalf configuration is changed.
+type Actor struct {
+	UserID   int64
+	Username string
+}
+
+// Sink receives every recorded audit entry in addition to the audit log.
+type Sink interface {
+	Record(entry Entry)
+}
+
+// BatchStore keeps changes staged by actors, so they are restored when the bot restarts.
+type BatchStore interface {
+	SaveBatches(batches map[int64][]wireguard.SavedOp)
+}
+
+// ConfigManager records every configuration change to the audit log. The wrapped ConfigManager
+// isn't exposed, so the configuration is changed only with methods which record the change.
+type ConfigManager struct {
+	config *wireguard.ConfigManager
+	Log    *Log
+	Sinks  []Sink
+	// Batches keeps staged changes, they are kept only in memory without it
+	Batches BatchStore
+
+	mu sync.Mutex
+	// Changes staged by actors, by user ID
+	batches map[int64]*wireguard.Batch
+}
+
+// NewConfigManager returns ConfigManager which records changes made with the given one to the log.
+func NewConfigManager(config *wireguard.ConfigManager, log *Log) *ConfigManager {
+	return &ConfigManager{config: config, Log: log}
+}
+
+func peerIP(peer *wireguard.Peer) string {
+	addr, _, err := net.ParseCIDR(peer.AllowedIPs)
+	if err != nil {
+		return peer.AllowedIPs
+	}
+	return addr.String()
+}
+
+func (c *ConfigManager) record(entry Entry, err error) {
+	entry.Time = time.Now()
+	entry.Result = ResultSuccess
+	if err != nil {
+		entry.Result = ResultFailure
+		var reloadErr *wireguard.ReloadError
+		if errors.As(err, &reloadErr) && reloadErr.RollbackErr == nil {
+			entry.Result = ResultRolledBack
+		}
+		entry.Error = err.Error()
+	}
+	log.Println(entry.String())
+	logErr := c.Log.Append(entry)
+	if logErr != nil {
+		log.Println(logErr)
+	}
+	for _, sink := range c.Sinks {
+		sink.Record(entry)
+	}
+}
+
+func (c *ConfigManager) AddPeer(actor Actor, publicKey string, name string) error {
+	return c.addPeer(actor, publicKey, name, func() error {
+		return c.config.AddPeer(publicKey, name)
+	})
+}
+
+// ApplyAddPeer applies the change previewed with PreviewAddPeer, so the peer gets the previewed address.
+func (c *ConfigManager) ApplyAddPeer(actor Actor, publicKey string, name string, change *wireguard.Change) error {
+	return c.addPeer(actor, publicKey, name, func() error {
+		return c.config.ApplyChange(change)
+	})
+}
+
+func (c *ConfigManager) addPeer(actor Actor, publicKey string, name string, add func() error) error {
+	entry := Entry{
+		UserID:    actor.UserID,
+		Username:  actor.Username,
+		Operation: OperationAddPeer,
+		PublicKey: publicKey,
+		Name:      name,
+	}
+	err := add()
+	if err == nil {
+		peer, err := c.config.GetPeer(publicKey)
+		if err == nil {
+			entry.IP = peerIP(peer)
+		}
+	}
+	c.record(entry, err)
+	return err
+}
+
+func (c *ConfigManager) RemovePeer(actor Actor, publicKey string) error {
+	entry := Entry{
+		UserID:    actor.UserID,
+		Username:  actor.Username,
+		Operation: OperationRemovePeer,
+		PublicKey: publicKey,
+	}
+	peer, err := c.config.GetPeer(publicKey)
+	if err == nil {
+		entry.Name = peer.Name
+		entry.IP = peerIP(peer)
+	}
+	err = c.config.RemovePeer(publicKey)
+	c.record(entry, err)
+	return err
+}
+
+// SetPeerEnabled disables the peer, or enables the disabled one.
+func (c *ConfigManager) SetPeerEnabled(actor Actor, publicKey string, enabled bool) error {
+	return c.setPeerEnabled(actor, publicKey, enabled, func() error {
+		if enabled {
+			return c.config.EnablePeer(publicKey)
+		}
+		return c.config.DisablePeer(publicKey)
+	})
+}
+
+// ApplySetPeerEnabled applies the change previewed with PreviewSetPeerEnabled.
+func (c *ConfigManager) ApplySetPeerEnabled(actor Actor, publicKey string, enabled bool, change *wireguard.Change) error {
+	return c.setPeerEnabled(actor, publicKey, enabled, func() error {
+		return c.config.ApplyChange(change)
+	})
+}
+
+func (c *ConfigManager) setPeerEnabled(actor Actor, publicKey string, enabled bool, apply func() error) error {
+	entry := Entry{
+		UserID:    actor.UserID,
+		Username:  actor.Username,
+		Operation: OperationDisablePeer,
+		PublicKey: publicKey,
+	}
+	if enabled {
+		entry.Operation = OperationEnablePeer
+	}
+	peer, err := c.config.GetPeer(publicKey)
+	if err == nil {
+		entry.Name = peer.Name
+		entry.IP = peerIP(peer)
+	}
+	err = apply()
+	c.record(entry, err)
+	return err
+}
+
+// peerAt returns the peer with the given index to describe it in an entry, or nil if there is no such peer.
+func (c *ConfigManager) peerAt(index int) *wireguard.Peer {
+	peers, err := c.config.ListPeers()
+	if err != nil || index < 0 || index >= len(peers) {
+		return nil
+	}
+	return &peers[index]
+}
+
+func (c *ConfigManager) ReaddressPeer(actor Actor, index int, publicKey string, allowedIP string) (net.IP, error) {
+	entry := Entry{
+		UserID:    actor.UserID,
+		Username:  actor.Username,
+		Operation: OperationReaddressPeer,
+		PublicKey: publicKey,
+	}
+	if peer := c.peerAt(index); peer != nil {
+		entry.Name = peer.Name
+	}
+	addr, err := c.config.ReaddressPeer(index, publicKey, allowedIP)
+	if err == nil {
+		entry.IP = addr.String()
+	}
+	c.record(entry, err)
+	return addr, err
+}
+
+func (c *ConfigManager) RemovePeerAt(actor Actor, index int, publicKey string) error {
+	entry := Entry{
+		UserID:    actor.UserID,
+		Username:  actor.Username,
+		Operation: OperationRemovePeer,
+		PublicKey: publicKey,
+	}
+	if peer := c.peerAt(index); peer != nil {
+		entry.Name = peer.Name
+		entry.IP = peerIP(peer)
+	}
+	err := c.config.RemovePeerAt(index, publicKey)
+	c.record(entry, err)
+	return err
+}
+
+// saveBatches passes changes staged by all actors to the batch store, c.mu must be held.
+func (c *ConfigManager) saveBatches() {
+	if c.Batches == nil {
+		return
+	}
+	saved := map[int64][]wireguard.SavedOp{}
+	for userID, batch := range c.batches {
+		saved[userID] = batch.Saved()
+	}
+	c.Batches.SaveBatches(saved)
+}
+
+// track makes the batch the one of the actor and saves it whenever a change is staged, c.mu must be held.
+func (c *ConfigManager) track(actor Actor, batch *wireguard.Batch) {
+	batch.Staged = func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.batches[actor.UserID] == batch {
+			c.saveBatches()
+		}
+	}
+	if c.batches == nil {
+		c.batches = map[int64]*wireguard.Batch{}
+	}
+	c.batches[actor.UserID] = batch
+	c.saveBatches()
+}
+
+// StartBatch starts staging changes of the actor. It returns false if the actor already has a batch.
+func (c *ConfigManager) StartBatch(actor Actor) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.batches[actor.UserID] != nil {
+		return false
+	}
+	c.track(actor, c.config.NewBatch())
+	return true
+}
+
+// RestoreBatches stages changes saved before the restart again. It returns IDs of users whose
+// batches don't apply anymore, e.g. because the configuration file was changed in the meantime.
+// Those batches are dropped.
+func (c *ConfigManager) RestoreBatches(saved map[int64][]wireguard.SavedOp) []int64 {
+	lost := []int64{}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for userID, ops := range saved {
+		batch, err := c.config.RestoreBatch(ops)
+		if err != nil {
+			log.Printf("Batch of user %d is lost: %v", userID, err)
+			lost = append(lost, userID)
+			continue
+		}
+		c.track(Actor{UserID: userID}, batch)
+	}
+	c.saveBatches()
+	return lost
+}
+
+// Batch returns changes staged by the actor, or nil if the actor doesn't stage changes.
+func (c *ConfigManager) Batch(actor Actor) *wireguard.Batch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.batches[actor.UserID]
+}
+
+// AbortBatch drops changes staged by the actor. It returns the dropped batch, or nil if there was none.
+func (c *ConfigManager) AbortBatch(actor Actor) *wireguard.Batch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	batch := c.batches[actor.UserID]
+	delete(c.batches, actor.UserID)
+	c.saveBatches()
+	return batch
+}
+
+var batchOperations = map[wireguard.OpKind]string{
+	wireguard.OpAddPeer:       OperationAddPeer,
+	wireguard.OpRemovePeer:    OperationRemovePeer,
+	wireguard.OpReaddressPeer: OperationReaddressPeer,
+	wireguard.OpDisablePeer:   OperationDisablePeer,
+	wireguard.OpEnablePeer:    OperationEnablePeer,
+}
+
+// Apply applies changes staged in the batch, recording every one of them. If the change returned by
+// the batch preview is given, exactly that change is applied. It returns the applied changes.
+func (c *ConfigManager) Apply(actor Actor, batch *wireguard.Batch, change *wireguard.Change) ([]wireguard.Op, error) {
+	var err error
+	if change != nil {
+		err = batch.Apply(change)
+	} else {
+		_, err = batch.Commit()
+	}
+	ops := batch.Ops()
+	for _, op := range ops {
+		c.record(Entry{
+			UserID:    actor.UserID,
+			Username:  actor.Username,
+			Operation: batchOperations[op.Kind],
+			PublicKey: op.PublicKey,
+			Name:      op.Peer.Name,
+			IP:        peerIP(&op.Peer),
+		}, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// CommitBatch applies changes staged by the actor, recording every one of them. The batch is
+// dropped once it is applied, and kept if it fails, so it could be reviewed or aborted.
+// The change returned by the batch preview is optional, see Apply. It returns the applied changes.
+func (c *ConfigManager) CommitBatch(actor Actor, change *wireguard.Change) ([]wireguard.Op, error) {
+	batch := c.Batch(actor)
+	if batch == nil {
+		return nil, errors.New("there is no batch to commit")
+	}
+	ops, err := c.Apply(actor, batch, change)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.batches[actor.UserID] == batch {
+		delete(c.batches, actor.UserID)
+		c.saveBatches()
+	}
+	return ops, nil
+}
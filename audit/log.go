@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ResultSuccess    = "success"
+	ResultFailure    = "failure"
+	ResultRolledBack = "rolled_back"
+)
+
+type Entry struct {
+	Time      time.Time `json:"time"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Operation string    `json:"operation"`
+	PublicKey string    `json:"public_key,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func (e *Entry) String() string {
+	str := fmt.Sprintf("%s %s %s by %s (%d)",
+		e.Time.Format("2006-01-02 15:04:05"),
+		e.Operation,
+		e.Result,
+		e.Username,
+		e.UserID,
+	)
+	if e.PublicKey != "" {
+		str += " key=" + e.PublicKey
+	}
+	if e.Name != "" {
+		str += " name='" + e.Name + "'"
+	}
+	if e.IP != "" {
+		str += " ip=" + e.IP
+	}
+	if e.Error != "" {
+		str += " error=" + e.Error
+	}
+	return str
+}
+
+// Filter selects log entries. Zero values match any entry.
+type Filter struct {
+	// User ID or username (without leading @)
+	User      string
+	Operation string
+	// Public key prefix
+	PublicKey string
+	Result    string
+	// Maximum amount of entries to return, latest entries are kept
+	Limit int
+}
+
+// ParseFilter parses filter from arguments like "op=add_peer user=123 10",
+// plain number sets the limit.
+func ParseFilter(args []string) (Filter, error) {
+	filter := Filter{}
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found {
+			limit, err := strconv.Atoi(arg)
+			if err != nil || limit < 1 {
+				return filter, fmt.Errorf("invalid limit %s", arg)
+			}
+			filter.Limit = limit
+			continue
+		}
+		switch key {
+		case "user":
+			filter.User = strings.TrimPrefix(value, "@")
+		case "op":
+			filter.Operation = value
+		case "key":
+			filter.PublicKey = value
+		case "result":
+			filter.Result = value
+		default:
+			return filter, fmt.Errorf("unknown filter %s", key)
+		}
+	}
+	return filter, nil
+}
+
+func (f *Filter) Match(e *Entry) bool {
+	if f.User != "" && f.User != strconv.FormatInt(e.UserID, 10) && f.User != e.Username {
+		return false
+	}
+	if f.Operation != "" && f.Operation != e.Operation {
+		return false
+	}
+	if f.PublicKey != "" && !strings.HasPrefix(e.PublicKey, f.PublicKey) {
+		return false
+	}
+	if f.Result != "" && f.Result != e.Result {
+		return false
+	}
+	return true
+}
+
+// Log is an append-only JSONL audit log. Log with empty FilePath discards all entries.
+type Log struct {
+	FilePath string
+	mu       sync.Mutex
+}
+
+func (l *Log) Append(entry Entry) error {
+	if l.FilePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.OpenFile(l.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %w", err)
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+	return file.Sync()
+}
+
+// Tail returns latest entries matching the filter, oldest first.
+func (l *Log) Tail(filter Filter) ([]Entry, error) {
+	if l.FilePath == "" {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.Open(l.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log: %w", err)
+	}
+	defer file.Close()
+
+	result := []Entry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := Entry{}
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing audit log: %w", err)
+		}
+		if !filter.Match(&entry) {
+			continue
+		}
+		result = append(result, entry)
+		if filter.Limit > 0 && len(result) > filter.Limit {
+			result = result[1:]
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit log: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,259 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `[Interface]
+Address    = 192.168.3.1/24
+ListenPort = 11111
+PrivateKey = sLsJoF6gLXYWfRcpRkA7ugzvkYX15Lpvif5oBeZeaHA=
+`
+
+type failingProcessManager struct{}
+
+func (pm *failingProcessManager) ReloadConfig() error {
+	return errors.New("reload failed")
+}
+
+func TestLog(t *testing.T) {
+	auditLog := &Log{FilePath: filepath.Join(t.TempDir(), "audit.jsonl")}
+
+	entries, err := auditLog.Tail(Filter{})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	require.NoError(t, auditLog.Append(Entry{UserID: 1, Username: "alice", Operation: OperationAddPeer, PublicKey: "aaa", Result: ResultSuccess}))
+	require.NoError(t, auditLog.Append(Entry{UserID: 2, Username: "bob", Operation: OperationAddPeer, PublicKey: "bbb", Result: ResultFailure}))
+	require.NoError(t, auditLog.Append(Entry{UserID: 1, Username: "alice", Operation: OperationRemovePeer, PublicKey: "aaa", Result: ResultSuccess}))
+
+	t.Run("all entries", func(t *testing.T) {
+		entries, err := auditLog.Tail(Filter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+	})
+
+	t.Run("limit keeps latest entries", func(t *testing.T) {
+		entries, err := auditLog.Tail(Filter{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, "bbb", entries[0].PublicKey)
+		require.Equal(t, OperationRemovePeer, entries[1].Operation)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		filter, err := ParseFilter([]string{"user=@alice", "op=add_peer"})
+		require.NoError(t, err)
+		entries, err := auditLog.Tail(filter)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "aaa", entries[0].PublicKey)
+
+		filter, err = ParseFilter([]string{"result=failure", "user=2"})
+		require.NoError(t, err)
+		entries, err = auditLog.Tail(filter)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "bob", entries[0].Username)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		_, err := ParseFilter([]string{"foo=bar"})
+		require.Error(t, err)
+		_, err = ParseFilter([]string{"-1"})
+		require.Error(t, err)
+	})
+}
+
+func TestConfigManager(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte(testConfig), 0600))
+	auditLog := &Log{FilePath: filepath.Join(t.TempDir(), "audit.jsonl")}
+	configManager := &ConfigManager{
+		config: &wireguard.ConfigManager{
+			ConfigFilePath: configFile,
+			ProcessManager: &wireguard.ProcessManagerStub{},
+		},
+		Log: auditLog,
+	}
+	actor := Actor{UserID: 1, Username: "alice"}
+
+	require.NoError(t, configManager.AddPeer(actor, "yyy", "Test Peer"))
+	require.Error(t, configManager.AddPeer(actor, "yyy", "Test Peer"))
+	require.NoError(t, configManager.RemovePeer(actor, "yyy"))
+
+	configManager.config.ProcessManager = &failingProcessManager{}
+	require.Error(t, configManager.AddPeer(actor, "zzz", "Other Peer"))
+
+	entries, err := auditLog.Tail(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	require.Equal(t, OperationAddPeer, entries[0].Operation)
+	require.Equal(t, ResultSuccess, entries[0].Result)
+	require.Equal(t, "192.168.3.2", entries[0].IP)
+	require.Equal(t, "alice", entries[0].Username)
+
+	require.Equal(t, ResultFailure, entries[1].Result)
+	require.NotEmpty(t, entries[1].Error)
+
+	require.Equal(t, OperationRemovePeer, entries[2].Operation)
+	require.Equal(t, "Test Peer", entries[2].Name)
+	require.Equal(t, "192.168.3.2", entries[2].IP)
+
+	require.Equal(t, ResultRolledBack, entries[3].Result)
+
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Empty(t, peers)
+}
+
+func TestConfigManagerFixes(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte(testConfig+`
+# Alice
+[Peer]
+PublicKey  = yyy
+AllowedIPs = 10.0.0.2/32
+
+# Alice copy
+[Peer]
+PublicKey  = yyy
+AllowedIPs = 192.168.3.5/32
+`), 0600))
+	auditLog := &Log{FilePath: filepath.Join(t.TempDir(), "audit.jsonl")}
+	configManager := &ConfigManager{
+		config: &wireguard.ConfigManager{
+			ConfigFilePath: configFile,
+			ProcessManager: &wireguard.ProcessManagerStub{},
+		},
+		Log: auditLog,
+	}
+	actor := Actor{UserID: 1, Username: "alice"}
+
+	_, err := configManager.ReaddressPeer(actor, 0, "yyy", "10.0.0.2/32")
+	require.NoError(t, err)
+	require.NoError(t, configManager.RemovePeerAt(actor, 1, "yyy"))
+
+	entries, err := auditLog.Tail(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, OperationReaddressPeer, entries[0].Operation)
+	require.Equal(t, "Alice", entries[0].Name)
+	require.Equal(t, "192.168.3.2", entries[0].IP)
+	require.Equal(t, OperationRemovePeer, entries[1].Operation)
+	require.Equal(t, "Alice copy", entries[1].Name)
+	require.Equal(t, "192.168.3.5", entries[1].IP)
+}
+
+func TestConfigManagerBatch(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte(testConfig), 0600))
+	auditLog := &Log{FilePath: filepath.Join(t.TempDir(), "audit.jsonl")}
+	configManager := &ConfigManager{
+		config: &wireguard.ConfigManager{
+			ConfigFilePath: configFile,
+			ProcessManager: &failingProcessManager{},
+		},
+		Log: auditLog,
+	}
+	alice := Actor{UserID: 1, Username: "alice"}
+	bob := Actor{UserID: 2, Username: "bob"}
+
+	require.Nil(t, configManager.Batch(alice))
+	require.True(t, configManager.StartBatch(alice))
+	require.False(t, configManager.StartBatch(alice))
+	require.Nil(t, configManager.Batch(bob))
+	require.NoError(t, configManager.Batch(alice).AddPeer("yyy", "Test Peer"))
+	require.NoError(t, configManager.Batch(alice).AddPeer("zzz", "Other Peer"))
+
+	// Failed batch is kept, so it could be reviewed or aborted
+	_, err := configManager.CommitBatch(alice, nil)
+	require.Error(t, err)
+	require.NotNil(t, configManager.Batch(alice))
+
+	configManager.config.ProcessManager = &wireguard.ProcessManagerStub{}
+	ops, err := configManager.CommitBatch(alice, nil)
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	require.Nil(t, configManager.Batch(alice))
+	_, err = configManager.CommitBatch(alice, nil)
+	require.Error(t, err)
+
+	entries, err := auditLog.Tail(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	require.Equal(t, ResultRolledBack, entries[0].Result)
+	require.Equal(t, ResultRolledBack, entries[1].Result)
+	require.Equal(t, OperationAddPeer, entries[2].Operation)
+	require.Equal(t, ResultSuccess, entries[2].Result)
+	require.Equal(t, "Test Peer", entries[2].Name)
+	require.Equal(t, "192.168.3.2", entries[2].IP)
+	require.Equal(t, "192.168.3.3", entries[3].IP)
+
+	require.True(t, configManager.StartBatch(bob))
+	require.NoError(t, configManager.Batch(bob).RemovePeer("yyy"))
+	require.Equal(t, 1, configManager.AbortBatch(bob).Len())
+	require.Nil(t, configManager.AbortBatch(bob))
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Len(t, peers, 2)
+}
+
+// memoryBatchStore keeps saved batches in memory
+type memoryBatchStore struct {
+	batches map[int64][]wireguard.SavedOp
+}
+
+func (s *memoryBatchStore) SaveBatches(batches map[int64][]wireguard.SavedOp) {
+	s.batches = batches
+}
+
+func TestConfigManagerRestoreBatches(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte(testConfig), 0600))
+	store := &memoryBatchStore{}
+	newConfigManager := func() *ConfigManager {
+		return &ConfigManager{
+			config: &wireguard.ConfigManager{
+				ConfigFilePath: configFile,
+				ProcessManager: &wireguard.ProcessManagerStub{},
+			},
+			Log:     &Log{},
+			Batches: store,
+		}
+	}
+	alice := Actor{UserID: 1, Username: "alice"}
+	bob := Actor{UserID: 2, Username: "bob"}
+
+	configManager := newConfigManager()
+	require.True(t, configManager.StartBatch(alice))
+	require.Equal(t, map[int64][]wireguard.SavedOp{1: {}}, store.batches)
+	require.NoError(t, configManager.Batch(alice).AddPeer("yyy", "Test Peer"))
+	require.True(t, configManager.StartBatch(bob))
+	require.NoError(t, configManager.Batch(bob).AddPeer("zzz", "Other Peer"))
+	require.Len(t, store.batches[1], 1)
+	require.Len(t, store.batches[2], 1)
+
+	// Batches are staged again after restart
+	restarted := newConfigManager()
+	require.Empty(t, restarted.RestoreBatches(store.batches))
+	require.Equal(t, 1, restarted.Batch(alice).Len())
+	require.NoError(t, restarted.Batch(alice).RemovePeer("yyy"))
+	require.Len(t, store.batches[1], 2)
+	_, err := restarted.CommitBatch(bob, nil)
+	require.NoError(t, err)
+	require.NotContains(t, store.batches, int64(2))
+
+	// The address bob's peer took is staged for alice's one, so her batch doesn't apply anymore
+	restarted = newConfigManager()
+	require.Equal(t, []int64{1}, restarted.RestoreBatches(store.batches))
+	require.Nil(t, restarted.Batch(alice))
+	require.Empty(t, store.batches)
+}
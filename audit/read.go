@@ -0,0 +1,68 @@
+package audit
+
+import "github.com/rem11/simple-wg-telegram-bot/wireguard"
+
+// Methods below don't change the configuration, they are passed through to the wrapped ConfigManager.
+
+// SetEndpoint changes the hostname and DNS put into client configs, see wireguard.ConfigManager.SetEndpoint.
+func (c *ConfigManager) SetEndpoint(hostname string, dns string) {
+	c.config.SetEndpoint(hostname, dns)
+}
+
+func (c *ConfigManager) ListPeers() ([]wireguard.Peer, error) {
+	return c.config.ListPeers()
+}
+
+func (c *ConfigManager) FindPeers(query string) ([]wireguard.Peer, error) {
+	return c.config.FindPeers(query)
+}
+
+func (c *ConfigManager) GetPeer(publicKey string) (*wireguard.Peer, error) {
+	return c.config.GetPeer(publicKey)
+}
+
+func (c *ConfigManager) GetClientConfig(publicKey string) (*wireguard.ClientConfig, string, error) {
+	return c.config.GetClientConfig(publicKey)
+}
+
+func (c *ConfigManager) AddressPool() (*wireguard.AddressPool, error) {
+	return c.config.AddressPool()
+}
+
+func (c *ConfigManager) Check() ([]wireguard.Problem, error) {
+	return c.config.Check()
+}
+
+func (c *ConfigManager) Export(format wireguard.ExportFormat) ([]byte, error) {
+	return c.config.Export(format)
+}
+
+func (c *ConfigManager) ChangeOutdated(change *wireguard.Change) bool {
+	return c.config.ChangeOutdated(change)
+}
+
+func (c *ConfigManager) PreviewAddPeer(publicKey string, name string) (*wireguard.Change, error) {
+	return c.config.PreviewAddPeer(publicKey, name)
+}
+
+func (c *ConfigManager) PreviewRemovePeer(publicKey string) (*wireguard.Change, error) {
+	return c.config.PreviewRemovePeer(publicKey)
+}
+
+func (c *ConfigManager) PreviewRemovePeerAt(index int, publicKey string) (*wireguard.Change, error) {
+	return c.config.PreviewRemovePeerAt(index, publicKey)
+}
+
+func (c *ConfigManager) PreviewReaddressPeer(index int, publicKey string, allowedIP string) (*wireguard.Change, error) {
+	return c.config.PreviewReaddressPeer(index, publicKey, allowedIP)
+}
+
+func (c *ConfigManager) PreviewSetPeerEnabled(publicKey string, enabled bool) (*wireguard.Change, error) {
+	return c.config.PreviewSetPeerEnabled(publicKey, enabled)
+}
+
+// ScratchBatch returns a batch which isn't staged by anyone, e.g. to check changes against the
+// configuration and preview them together. Its changes are applied with Apply, so they are recorded.
+func (c *ConfigManager) ScratchBatch() *wireguard.Batch {
+	return c.config.NewBatch()
+}
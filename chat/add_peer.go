@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"errors"
+	"log"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+func NewAddPeerCommand(configManager *audit.ConfigManager) *Wizard {
+	previewed := &previewedChange{}
+	return &Wizard{
+		Name: "add_peer",
+		Steps: []WizardStep{
+			&TextStep{Name: "publicKey", Text: i18n.EnterPublicKey, Parse: ParsePublicKey},
+			&TextStep{Name: "name", Text: i18n.EnterPeerName, Parse: ParsePeerName},
+		},
+		Confirmation: func(conv Conversation, values map[string]string) string {
+			return tr(conv, i18n.AddConfirmation, values["publicKey"], values["name"])
+		},
+		Preview: func(conv Conversation, values map[string]string) (string, error) {
+			if configManager.Batch(Actor(conv)) != nil {
+				// Staged changes are reviewed together
+				return "", nil
+			}
+			return previewed.keep(configManager.PreviewAddPeer(values["publicKey"], values["name"]))
+		},
+		Outdated: func(conv Conversation, values map[string]string) bool {
+			return configManager.Batch(Actor(conv)) == nil && previewed.outdated(conv, configManager)
+		},
+		Finish: func(conv Conversation, values map[string]string) {
+			if batch := configManager.Batch(Actor(conv)); batch != nil {
+				stageChange(conv, batch, batch.AddPeer(values["publicKey"], values["name"]))
+				return
+			}
+			var err error
+			if previewed.change != nil {
+				err = configManager.ApplyAddPeer(Actor(conv), values["publicKey"], values["name"], previewed.change)
+			} else {
+				err = configManager.AddPeer(Actor(conv), values["publicKey"], values["name"])
+			}
+			if errors.Is(err, wireguard.ErrChangeOutdated) {
+				conv.Send(tr(conv, i18n.ChangeOutdated), RemoveKeyboard)
+				return
+			}
+			if errors.Is(err, wireguard.ErrInconsistentConfig) {
+				log.Println(err)
+				conv.Send(tr(conv, i18n.AddPeerConfigError), RemoveKeyboard)
+				return
+			}
+			if err != nil {
+				log.Println(err)
+				conv.Send(tr(conv, i18n.AddPeerError), RemoveKeyboard)
+				return
+			}
+			conv.Send(tr(conv, i18n.PeerAdded), RemoveKeyboard)
+			SendClientConfig(conv, configManager, values["publicKey"])
+		},
+	}
+}
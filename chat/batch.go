@@ -0,0 +1,154 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+var opLabels = map[wireguard.OpKind]i18n.Key{
+	wireguard.OpAddPeer:       i18n.StagedAddPeer,
+	wireguard.OpRemovePeer:    i18n.StagedRemovePeer,
+	wireguard.OpReaddressPeer: i18n.StagedReaddressPeer,
+	wireguard.OpDisablePeer:   i18n.StagedDisablePeer,
+	wireguard.OpEnablePeer:    i18n.StagedEnablePeer,
+}
+
+// stageChange reports the result of staging a change in the batch.
+func stageChange(conv Conversation, batch *wireguard.Batch, err error) {
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.StageError), RemoveKeyboard)
+		return
+	}
+	conv.Send(tr(conv, i18n.ChangeStaged, batch.Len()), RemoveKeyboard)
+}
+
+// sendBatch lists changes staged in the batch along with their combined diff.
+func sendBatch(conv Conversation, batch *wireguard.Batch) {
+	preview, err := changeDiff(batch.Preview())
+	if sendOps(conv, batch) {
+		sendPreview(conv, preview, err)
+	}
+}
+
+// sendOps lists changes staged in the batch. It returns false if there are none.
+func sendOps(conv Conversation, batch *wireguard.Batch) bool {
+	ops := batch.Ops()
+	if len(ops) == 0 {
+		conv.Send(tr(conv, i18n.NoStagedChanges), nil)
+		return false
+	}
+	builder := strings.Builder{}
+	builder.WriteString(tr(conv, i18n.StagedChanges) + "\n")
+	for i, op := range ops {
+		label := op.PublicKey
+		if op.Peer.PublicKey != "" {
+			label = peerLabel(&op.Peer)
+		}
+		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, tr(conv, opLabels[op.Kind], label)))
+	}
+	conv.Send(builder.String(), nil)
+	return true
+}
+
+// BatchCommand starts staging changes, so they are applied together with /commit.
+// If changes are staged already, it shows them. It finishes right away.
+type BatchCommand struct {
+	*audit.ConfigManager
+}
+
+func (cmd *BatchCommand) Start(conv Conversation) bool {
+	if cmd.StartBatch(Actor(conv)) {
+		conv.Send(tr(conv, i18n.BatchStarted), nil)
+		return true
+	}
+	sendBatch(conv, cmd.Batch(Actor(conv)))
+	return true
+}
+
+func (cmd *BatchCommand) HandleInput(conv Conversation) bool {
+	return true
+}
+
+// AbortCommand drops staged changes. It finishes right away.
+type AbortCommand struct {
+	*audit.ConfigManager
+}
+
+func (cmd *AbortCommand) Start(conv Conversation) bool {
+	batch := cmd.AbortBatch(Actor(conv))
+	if batch == nil {
+		conv.Send(tr(conv, i18n.NoBatch), nil)
+		return true
+	}
+	conv.Send(tr(conv, i18n.BatchAborted, batch.Len()), nil)
+	return true
+}
+
+func (cmd *AbortCommand) HandleInput(conv Conversation) bool {
+	return true
+}
+
+// CommitCommand shows staged changes and applies them once confirmed. Exactly the previewed
+// change is applied, it is previewed again if the configuration file was changed since.
+type CommitCommand struct {
+	*audit.ConfigManager
+	confirm   *Wizard
+	previewed previewedChange
+}
+
+func (cmd *CommitCommand) Start(conv Conversation) bool {
+	batch := cmd.Batch(Actor(conv))
+	if batch == nil {
+		conv.Send(tr(conv, i18n.NoBatch), nil)
+		return true
+	}
+	if batch.Len() == 0 {
+		cmd.AbortBatch(Actor(conv))
+		conv.Send(tr(conv, i18n.BatchEmpty), nil)
+		return true
+	}
+	sendOps(conv, batch)
+	cmd.confirm = &Wizard{
+		Confirmation: func(conv Conversation, values map[string]string) string {
+			return tr(conv, i18n.CommitConfirmation, batch.Len())
+		},
+		Preview: func(conv Conversation, values map[string]string) (string, error) {
+			return cmd.previewed.keep(batch.Preview())
+		},
+		Outdated: func(conv Conversation, values map[string]string) bool {
+			return cmd.previewed.outdated(conv, cmd.ConfigManager)
+		},
+		Finish: cmd.commit,
+	}
+	return cmd.confirm.Start(conv)
+}
+
+func (cmd *CommitCommand) HandleInput(conv Conversation) bool {
+	return cmd.confirm.HandleInput(conv)
+}
+
+func (cmd *CommitCommand) commit(conv Conversation, values map[string]string) {
+	ops, err := cmd.CommitBatch(Actor(conv), cmd.previewed.change)
+	if errors.Is(err, wireguard.ErrChangeOutdated) {
+		conv.Send(tr(conv, i18n.ChangeOutdated), RemoveKeyboard)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.CommitError), RemoveKeyboard)
+		return
+	}
+	conv.Send(tr(conv, i18n.BatchCommitted, len(ops)), RemoveKeyboard)
+	for _, op := range ops {
+		if op.Kind == wireguard.OpAddPeer {
+			SendClientConfig(conv, cmd.ConfigManager, op.PublicKey)
+		}
+	}
+}
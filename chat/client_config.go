@@ -0,0 +1,58 @@
+package chat
+
+import (
+	"log"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+func NewClientConfigCommand(configManager *audit.ConfigManager) *Wizard {
+	return &Wizard{
+		Name: "client_config",
+		Steps: []WizardStep{
+			&PeerStep{ConfigManager: configManager, Name: "peer", Text: i18n.SelectPeerConfig},
+		},
+		Finish: func(conv Conversation, values map[string]string) {
+			SendClientConfig(conv, configManager, values["peer"])
+		},
+	}
+}
+
+// FormatClientConfig returns message with the client config in the given language.
+func FormatClientConfig(lang string, cfg *wireguard.ClientConfig, cfgStr string) *render.Message {
+	msg := &render.Message{}
+	if cfg.Name != "" {
+		msg.Bold(cfg.Name).Newline().Newline()
+	}
+	field := func(label i18n.Key, value string) {
+		msg.Text(i18n.Translate(lang, label) + ": ").Code(value).Newline()
+	}
+	msg.Bold(i18n.Translate(lang, i18n.ConfigInterface)).Newline()
+	field(i18n.ConfigAddress, cfg.Interface.Address)
+	field(i18n.ConfigDNS, cfg.Interface.DNS)
+	msg.Newline()
+	msg.Bold(i18n.Translate(lang, i18n.ConfigPeer)).Newline()
+	field(i18n.ConfigPublicKey, cfg.Peer.PublicKey)
+	field(i18n.ConfigAllowedIPs, cfg.Peer.AllowedIPs)
+	field(i18n.ConfigEndpoint, cfg.Peer.Endpoint)
+	msg.Newline()
+	msg.Bold(i18n.Translate(lang, i18n.ConfigTemplate)).Newline()
+	return msg.Pre(cfgStr)
+}
+
+// SendClientConfig sends client config of the peer with the given public key.
+func SendClientConfig(conv Conversation, configManager *audit.ConfigManager, publicKey string) {
+	cfg, cfgStr, err := configManager.GetClientConfig(publicKey)
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.ClientConfigErr), nil)
+		return
+	}
+	err = conv.SendFormatted(FormatClientConfig(conv.Language(), cfg, cfgStr))
+	if err != nil {
+		log.Println(err)
+	}
+}
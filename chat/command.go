@@ -0,0 +1,27 @@
+package chat
+
+// Command is a conversation with the user, which could take several inputs.
+type Command interface {
+	// Start starts the command. It returns true if the command has finished.
+	Start(Conversation) bool
+	// HandleInput processes user input. It returns true if the command has finished.
+	HandleInput(Conversation) bool
+}
+
+// CommandState is a serializable state of a command.
+type CommandState struct {
+	// Name of the command factory registered in CommandController
+	Command    string
+	Values     map[string]string
+	Preset     []string
+	Step       int
+	Confirming bool
+}
+
+// PersistentCommand is a Command which can be resumed after bot restart.
+type PersistentCommand interface {
+	Command
+	State() *CommandState
+	// Resume restores command state and asks user for the input again
+	Resume(conv Conversation, state *CommandState) bool
+}
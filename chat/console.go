@@ -0,0 +1,195 @@
+package chat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+)
+
+// buttonPrefix starts the number of a button which is pressed, e.g. "#2".
+const buttonPrefix = "#"
+
+// Console is a line-based frontend, which reads user input from In and writes messages to Out.
+// Every line is a message, a line starting with the name of a command starts the command.
+// Answers offered by commands are listed below the message, and buttons are numbered, so a
+// button is pressed by entering its number with "#" prefix. Console is run by a single user.
+type Console struct {
+	In   io.Reader
+	Out  io.Writer
+	User User
+	// Language messages are shown in, the default one is used if it is not set
+	Lang string
+	// Commands which can be started, by name with "/" prefix. Factory receives the rest of the line.
+	Commands map[string]func(payload string) Command
+
+	text    string
+	pressed bool
+	data    string
+	// Buttons of the latest message which has them, in the order they are numbered
+	buttons []Button
+}
+
+// Run reads and handles input until In is exhausted.
+func (c *Console) Run() error {
+	var active Command
+	scanner := bufio.NewScanner(c.In)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		c.read(line)
+
+		name, payload := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			name, payload = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		if factory, ok := c.Commands[name]; ok {
+			if active != nil {
+				c.println(tr(c, i18n.PreviousCommandCancelled))
+			}
+			active = factory(payload)
+			if active.Start(c) {
+				active = nil
+			}
+			continue
+		}
+		switch {
+		case name == "/cancel" && active == nil:
+			c.println(tr(c, i18n.NoActiveCommand))
+		case name == "/cancel":
+			active = nil
+			c.println(tr(c, i18n.CommandCancelled))
+		case active == nil:
+			c.println(tr(c, i18n.UnknownCommand, strings.Join(c.commandNames(), ", ")))
+		case active.HandleInput(c):
+			active = nil
+		}
+	}
+	return scanner.Err()
+}
+
+// read makes the line the current input.
+func (c *Console) read(line string) {
+	c.text, c.pressed, c.data = line, false, ""
+	if !strings.HasPrefix(line, buttonPrefix) {
+		return
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(line, buttonPrefix))
+	if err != nil {
+		return
+	}
+	c.text, c.pressed = "", true
+	// Pressing a button which doesn't exist is the same as pressing an outdated one
+	if number >= 1 && number <= len(c.buttons) {
+		c.data = c.buttons[number-1].Data
+	}
+}
+
+func (c *Console) commandNames() []string {
+	names := []string{"/cancel"}
+	for name := range c.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Console) println(text string) {
+	fmt.Fprintln(c.Out, text)
+}
+
+// showButtons numbers buttons and lists them a row per line.
+func (c *Console) showButtons(buttons [][]Button) {
+	c.buttons = nil
+	for _, row := range buttons {
+		labels := []string{}
+		for _, button := range row {
+			c.buttons = append(c.buttons, button)
+			labels = append(labels, fmt.Sprintf("[%s%d] %s", buttonPrefix, len(c.buttons), button.Text))
+		}
+		c.println("  " + strings.Join(labels, "  "))
+	}
+}
+
+func (c *Console) Sender() User {
+	return c.User
+}
+
+func (c *Console) Language() string {
+	if c.Lang == "" {
+		return i18n.DefaultLanguage
+	}
+	return c.Lang
+}
+
+func (c *Console) Text() string {
+	return c.text
+}
+
+func (c *Console) Pressed() bool {
+	return c.pressed
+}
+
+func (c *Console) Data() string {
+	return c.data
+}
+
+func (c *Console) Send(text string, keyboard *Keyboard) error {
+	c.println(text)
+	if keyboard == nil {
+		return nil
+	}
+	if len(keyboard.Buttons) > 0 {
+		c.showButtons(keyboard.Buttons)
+	}
+	if len(keyboard.Answers) > 0 {
+		c.println("  (" + strings.Join(keyboard.Answers, " | ") + ")")
+	}
+	return nil
+}
+
+func (c *Console) SendFormatted(msg *render.Message) error {
+	for _, text := range msg.Render(render.Plain, render.MaxLength) {
+		c.println(text)
+	}
+	return nil
+}
+
+func (c *Console) SendDocument(doc *Document) error {
+	if doc.Caption != "" {
+		c.println(doc.Caption)
+	}
+	if !utf8.Valid(doc.Content) {
+		c.println(fmt.Sprintf("[%s, %d bytes]", doc.FileName, len(doc.Content)))
+		return nil
+	}
+	c.println(fmt.Sprintf("[%s]", doc.FileName))
+	c.println(strings.TrimSuffix(string(doc.Content), "\n"))
+	return nil
+}
+
+func (c *Console) Edit(text string, buttons [][]Button) error {
+	c.println(text)
+	c.showButtons(buttons)
+	return nil
+}
+
+func (c *Console) Answer(text string) error {
+	if text != "" {
+		c.println(text)
+	}
+	return nil
+}
+
+func (c *Console) Notify(userID int64, text string) error {
+	c.println(fmt.Sprintf("[to %d] %s", userID, text))
+	return nil
+}
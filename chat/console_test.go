@@ -0,0 +1,456 @@
+package chat
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"github.com/stretchr/testify/require"
+)
+
+const consoleServerConfig = `[Interface]
+Address    = 192.168.3.1/24
+ListenPort = 11111
+PrivateKey = sLsJoF6gLXYWfRcpRkA7ugzvkYX15Lpvif5oBeZeaHA=
+`
+
+const consolePublicKey = "Dc6HJYJHhm//iEeQDnXDPPtQ1u9slnkDaflP0ar4ISE="
+
+// newTestConfig returns ConfigManager of a new server configuration, so tests could change its settings.
+func newTestConfig(t *testing.T) *wireguard.ConfigManager {
+	configPath := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(configPath, []byte(consoleServerConfig), 0600))
+	return &wireguard.ConfigManager{
+		ConfigFilePath: configPath,
+		Hostname:       "example.com",
+		DNS:            "8.8.8.8",
+		ProcessManager: &wireguard.ProcessManagerStub{},
+	}
+}
+
+// newAuditedConfigManager returns audited ConfigManager of the configuration.
+func newAuditedConfigManager(t *testing.T, config *wireguard.ConfigManager) *audit.ConfigManager {
+	return audit.NewConfigManager(config, &audit.Log{FilePath: filepath.Join(t.TempDir(), "audit.jsonl")})
+}
+
+func newTestConfigManager(t *testing.T) *audit.ConfigManager {
+	return newAuditedConfigManager(t, newTestConfig(t))
+}
+
+// runConsole runs console session with the given input lines and returns its output.
+func runConsole(t *testing.T, configManager *audit.ConfigManager, lines ...string) string {
+	out := &bytes.Buffer{}
+	console := &Console{
+		In:   strings.NewReader(strings.Join(lines, "\n")),
+		Out:  out,
+		User: User{ID: 1, Username: "admin"},
+		Commands: map[string]func(payload string) Command{
+			"/add_peer": func(string) Command {
+				return NewAddPeerCommand(configManager)
+			},
+			"/client_config": func(string) Command {
+				return NewClientConfigCommand(configManager)
+			},
+			"/find": func(query string) Command {
+				return &FindCommand{ConfigManager: configManager, Query: query}
+			},
+			"/ipam": func(string) Command {
+				return &IPAMCommand{ConfigManager: configManager}
+			},
+			"/export": func(format string) Command {
+				return &ExportCommand{ConfigManager: configManager, Format: format}
+			},
+			"/doctor": func(string) Command {
+				return &DoctorCommand{ConfigManager: configManager}
+			},
+			"/batch": func(string) Command {
+				return &BatchCommand{ConfigManager: configManager}
+			},
+			"/commit": func(string) Command {
+				return &CommitCommand{ConfigManager: configManager}
+			},
+			"/abort": func(string) Command {
+				return &AbortCommand{ConfigManager: configManager}
+			},
+			"/import": func(path string) Command {
+				return &ImportCommand{ConfigManager: configManager, Open: func() (io.ReadCloser, error) {
+					return os.Open(path)
+				}}
+			},
+		},
+	}
+	require.NoError(t, console.Run())
+	return out.String()
+}
+
+func TestConsole(t *testing.T) {
+	config := newTestConfig(t)
+	configManager := newAuditedConfigManager(t, config)
+
+	out := runConsole(t, configManager,
+		"/add_peer",
+		consolePublicKey,
+		"Bob laptop",
+		"Yes",
+	)
+	require.Contains(t, out, "Enter public key for new peer\n")
+	require.Contains(t, out, "  (« Back)\n")
+	require.Contains(t, out, "  (Yes | No | « Back)\n")
+	require.Contains(t, out, "Peer was added successfully! Config below.\nBob laptop\n")
+	require.Contains(t, out, "Address: 192.168.3.2/24\n")
+
+	entries, err := configManager.Log.Tail(audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "admin", entries[0].Username)
+
+	out = runConsole(t, configManager,
+		"/client_config",
+		"Bob laptop",
+		"#1",
+	)
+	require.Contains(t, out, "  [#1] Bob laptop - 192.168.3.2/32\n")
+	require.Contains(t, out, "Please select a peer using buttons above\n")
+	require.Contains(t, out, "Selected: Bob laptop - 192.168.3.2/32\n")
+	require.Contains(t, out, "Endpoint: example.com:11111\n")
+
+	out = runConsole(t, configManager,
+		"/find bob",
+		"#3",
+		"No",
+		"/cancel",
+		"hello",
+	)
+	require.Contains(t, out, "  [#1] 1. Config  [#2] 1. Disable  [#3] 1. Remove\n")
+	require.Contains(t, out, "Are you sure that you want to remove peer?")
+	// Declined removal keeps the results, so the command is still active
+	require.Contains(t, out, "Removal cancelled\nPeers matching 'bob'\n")
+	require.Contains(t, out, "Command was cancelled\nUnknown command")
+	require.Contains(t, out, "Unknown command, available commands: /abort, /add_peer, /batch, /cancel, /client_config, /commit, /doctor, /export, /find, /import, /ipam\n")
+
+	out = runConsole(t, configManager,
+		"/find bob",
+		"#2",
+		"No",
+		"#2",
+		"Yes",
+		"#2",
+		"Yes",
+	)
+	require.Contains(t, out, "+# disabled: 192.168.3.2/32\n")
+	require.Contains(t, out, "Disable Bob laptop - 192.168.3.2/32?\n  (Yes | No)\nNothing was changed\nPeers matching 'bob'\n")
+	require.Contains(t, out, "Bob laptop - 192.168.3.2/32 is disabled, it keeps its address and could be enabled again\n"+
+		"Peers matching 'bob'\n\n1. Bob laptop (disabled)\n    192.168.3.2/32\n")
+	require.Contains(t, out, "  [#1] 1. Config  [#2] 1. Enable  [#3] 1. Remove\n")
+	require.Contains(t, out, "Bob laptop - 192.168.3.2/32 is enabled\nPeers matching 'bob'\n\n1. Bob laptop\n")
+	peer, err := configManager.GetPeer(consolePublicKey)
+	require.NoError(t, err)
+	require.False(t, peer.Disabled)
+	require.Equal(t, "192.168.3.2/32", peer.AllowedIPs)
+
+	config.Reserved = []string{".3-.20"}
+	out = runConsole(t, configManager, "/ipam")
+	require.Equal(t, `Address pool
+Subnet: 192.168.3.0/24
+Used: 2 / 254
+Reserved: 18 (192.168.3.3-192.168.3.20)
+Free: 234
+Next address: 192.168.3.21
+`, out)
+}
+
+func TestConsoleDoctor(t *testing.T) {
+	wgConfig := newTestConfig(t)
+	configManager := newAuditedConfigManager(t, wgConfig)
+	config := consoleServerConfig + `
+# Alice
+[Peer]
+PublicKey  = ` + consolePublicKey + `
+AllowedIPs = 192.168.3.2/32
+
+# Bob
+[Peer]
+PublicKey  = ` + consolePublicKey + `
+AllowedIPs = 192.168.30.3/32
+`
+	require.NoError(t, os.WriteFile(wgConfig.ConfigFilePath, []byte(config), 0600))
+
+	out := runConsole(t, configManager,
+		"/doctor",
+		"hello",
+		"#2",
+		"No",
+		"#2",
+		"Yes",
+		"#1",
+		"Yes",
+	)
+	require.Contains(t, out, "Problems found in server configuration:\n\n"+
+		"1. line 12: peer \"Bob\" has the same public key as peer \"Alice\"\n"+
+		"2. line 12: peer \"Bob\" has no address in interface subnet 192.168.3.0/24\n")
+	require.Contains(t, out, "  [#1] 1. Remove\n  [#2] 2. Re-address\n")
+	require.Contains(t, out, "Please choose a fix using buttons above\n")
+	require.Contains(t, out, "-AllowedIPs = 192.168.30.3/32\n+AllowedIPs = 192.168.3.3/32\n")
+	require.Contains(t, out, "Apply fix 'Re-address'?\n")
+	require.Contains(t, out, "Peer got new address 192.168.3.3\n")
+	require.Contains(t, out, "Peer was removed successfully!\n")
+	require.Contains(t, out, "Problems found in server configuration:\n\n"+
+		"1. line 12: peer \"Bob\" has the same public key as peer \"Alice\"\n\n  [#1] 1. Remove\n")
+	require.Contains(t, out, "No problems found in server configuration\n")
+
+	content, err := os.ReadFile(wgConfig.ConfigFilePath)
+	require.NoError(t, err)
+	require.NotContains(t, string(content), "Bob")
+
+	entries, err := configManager.Log.Tail(audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
+
+func TestConsoleBatch(t *testing.T) {
+	configManager := newTestConfigManager(t)
+	otherKey := "Dq7pWRg3Us+s8KxsWbRCdSEePGda1bPDqsoEvygyjhk="
+
+	out := runConsole(t, configManager,
+		"/commit",
+		"/batch",
+		"/batch",
+		"/add_peer",
+		consolePublicKey,
+		"Bob laptop",
+		"Yes",
+		"/add_peer",
+		consolePublicKey,
+		"Bob again",
+		"Yes",
+		"/add_peer",
+		otherKey,
+		"Alice phone",
+		"Yes",
+		"/batch",
+	)
+	require.Contains(t, out, "Batch mode is off, use /batch to start staging changes\n")
+	require.Contains(t, out, "Batch mode is on, no changes are staged yet\n")
+	require.Contains(t, out, "Change was staged, changes waiting for /commit: 1\n")
+	require.Contains(t, out, "Change could not be staged, it conflicts with the configuration or with staged changes\n")
+	require.Contains(t, out, "Change was staged, changes waiting for /commit: 2\n")
+	require.Contains(t, out, "Staged changes:\n1. Add Bob laptop - 192.168.3.2/32\n2. Add Alice phone - 192.168.3.3/32\n")
+	require.Contains(t, out, "+AllowedIPs = 192.168.3.3/32\n")
+	require.NotContains(t, out, "Config below")
+
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Empty(t, peers)
+
+	out = runConsole(t, configManager, "/commit", "Yes")
+	require.Contains(t, out, "Apply staged changes (2)?\n")
+	require.Contains(t, out, "Changes were applied: 2. Batch mode is off\n")
+	require.Contains(t, out, "Address: 192.168.3.2/24\n")
+	require.Contains(t, out, "Address: 192.168.3.3/24\n")
+	peers, err = configManager.ListPeers()
+	require.NoError(t, err)
+	require.Len(t, peers, 2)
+	entries, err := configManager.Log.Tail(audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	out = runConsole(t, configManager,
+		"/batch",
+		"/find bob",
+		"#3",
+		"Yes",
+		"/abort",
+		"/abort",
+	)
+	require.Contains(t, out, "Change was staged, changes waiting for /commit: 1\n")
+	require.Contains(t, out, "Staged changes were dropped: 1. Batch mode is off\n")
+	require.Contains(t, out, "Batch mode is off, use /batch to start staging changes\n")
+	peers, err = configManager.ListPeers()
+	require.NoError(t, err)
+	require.Len(t, peers, 2)
+}
+
+func TestConsoleImport(t *testing.T) {
+	configManager := newTestConfigManager(t)
+	otherKey := "Dq7pWRg3Us+s8KxsWbRCdSEePGda1bPDqsoEvygyjhk="
+	path := filepath.Join(t.TempDir(), "peers.csv")
+	require.NoError(t, os.WriteFile(path, []byte("name,public_key,address\n"+
+		"Bob laptop,"+consolePublicKey+"\n"+
+		"Bob again,"+consolePublicKey+"\n"+
+		"Alice/phone,"+otherKey+",192.168.3.10\n"), 0600))
+
+	out := runConsole(t, configManager, "/import "+path, "No", "/import "+path, "Yes")
+	require.Contains(t, out, "These rows will be skipped:\nrow 3: peer with public key "+consolePublicKey+" already exists\n")
+	require.Contains(t, out, "+AllowedIPs = 192.168.3.10/32\n")
+	require.Contains(t, out, "Add 2 peers?\n")
+	require.Contains(t, out, "Peers were added: 2. Client configs are below\n")
+	require.Contains(t, out, "Client configs, the private key of every peer should be filled in\n[client_configs.zip, ")
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Len(t, peers, 2)
+	require.Equal(t, "192.168.3.2/32", peers[0].AllowedIPs)
+	require.Equal(t, "192.168.3.10/32", peers[1].AllowedIPs)
+
+	// Peers which are already added are skipped
+	out = runConsole(t, configManager, "/import "+path, "/import "+filepath.Join(t.TempDir(), "missing.csv"))
+	require.Contains(t, out, "There are no peers to import\n")
+	require.Contains(t, out, "Could not read the file\n")
+
+	// Imported peers are staged in batch mode
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "Carol", "public_key": "Dc6HJYJHhm//iEeQDnXDPPtQ1u9slnkDaflP0ar4ISA="}]`), 0600))
+	out = runConsole(t, configManager, "/batch", "/import "+path, "Yes")
+	require.NotContains(t, out, "+[Peer]")
+	require.Contains(t, out, "Peers were staged: 1, changes waiting for /commit: 1\n")
+	out = runConsole(t, configManager, "/commit", "Yes")
+	require.Contains(t, out, "Changes were applied: 1. Batch mode is off\n")
+	peers, err = configManager.ListPeers()
+	require.NoError(t, err)
+	require.Len(t, peers, 3)
+}
+
+func TestConfigFileName(t *testing.T) {
+	used := map[string]bool{}
+	require.Equal(t, "Bob_laptop.conf", configFileName("Bob laptop", used))
+	require.Equal(t, "Bob_laptop-2.conf", configFileName("Bob/laptop", used))
+	require.Equal(t, "peer.conf", configFileName("../", used))
+	require.Equal(t, "Алиса.conf", configFileName("Алиса", used))
+}
+
+func TestConsoleExport(t *testing.T) {
+	configManager := newTestConfigManager(t)
+	require.NoError(t, configManager.AddPeer(Actor(&Console{User: User{ID: 1}}), consolePublicKey, "Bob laptop"))
+
+	out := runConsole(t, configManager, "/export", "/export json", "/export xml")
+	require.Contains(t, out, "Peers as of ")
+	require.Contains(t, out, ".csv]\nname,public_key,allowed_ips,disabled\nBob laptop,"+consolePublicKey+",192.168.3.2/32,false\n")
+	require.Contains(t, out, ".json]\n[\n  {\n    \"name\": \"Bob laptop\",\n")
+	require.Contains(t, out, "Usage: /export [csv|json]\n")
+}
+
+func TestAddPeerAppliesPreview(t *testing.T) {
+	config := newTestConfig(t)
+	config.Allocation = wireguard.Random
+	configManager := newAuditedConfigManager(t, config)
+	out := &bytes.Buffer{}
+	console := &Console{Out: out, User: User{ID: 1, Username: "admin"}}
+	run := func(w *Wizard, lines ...string) bool {
+		console.read("/add_peer")
+		finished := w.Start(console)
+		for _, line := range lines {
+			console.read(line)
+			finished = w.HandleInput(console)
+		}
+		return finished
+	}
+	previewedAddress := func() string {
+		matches := regexp.MustCompile(`\+AllowedIPs = (\S+)`).FindAllStringSubmatch(out.String(), -1)
+		require.NotEmpty(t, matches)
+		return matches[len(matches)-1][1]
+	}
+
+	// The random address of the preview is the one saved
+	require.True(t, run(NewAddPeerCommand(configManager), consolePublicKey, "Bob laptop", "Yes"))
+	peer, err := configManager.GetPeer(consolePublicKey)
+	require.NoError(t, err)
+	require.Equal(t, previewedAddress(), peer.AllowedIPs)
+
+	// The change is previewed again if the file was changed before it is confirmed
+	const otherKey = "Dq7pWRg3Us+s8KxsWbRCdSEePGda1bPDqsoEvygyjhk="
+	w := NewAddPeerCommand(configManager)
+	require.False(t, run(w, otherKey, "Alice phone"))
+	require.NoError(t, configManager.RemovePeer(audit.Actor{}, consolePublicKey))
+	out.Reset()
+	console.read("Yes")
+	require.False(t, w.HandleInput(console))
+	require.Contains(t, out.String(), "The configuration file was changed since the preview, please review the change again\n"+
+		"--- a/wg0.conf\n")
+	console.read("Yes")
+	require.True(t, w.HandleInput(console))
+	peer, err = configManager.GetPeer(otherKey)
+	require.NoError(t, err)
+	require.Equal(t, previewedAddress(), peer.AllowedIPs)
+}
+
+func TestImportAppliesPreview(t *testing.T) {
+	config := newTestConfig(t)
+	config.Allocation = wireguard.Random
+	configManager := newAuditedConfigManager(t, config)
+	const otherKey = "Dq7pWRg3Us+s8KxsWbRCdSEePGda1bPDqsoEvygyjhk="
+	path := filepath.Join(t.TempDir(), "peers.csv")
+	require.NoError(t, os.WriteFile(path, []byte("Bob laptop,"+consolePublicKey+"\nAlice phone,"+otherKey+",192.168.3.10\n"), 0600))
+	out := &bytes.Buffer{}
+	console := &Console{Out: out, User: User{ID: 1, Username: "admin"}}
+	cmd := &ImportCommand{ConfigManager: configManager, Open: func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}}
+
+	console.read("/import")
+	require.False(t, cmd.Start(console))
+	require.Contains(t, out.String(), "Add 2 peers?\n")
+
+	// Rows are checked again if the file was changed before the import is confirmed
+	require.NoError(t, configManager.AddPeer(audit.Actor{}, otherKey, "Alice"))
+	out.Reset()
+	console.read("Yes")
+	require.False(t, cmd.HandleInput(console))
+	require.Contains(t, out.String(), "The configuration file was changed since the preview, please review the change again\n"+
+		"These rows will be skipped:\nrow 2: peer with public key "+otherKey+" already exists\n")
+	require.Contains(t, out.String(), "Add 1 peers?\n")
+
+	console.read("Yes")
+	require.True(t, cmd.HandleInput(console))
+	matches := regexp.MustCompile(`\+AllowedIPs = (\S+)`).FindAllStringSubmatch(out.String(), -1)
+	require.Len(t, matches, 1)
+	peer, err := configManager.GetPeer(consolePublicKey)
+	require.NoError(t, err)
+	require.Equal(t, matches[0][1], peer.AllowedIPs)
+}
+
+func TestFindToggleFollowsState(t *testing.T) {
+	configManager := newTestConfigManager(t)
+	require.NoError(t, configManager.AddPeer(audit.Actor{}, consolePublicKey, "Bob laptop"))
+	console := &Console{Out: &bytes.Buffer{}, User: User{ID: 1, Username: "admin"}}
+	cmd := &FindCommand{ConfigManager: configManager, Query: "bob"}
+	require.False(t, cmd.Start(console))
+	disable := console.buttons[1]
+	require.Equal(t, "1. Disable", disable.Text)
+
+	press := func(data string) {
+		console.text, console.pressed, console.data = "", true, data
+		require.False(t, cmd.HandleInput(console))
+	}
+	press(disable.Data)
+	console.read("Yes")
+	require.False(t, cmd.HandleInput(console))
+	require.Equal(t, "1. Enable", console.buttons[1].Text)
+	// The outdated button doesn't enable the peer again
+	press(disable.Data)
+	peer, err := configManager.GetPeer(consolePublicKey)
+	require.NoError(t, err)
+	require.True(t, peer.Disabled)
+
+	// In batch mode the change is staged after the confirmation
+	require.True(t, configManager.StartBatch(Actor(console)))
+	press(console.buttons[1].Data)
+	console.read("Yes")
+	require.False(t, cmd.HandleInput(console))
+	peer, err = configManager.GetPeer(consolePublicKey)
+	require.NoError(t, err)
+	require.True(t, peer.Disabled)
+	ops := configManager.Batch(Actor(console)).Ops()
+	require.Len(t, ops, 1)
+	require.Equal(t, wireguard.OpEnablePeer, ops[0].Kind)
+	require.Equal(t, "192.168.3.2/32", ops[0].Peer.AllowedIPs)
+
+	_, err = configManager.CommitBatch(Actor(console), nil)
+	require.NoError(t, err)
+	peer, err = configManager.GetPeer(consolePublicKey)
+	require.NoError(t, err)
+	require.False(t, peer.Disabled)
+}
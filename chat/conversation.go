@@ -0,0 +1,100 @@
+// Package chat contains peer management commands which don't depend on a particular
+// messenger. Commands talk to the user through Conversation, every frontend (Telegram bot,
+// console) provides its own implementation of it.
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+)
+
+// User is the person a conversation is with.
+type User struct {
+	ID       int64
+	Username string
+	// Full name of the user
+	Name string
+}
+
+func (u User) String() string {
+	name := u.Name
+	if u.Username != "" {
+		name += " @" + u.Username
+	}
+	return fmt.Sprintf("%s (%d)", name, u.ID)
+}
+
+// Button is attached to a message. Once it is pressed, its Data is passed to the command as input.
+type Button struct {
+	Text string
+	Data string
+}
+
+// Keyboard is attached to a sent message.
+type Keyboard struct {
+	// Answers offered to the user, which are sent as usual messages once chosen
+	Answers []string
+	// Buttons attached to the message, a row per slice
+	Buttons [][]Button
+	// Remove hides answers which were offered before
+	Remove bool
+}
+
+// RemoveKeyboard hides answers which were offered before.
+var RemoveKeyboard = &Keyboard{Remove: true}
+
+// Document is a file sent to the user.
+type Document struct {
+	FileName string
+	Content  []byte
+	Caption  string
+}
+
+// Conversation is a chat with a single user, as seen by a command handling the user's input.
+type Conversation interface {
+	// Sender returns the user the input came from.
+	Sender() User
+	// Language returns language messages to the user should be sent in.
+	Language() string
+	// Text returns text of the incoming message, it is empty if a button was pressed.
+	Text() string
+	// Pressed reports whether the input is a button press rather than a message.
+	Pressed() bool
+	// Data returns data of the pressed button.
+	Data() string
+
+	// Send sends a text message, keyboard is optional.
+	Send(text string, keyboard *Keyboard) error
+	// SendFormatted sends a formatted message, split into several ones if it is too long.
+	SendFormatted(msg *render.Message) error
+	// SendDocument sends a file.
+	SendDocument(doc *Document) error
+	// Edit replaces the message which has the pressed button, buttons are optional.
+	Edit(text string, buttons [][]Button) error
+	// Answer acknowledges the button press, showing text to the user if it is not empty.
+	Answer(text string) error
+	// Notify sends a message to another user of the same frontend.
+	Notify(userID int64, text string) error
+}
+
+// tr translates the message to the language of the conversation.
+func tr(conv Conversation, key i18n.Key, args ...interface{}) string {
+	return i18n.Translate(conv.Language(), key, args...)
+}
+
+// Actor returns the user the input came from, as recorded in the audit log.
+func Actor(conv Conversation) audit.Actor {
+	user := conv.Sender()
+	username := user.Username
+	if username == "" {
+		username = strings.TrimSpace(user.Name)
+	}
+	return audit.Actor{
+		UserID:   user.ID,
+		Username: username,
+	}
+}
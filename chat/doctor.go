@@ -0,0 +1,198 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+// maxProblemsShown limits the problem list, the rest is shown once the first ones are fixed.
+const maxProblemsShown = 10
+
+var fixLabels = map[wireguard.Fix]i18n.Key{
+	wireguard.FixReaddress: i18n.FixReaddress,
+	wireguard.FixRemove:    i18n.FixRemove,
+}
+
+// DoctorCommand shows problems of the server configuration, and applies fixes chosen by the user
+// after confirmation. Configuration is checked again after every fix.
+type DoctorCommand struct {
+	*audit.ConfigManager
+	session  string
+	problems []wireguard.Problem
+	// Problem and the fix waiting for confirmation
+	problem *wireguard.Problem
+	fix     wireguard.Fix
+}
+
+func (cmd *DoctorCommand) Start(conv Conversation) bool {
+	return cmd.check(conv)
+}
+
+// check sends problems found along with buttons to fix them. It returns true if there is nothing to fix.
+func (cmd *DoctorCommand) check(conv Conversation) bool {
+	problems, err := cmd.Check()
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.CheckError), RemoveKeyboard)
+		return true
+	}
+	if len(problems) == 0 {
+		conv.Send(tr(conv, i18n.NoProblems), RemoveKeyboard)
+		return true
+	}
+	// Buttons of the previous list refer to problems by their position, which could have changed
+	cmd.session, err = sessionToken()
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.CheckError), RemoveKeyboard)
+		return true
+	}
+	cmd.problems = problems
+
+	builder := strings.Builder{}
+	builder.WriteString(tr(conv, i18n.ProblemsFound) + "\n\n")
+	buttons := [][]Button{}
+	for i, problem := range problems {
+		if i == maxProblemsShown {
+			builder.WriteString(tr(conv, i18n.MoreProblems, len(problems)-maxProblemsShown) + "\n")
+			break
+		}
+		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, problem.String()))
+		row := []Button{}
+		for _, fix := range problem.Fixes {
+			row = append(row, Button{
+				Text: fmt.Sprintf("%d. %s", i+1, tr(conv, fixLabels[fix])),
+				Data: strings.Join([]string{cmd.session, strconv.Itoa(i), string(fix)}, "|"),
+			})
+		}
+		if len(row) > 0 {
+			buttons = append(buttons, row)
+		}
+	}
+	conv.Send(builder.String(), &Keyboard{Buttons: buttons})
+	return len(buttons) == 0
+}
+
+// selected returns the problem and the fix of the pressed button, or nil if the button is outdated.
+func (cmd *DoctorCommand) selected(data string) (*wireguard.Problem, wireguard.Fix) {
+	args := strings.Split(data, "|")
+	if len(args) != 3 || args[0] != cmd.session {
+		return nil, ""
+	}
+	index, err := strconv.Atoi(args[1])
+	if err != nil || index < 0 || index >= len(cmd.problems) {
+		return nil, ""
+	}
+	problem := &cmd.problems[index]
+	for _, fix := range problem.Fixes {
+		if string(fix) == args[2] {
+			return problem, fix
+		}
+	}
+	return nil, ""
+}
+
+func (cmd *DoctorCommand) HandleInput(conv Conversation) bool {
+	if conv.Pressed() {
+		problem, fix := cmd.selected(conv.Data())
+		if problem == nil {
+			conv.Answer(tr(conv, i18n.ListOutdated))
+			return false
+		}
+		conv.Answer("")
+		cmd.problem, cmd.fix = problem, fix
+		preview, err := cmd.preview(conv)
+		sendPreview(conv, preview, err)
+		conv.Send(tr(conv, i18n.FixConfirmation, problem.String(), tr(conv, fixLabels[fix])),
+			&Keyboard{Answers: []string{tr(conv, i18n.Yes), tr(conv, i18n.No)}})
+		return false
+	}
+
+	if cmd.problem == nil {
+		conv.Send(tr(conv, i18n.SelectFix), nil)
+		return false
+	}
+	responseText := strings.TrimSpace(conv.Text())
+	switch {
+	case strings.EqualFold(responseText, tr(conv, i18n.Yes)):
+		if batch := cmd.Batch(Actor(conv)); batch != nil {
+			// Configuration doesn't change until the batch is committed, so there is nothing to check again
+			cmd.stage(conv, batch)
+			return true
+		}
+		cmd.apply(conv)
+		return cmd.check(conv)
+	case strings.EqualFold(responseText, tr(conv, i18n.No)):
+		cmd.problem = nil
+		conv.Send(tr(conv, i18n.SelectFix), RemoveKeyboard)
+		return false
+	default:
+		conv.Send(tr(conv, i18n.AnswerYesOrNo, tr(conv, i18n.Yes), tr(conv, i18n.No)), nil)
+		return false
+	}
+}
+
+// preview returns diff of the fix waiting for confirmation.
+func (cmd *DoctorCommand) preview(conv Conversation) (string, error) {
+	if cmd.Batch(Actor(conv)) != nil {
+		// Staged changes are reviewed together
+		return "", nil
+	}
+	problem := cmd.problem
+	switch cmd.fix {
+	case wireguard.FixReaddress:
+		return changeDiff(cmd.PreviewReaddressPeer(problem.Peer, problem.PublicKey, problem.AllowedIP))
+	case wireguard.FixRemove:
+		return changeDiff(cmd.PreviewRemovePeerAt(problem.Peer, problem.PublicKey))
+	}
+	return "", nil
+}
+
+// apply applies the confirmed fix.
+func (cmd *DoctorCommand) apply(conv Conversation) {
+	problem := cmd.problem
+	cmd.problem = nil
+	var text string
+	var err error
+	switch cmd.fix {
+	case wireguard.FixReaddress:
+		var addr net.IP
+		addr, err = cmd.ReaddressPeer(Actor(conv), problem.Peer, problem.PublicKey, problem.AllowedIP)
+		if err == nil {
+			text = tr(conv, i18n.PeerReaddressed, addr.String())
+		}
+	case wireguard.FixRemove:
+		err = cmd.RemovePeerAt(Actor(conv), problem.Peer, problem.PublicKey)
+		text = tr(conv, i18n.PeerRemoved)
+	}
+	switch {
+	case errors.Is(err, wireguard.ErrPeerChanged):
+		text = tr(conv, i18n.FixOutdated)
+	case err != nil:
+		log.Println(err)
+		text = tr(conv, i18n.FixError)
+	}
+	conv.Send(text, RemoveKeyboard)
+}
+
+// stage stages the confirmed fix in the batch.
+func (cmd *DoctorCommand) stage(conv Conversation, batch *wireguard.Batch) {
+	problem := cmd.problem
+	cmd.problem = nil
+	var err error
+	switch cmd.fix {
+	case wireguard.FixReaddress:
+		err = batch.ReaddressPeer(problem.Peer, problem.PublicKey, problem.AllowedIP)
+	case wireguard.FixRemove:
+		err = batch.RemovePeerAt(problem.Peer, problem.PublicKey)
+	}
+	stageChange(conv, batch, err)
+}
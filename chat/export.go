@@ -0,0 +1,47 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+// ExportCommand sends the inventory of peers as a file. It finishes right away, so frontends
+// may run it without interrupting the active command.
+type ExportCommand struct {
+	*audit.ConfigManager
+	// Format name, CSV is sent if it is empty
+	Format string
+}
+
+func (cmd *ExportCommand) Start(conv Conversation) bool {
+	format, err := wireguard.ParseExportFormat(cmd.Format)
+	if err != nil {
+		conv.Send(tr(conv, i18n.ExportUsage), nil)
+		return true
+	}
+	content, err := cmd.Export(format)
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.ExportError), nil)
+		return true
+	}
+	exportedAt := time.Now().UTC()
+	err = conv.SendDocument(&Document{
+		FileName: fmt.Sprintf("peers-%s.%s", exportedAt.Format("2006-01-02"), format),
+		Content:  content,
+		Caption:  tr(conv, i18n.ExportCaption, exportedAt.Format("2006-01-02 15:04 UTC")),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+	return true
+}
+
+func (cmd *ExportCommand) HandleInput(conv Conversation) bool {
+	return true
+}
@@ -0,0 +1,178 @@
+package chat
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+const (
+	configAction  = "config"
+	disableAction = "disable"
+	enableAction  = "enable"
+	removeAction  = "remove"
+)
+
+var findActions = []pickerAction{
+	{Action: configAction, Label: i18n.ActionConfig},
+	{Action: disableAction, Label: i18n.ActionDisable, DisabledAction: enableAction, DisabledLabel: i18n.ActionEnable},
+	{Action: removeAction, Label: i18n.ActionRemove},
+}
+
+type FindCommand struct {
+	*audit.ConfigManager
+	Query  string
+	picker *peerPicker
+	// Removal, disabling or enabling of selected peer waiting for confirmation
+	confirm *Wizard
+}
+
+func (cmd *FindCommand) Start(conv Conversation) bool {
+	if cmd.Query == "" {
+		conv.Send(tr(conv, i18n.FindUsage), nil)
+		return true
+	}
+	picker, err := newPeerPicker(cmd.ConfigManager, tr(conv, i18n.PeersMatching, cmd.Query), cmd.Query, findActions...)
+	if err != nil {
+		conv.Send(tr(conv, i18n.PeerListError), nil)
+		log.Println(err)
+		return true
+	}
+	if len(picker.peers) == 0 {
+		conv.Send(tr(conv, i18n.NoPeersMatching, cmd.Query), nil)
+		return true
+	}
+	cmd.picker = picker
+	picker.send(conv)
+	return false
+}
+
+func (cmd *FindCommand) HandleInput(conv Conversation) bool {
+	if cmd.confirm != nil {
+		if !cmd.confirm.HandleInput(conv) {
+			return false
+		}
+		// Results stay usable after the change is done or declined
+		cmd.confirm = nil
+		return cmd.showResults(conv)
+	}
+
+	if !conv.Pressed() {
+		// Any text sent while results are displayed is a new query
+		query := strings.TrimSpace(conv.Text())
+		if query == "" {
+			return false
+		}
+		cmd.Query = query
+		return cmd.Start(conv)
+	}
+
+	peer, action := cmd.picker.handle(conv)
+	if peer == nil {
+		return false
+	}
+	switch action {
+	case configAction:
+		SendClientConfig(conv, cmd.ConfigManager, peer.PublicKey)
+	case disableAction, enableAction:
+		enabled := action == enableAction
+		if peer.Disabled != enabled {
+			// The peer was already enabled or disabled since the button was shown
+			cmd.picker.redraw(conv)
+			return false
+		}
+		return cmd.confirmChange(conv, newSetPeerEnabledWizard(cmd.ConfigManager, peer, enabled))
+	case removeAction:
+		remove := NewRemovePeerCommand(cmd.ConfigManager)
+		remove.Values = map[string]string{
+			"peer":     peer.PublicKey,
+			"peerName": peer.Name,
+		}
+		remove.Declined = func(conv Conversation) {
+			conv.Send(tr(conv, i18n.RemovalCancelled), RemoveKeyboard)
+		}
+		return cmd.confirmChange(conv, remove)
+	}
+	return false
+}
+
+// confirmChange starts the wizard confirming a change of the selected peer. Results are shown
+// again once it finishes. It returns true if nothing is left to show.
+func (cmd *FindCommand) confirmChange(conv Conversation, w *Wizard) bool {
+	if w.Start(conv) {
+		return cmd.showResults(conv)
+	}
+	cmd.confirm = w
+	return false
+}
+
+// showResults sends the results again, as they could have been changed. It returns true if nothing is left.
+func (cmd *FindCommand) showResults(conv Conversation) bool {
+	err := cmd.picker.refresh()
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.PeerListError), nil)
+		return true
+	}
+	if len(cmd.picker.peers) == 0 {
+		conv.Send(tr(conv, i18n.NoPeersMatching, cmd.Query), nil)
+		return true
+	}
+	cmd.picker.send(conv)
+	return false
+}
+
+// newSetPeerEnabledWizard confirms disabling or enabling the peer, showing the change first.
+// The change is staged instead if the user stages changes.
+func newSetPeerEnabledWizard(configManager *audit.ConfigManager, peer *wireguard.Peer, enabled bool) *Wizard {
+	previewed := &previewedChange{}
+	confirmation, done := i18n.DisableConfirmation, i18n.PeerDisabled
+	if enabled {
+		confirmation, done = i18n.EnableConfirmation, i18n.PeerEnabled
+	}
+	name := peerLabel(peer)
+	return &Wizard{
+		Confirmation: func(conv Conversation, values map[string]string) string {
+			return tr(conv, confirmation, name)
+		},
+		Preview: func(conv Conversation, values map[string]string) (string, error) {
+			if configManager.Batch(Actor(conv)) != nil {
+				// Staged changes are reviewed together
+				return "", nil
+			}
+			return previewed.keep(configManager.PreviewSetPeerEnabled(peer.PublicKey, enabled))
+		},
+		Outdated: func(conv Conversation, values map[string]string) bool {
+			return configManager.Batch(Actor(conv)) == nil && previewed.outdated(conv, configManager)
+		},
+		Finish: func(conv Conversation, values map[string]string) {
+			if batch := configManager.Batch(Actor(conv)); batch != nil {
+				stageChange(conv, batch, batch.SetPeerEnabled(peer.PublicKey, enabled))
+				return
+			}
+			var err error
+			if previewed.change != nil {
+				err = configManager.ApplySetPeerEnabled(Actor(conv), peer.PublicKey, enabled, previewed.change)
+			} else {
+				err = configManager.SetPeerEnabled(Actor(conv), peer.PublicKey, enabled)
+			}
+			if errors.Is(err, wireguard.ErrChangeOutdated) {
+				conv.Send(tr(conv, i18n.ChangeOutdated), RemoveKeyboard)
+				return
+			}
+			if err != nil {
+				log.Println(err)
+				conv.Send(tr(conv, i18n.DisablePeerError), RemoveKeyboard)
+				return
+			}
+			conv.Send(tr(conv, done, name), RemoveKeyboard)
+		},
+		Declined: func(conv Conversation) {
+			conv.Send(tr(conv, i18n.ToggleCancelled), RemoveKeyboard)
+		},
+	}
+}
@@ -0,0 +1,242 @@
+package chat
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+// MaxImportSize limits the size of the file peers are imported from.
+const MaxImportSize = 1 << 20
+
+// ImportCommand adds peers listed in a CSV or JSON file once the user confirms it. Rows which
+// can't be imported are listed and skipped, the rest are added with a single configuration change.
+type ImportCommand struct {
+	*audit.ConfigManager
+	// Open returns the file to import, the command only shows how to import peers if it is nil
+	Open func() (io.ReadCloser, error)
+	rows []wireguard.ImportRow
+	// Rows staged in a scratch batch, its previewed change is applied once confirmed
+	scratch   *wireguard.Batch
+	count     int
+	previewed previewedChange
+	confirm   *Wizard
+}
+
+// read reads the file, sending the problem if it can't be read.
+func (cmd *ImportCommand) read(conv Conversation) ([]byte, bool) {
+	file, err := cmd.Open()
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.ImportReadError), nil)
+		return nil, false
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, MaxImportSize+1))
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.ImportReadError), nil)
+		return nil, false
+	}
+	if len(content) > MaxImportSize {
+		conv.Send(tr(conv, i18n.ImportTooLarge, MaxImportSize/1024), nil)
+		return nil, false
+	}
+	return content, true
+}
+
+func (cmd *ImportCommand) Start(conv Conversation) bool {
+	if cmd.Open == nil {
+		conv.Send(tr(conv, i18n.ImportHint), nil)
+		return true
+	}
+	content, ok := cmd.read(conv)
+	if !ok {
+		return true
+	}
+	rows, problems, err := wireguard.ParseImport(content)
+	if err != nil {
+		conv.Send(tr(conv, i18n.ImportParseError, err.Error()), nil)
+		return true
+	}
+
+	// Rows are checked against the current configuration, the change is shown unless it is staged
+	rowProblems, err := cmd.check(rows)
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.ImportFailed), nil)
+		return true
+	}
+	problems = append(problems, rowProblems...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Row < problems[j].Row
+	})
+	sendRowProblems(conv, problems)
+	if cmd.count == 0 {
+		conv.Send(tr(conv, i18n.NothingToImport), nil)
+		return true
+	}
+
+	cmd.confirm = &Wizard{
+		Confirmation: func(conv Conversation, values map[string]string) string {
+			return tr(conv, i18n.ImportConfirmation, cmd.count)
+		},
+		Preview: func(conv Conversation, values map[string]string) (string, error) {
+			if cmd.Batch(Actor(conv)) != nil {
+				// Staged changes are reviewed together
+				return "", nil
+			}
+			return cmd.previewed.keep(cmd.scratch.Preview())
+		},
+		Outdated: cmd.outdated,
+		Finish:   cmd.apply,
+	}
+	return cmd.confirm.Start(conv)
+}
+
+// check stages rows in a new scratch batch, so they are checked against the current configuration.
+func (cmd *ImportCommand) check(rows []wireguard.ImportRow) ([]wireguard.ImportError, error) {
+	scratch := cmd.ScratchBatch()
+	problems, err := scratch.Import(rows)
+	if err != nil {
+		return nil, err
+	}
+	cmd.rows, cmd.scratch, cmd.count = rows, scratch, scratch.Len()
+	return problems, nil
+}
+
+// outdated checks rows again if the configuration was changed since the change was previewed,
+// so the new preview shows which of them can still be imported.
+func (cmd *ImportCommand) outdated(conv Conversation, values map[string]string) bool {
+	if cmd.Batch(Actor(conv)) != nil || !cmd.previewed.outdated(conv, cmd.ConfigManager) {
+		return false
+	}
+	problems, err := cmd.check(cmd.rows)
+	if err != nil {
+		// Applying the previewed change reports that it is outdated
+		log.Println(err)
+		return false
+	}
+	sendRowProblems(conv, problems)
+	return true
+}
+
+func (cmd *ImportCommand) HandleInput(conv Conversation) bool {
+	return cmd.confirm.HandleInput(conv)
+}
+
+// sendRowProblems lists rows which are skipped.
+func sendRowProblems(conv Conversation, problems []wireguard.ImportError) {
+	if len(problems) == 0 {
+		return
+	}
+	builder := strings.Builder{}
+	builder.WriteString(tr(conv, i18n.RowsSkipped) + "\n")
+	for i, problem := range problems {
+		if i == maxProblemsShown {
+			builder.WriteString(tr(conv, i18n.MoreProblems, len(problems)-maxProblemsShown) + "\n")
+			break
+		}
+		builder.WriteString(problem.Error() + "\n")
+	}
+	conv.Send(builder.String(), nil)
+}
+
+// apply adds the confirmed peers, or stages them if the user stages changes.
+func (cmd *ImportCommand) apply(conv Conversation, values map[string]string) {
+	if batch := cmd.Batch(Actor(conv)); batch != nil {
+		staged := batch.Len()
+		problems, err := batch.Import(cmd.rows)
+		if err != nil {
+			log.Println(err)
+			conv.Send(tr(conv, i18n.StageError), RemoveKeyboard)
+			return
+		}
+		// Rows could conflict with changes staged since the file was checked
+		sendRowProblems(conv, problems)
+		conv.Send(tr(conv, i18n.ImportStaged, batch.Len()-staged, batch.Len()), RemoveKeyboard)
+		return
+	}
+
+	if cmd.count == 0 {
+		conv.Send(tr(conv, i18n.NothingToImport), RemoveKeyboard)
+		return
+	}
+	ops, err := cmd.Apply(Actor(conv), cmd.scratch, cmd.previewed.change)
+	if errors.Is(err, wireguard.ErrChangeOutdated) {
+		conv.Send(tr(conv, i18n.ChangeOutdated), RemoveKeyboard)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.ImportFailed), RemoveKeyboard)
+		return
+	}
+	conv.Send(tr(conv, i18n.PeersImported, len(ops)), RemoveKeyboard)
+	SendClientConfigs(conv, cmd.ConfigManager, ops)
+}
+
+// configFileName returns name of the client config file of the peer, unique among used ones.
+func configFileName(name string, used map[string]bool) string {
+	base := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	if strings.Trim(base, "_") == "" {
+		base = "peer"
+	}
+	fileName := base + ".conf"
+	for i := 2; used[fileName]; i++ {
+		fileName = fmt.Sprintf("%s-%d.conf", base, i)
+	}
+	used[fileName] = true
+	return fileName
+}
+
+// SendClientConfigs sends client configs of peers added by the changes as a zip archive.
+func SendClientConfigs(conv Conversation, configManager *audit.ConfigManager, ops []wireguard.Op) {
+	buffer := bytes.Buffer{}
+	archive := zip.NewWriter(&buffer)
+	used := map[string]bool{}
+	for _, op := range ops {
+		if op.Kind != wireguard.OpAddPeer {
+			continue
+		}
+		cfg, cfgStr, err := configManager.GetClientConfig(op.PublicKey)
+		if err == nil {
+			var file io.Writer
+			file, err = archive.Create(configFileName(cfg.Name, used))
+			if err == nil {
+				_, err = file.Write([]byte(cfgStr))
+			}
+		}
+		if err != nil {
+			log.Println(err)
+			conv.Send(tr(conv, i18n.ClientConfigErr), nil)
+			return
+		}
+	}
+	err := archive.Close()
+	if err == nil {
+		err = conv.SendDocument(&Document{
+			FileName: "client_configs.zip",
+			Content:  buffer.Bytes(),
+			Caption:  tr(conv, i18n.ClientConfigsCaption),
+		})
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,65 @@
+package chat
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+// IPAMCommand shows how addresses of the server subnet are used. It finishes right away,
+// so frontends may run it without interrupting the active command.
+type IPAMCommand struct {
+	*audit.ConfigManager
+}
+
+// FormatAddressPool returns message with address pool usage in the given language.
+func FormatAddressPool(lang string, pool *wireguard.AddressPool) *render.Message {
+	msg := &render.Message{}
+	field := func(label i18n.Key, value string) {
+		msg.Text(i18n.Translate(lang, label) + ": ").Code(value).Newline()
+	}
+	msg.Bold(i18n.Translate(lang, i18n.PoolTitle)).Newline()
+	field(i18n.PoolSubnet, pool.Network.String())
+	msg.Text(i18n.Translate(lang, i18n.PoolUsed) + ": ").Code(strconv.Itoa(pool.Used)).Text(" / ").Code(pool.Size.String()).Newline()
+	if len(pool.Ranges) > 0 {
+		ranges := make([]string, len(pool.Ranges))
+		for i, r := range pool.Ranges {
+			ranges[i] = r.String()
+		}
+		msg.Text(i18n.Translate(lang, i18n.PoolReserved) + ": ").Code(pool.Reserved.String()).
+			Text(" (" + strings.Join(ranges, ", ") + ")").Newline()
+	}
+	field(i18n.PoolFree, pool.Free.String())
+	if pool.Next == nil {
+		return msg.Text(i18n.Translate(lang, i18n.PoolNext) + ": " + i18n.Translate(lang, i18n.PoolExhausted))
+	}
+	if pool.Strategy == wireguard.Random {
+		return msg.Text(i18n.Translate(lang, i18n.PoolNext) + ": " + i18n.Translate(lang, i18n.PoolRandom) + " ").
+			Code(pool.Next.String())
+	}
+	field(i18n.PoolNext, pool.Next.String())
+	return msg
+}
+
+func (cmd *IPAMCommand) Start(conv Conversation) bool {
+	pool, err := cmd.AddressPool()
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.PoolError), nil)
+		return true
+	}
+	err = conv.SendFormatted(FormatAddressPool(conv.Language(), pool))
+	if err != nil {
+		log.Println(err)
+	}
+	return true
+}
+
+func (cmd *IPAMCommand) HandleInput(conv Conversation) bool {
+	return true
+}
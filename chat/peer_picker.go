@@ -0,0 +1,229 @@
+package chat
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+const (
+	peersPerPage       = 8
+	peersPerSearchPage = 5
+)
+
+const (
+	pageAction   = "page"
+	selectAction = "select"
+)
+
+const searchResultLine = "%d. %s\n    %s\n    %s\n"
+
+type pickerAction struct {
+	Action string
+	Label  i18n.Key
+	// Action and label used instead for disabled peers, if they are set
+	DisabledAction string
+	DisabledLabel  i18n.Key
+}
+
+// forPeer returns action and label of the button for the peer.
+func (a *pickerAction) forPeer(peer *wireguard.Peer) (string, i18n.Key) {
+	if peer.Disabled && a.DisabledAction != "" {
+		return a.DisabledAction, a.DisabledLabel
+	}
+	return a.Action, a.Label
+}
+
+// peerPicker shows peers as buttons. Button data contains a
+// session token of the picker and a peer ID, so buttons of outdated lists
+// and peers which were removed in the meantime are detected.
+//
+// Without actions picker displays a single button for every peer. With actions
+// it lists peers in the message text, and displays a button for every action on them.
+type peerPicker struct {
+	*audit.ConfigManager
+	prompt  string
+	query   string
+	actions []pickerAction
+	session string
+	peers   []wireguard.Peer
+	page    int
+}
+
+func newPeerPicker(configManager *audit.ConfigManager, prompt string, query string, actions ...pickerAction) (*peerPicker, error) {
+	session, err := sessionToken()
+	if err != nil {
+		return nil, err
+	}
+	picker := &peerPicker{
+		ConfigManager: configManager,
+		prompt:        prompt,
+		query:         query,
+		actions:       actions,
+		session:       session,
+	}
+	err = picker.refresh()
+	if err != nil {
+		return nil, err
+	}
+	return picker, nil
+}
+
+func (p *peerPicker) refresh() error {
+	peers, err := p.ConfigManager.FindPeers(p.query)
+	if err != nil {
+		return err
+	}
+	p.peers = peers
+	if p.page >= p.pageCount() {
+		p.page = p.pageCount() - 1
+	}
+	if p.page < 0 {
+		p.page = 0
+	}
+	return nil
+}
+
+func (p *peerPicker) pageSize() int {
+	if len(p.actions) > 0 {
+		return peersPerSearchPage
+	}
+	return peersPerPage
+}
+
+func (p *peerPicker) pageCount() int {
+	return (len(p.peers) + p.pageSize() - 1) / p.pageSize()
+}
+
+func (p *peerPicker) text(conv Conversation) string {
+	text := p.prompt
+	if p.pageCount() > 1 {
+		text = tr(conv, i18n.PageOf, p.prompt, p.page+1, p.pageCount())
+	}
+	if len(p.actions) == 0 {
+		return text
+	}
+	builder := strings.Builder{}
+	builder.WriteString(text + "\n\n")
+	start := p.page * p.pageSize()
+	for i := start; i < start+p.pageSize() && i < len(p.peers); i++ {
+		name := p.peers[i].Name
+		if p.peers[i].Disabled {
+			name = tr(conv, i18n.DisabledPeer, name)
+		}
+		builder.WriteString(fmt.Sprintf(searchResultLine, i+1, name, p.peers[i].AllowedIPs, p.peers[i].PublicKey))
+	}
+	return builder.String()
+}
+
+func peerLabel(peer *wireguard.Peer) string {
+	name := peer.Name
+	if name == "" {
+		name = peer.PublicKey
+	}
+	return fmt.Sprintf("%s - %s", name, peer.AllowedIPs)
+}
+
+// button returns button which data contains the picker session and the given arguments.
+func (p *peerPicker) button(text string, args ...string) Button {
+	return Button{Text: text, Data: strings.Join(append([]string{p.session}, args...), "|")}
+}
+
+func (p *peerPicker) buttons(conv Conversation) [][]Button {
+	rows := [][]Button{}
+	start := p.page * p.pageSize()
+	for i := start; i < start+p.pageSize() && i < len(p.peers); i++ {
+		if len(p.actions) == 0 {
+			rows = append(rows, []Button{p.button(peerLabel(&p.peers[i]), p.peers[i].ID(), selectAction)})
+			continue
+		}
+		buttons := []Button{}
+		for _, action := range p.actions {
+			name, label := action.forPeer(&p.peers[i])
+			buttons = append(buttons, p.button(fmt.Sprintf("%d. %s", i+1, tr(conv, label)), p.peers[i].ID(), name))
+		}
+		rows = append(rows, buttons)
+	}
+	navigation := []Button{}
+	if p.page > 0 {
+		navigation = append(navigation, p.button(tr(conv, i18n.PreviousPage), pageAction, strconv.Itoa(p.page-1)))
+	}
+	if p.page < p.pageCount()-1 {
+		navigation = append(navigation, p.button(tr(conv, i18n.NextPage), pageAction, strconv.Itoa(p.page+1)))
+	}
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+	return rows
+}
+
+func (p *peerPicker) send(conv Conversation) {
+	conv.Send(p.text(conv), &Keyboard{Buttons: p.buttons(conv)})
+}
+
+func (p *peerPicker) redraw(conv Conversation) {
+	conv.Edit(p.text(conv), p.buttons(conv))
+}
+
+// handle processes button press while picker is active. It returns selected peer
+// and action, or nil if peer wasn't selected yet.
+func (p *peerPicker) handle(conv Conversation) (*wireguard.Peer, string) {
+	if !conv.Pressed() {
+		conv.Send(tr(conv, i18n.SelectPeer), nil)
+		return nil, ""
+	}
+	args := strings.Split(conv.Data(), "|")
+	if len(args) != 3 || args[0] != p.session {
+		conv.Answer(tr(conv, i18n.ListOutdated))
+		return nil, ""
+	}
+	if args[1] == pageAction {
+		page, err := strconv.Atoi(args[2])
+		if err == nil && page >= 0 && page < p.pageCount() {
+			p.page = page
+		}
+		conv.Answer("")
+		p.redraw(conv)
+		return nil, ""
+	}
+
+	// Peer list could have been changed since it was displayed, so check that selected peer still exists
+	err := p.refresh()
+	if err != nil {
+		log.Println(err)
+		conv.Answer(tr(conv, i18n.PeerListError))
+		return nil, ""
+	}
+	for i := range p.peers {
+		if p.peers[i].ID() == args[1] {
+			conv.Answer("")
+			if len(p.actions) == 0 {
+				conv.Edit(tr(conv, i18n.PeerSelected, p.prompt, peerLabel(&p.peers[i])), nil)
+			}
+			return &p.peers[i], args[2]
+		}
+	}
+	conv.Answer(tr(conv, i18n.PeerGone))
+	if len(p.peers) == 0 {
+		conv.Edit(tr(conv, i18n.NoPeersFound), nil)
+		return nil, ""
+	}
+	p.redraw(conv)
+	return nil, ""
+}
+
+func sessionToken() (string, error) {
+	buf := make([]byte, 6)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("error generating random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,149 @@
+package chat
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"github.com/stretchr/testify/require"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// newTestPickerConfig returns config manager with the given number of peers, named "Peer 1", "Peer 2" etc.
+func newTestPickerConfig(t *testing.T, count int) (*audit.ConfigManager, []string) {
+	configManager := newTestConfigManager(t)
+	keys := []string{}
+	for i := 1; i <= count; i++ {
+		key, err := wgtypes.GeneratePrivateKey()
+		require.NoError(t, err)
+		publicKey := key.PublicKey().String()
+		require.NoError(t, configManager.AddPeer(audit.Actor{}, publicKey, fmt.Sprintf("Peer %d", i)))
+		keys = append(keys, publicKey)
+	}
+	return configManager, keys
+}
+
+func TestPeerPicker(t *testing.T) {
+	out := &bytes.Buffer{}
+	console := &Console{Out: out}
+	// lastLines returns the given number of the latest output lines
+	lastLines := func(n int) []string {
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		return lines[len(lines)-n:]
+	}
+	press := func(p *peerPicker, data string) (*wireguard.Peer, string) {
+		console.text, console.pressed, console.data = "", true, data
+		return p.handle(console)
+	}
+	// buttonData returns data of the displayed button with the given text
+	buttonData := func(text string) string {
+		for _, button := range console.buttons {
+			if button.Text == text {
+				return button.Data
+			}
+		}
+		require.Failf(t, "button not found", "%q is not among %v", text, console.buttons)
+		return ""
+	}
+
+	t.Run("paging", func(t *testing.T) {
+		configManager, _ := newTestPickerConfig(t, 10)
+		picker, err := newPeerPicker(configManager, "Pick a peer", "")
+		require.NoError(t, err)
+		picker.send(console)
+		require.Equal(t, "Pick a peer (page 1 of 2)", lastLines(10)[0])
+		require.Len(t, console.buttons, peersPerPage+1)
+		require.Equal(t, "Peer 1 - 192.168.3.2/32", console.buttons[0].Text)
+		require.Equal(t, "Next »", console.buttons[peersPerPage].Text)
+
+		peer, _ := press(picker, buttonData("Next »"))
+		require.Nil(t, peer)
+		require.Equal(t, []string{
+			"Pick a peer (page 2 of 2)",
+			"  [#1] Peer 9 - 192.168.3.10/32",
+			"  [#2] Peer 10 - 192.168.3.11/32",
+			"  [#3] « Previous",
+		}, lastLines(4))
+
+		// Pages which don't exist are ignored
+		peer, _ = press(picker, picker.session+"|page|5")
+		require.Nil(t, peer)
+		require.Equal(t, 1, picker.page)
+
+		peer, action := press(picker, buttonData("Peer 10 - 192.168.3.11/32"))
+		require.NotNil(t, peer)
+		require.Equal(t, "Peer 10", peer.Name)
+		require.Equal(t, selectAction, action)
+		require.Equal(t, []string{"Pick a peer", "Selected: Peer 10 - 192.168.3.11/32"}, lastLines(2))
+	})
+
+	t.Run("actions", func(t *testing.T) {
+		configManager, keys := newTestPickerConfig(t, 6)
+		picker, err := newPeerPicker(configManager, "Peers", "Peer", pickerAction{Action: "remove", Label: i18n.ActionRemove})
+		require.NoError(t, err)
+		picker.send(console)
+		require.Len(t, console.buttons, peersPerSearchPage+1)
+		require.Contains(t, out.String(), "5. Peer 5\n    192.168.3.6/32\n    "+keys[4]+"\n")
+		require.NotContains(t, out.String(), "6. Peer 6")
+
+		peer, action := press(picker, console.buttons[1].Data)
+		require.Equal(t, "Peer 2", peer.Name)
+		require.Equal(t, "remove", action)
+	})
+
+	t.Run("outdated buttons", func(t *testing.T) {
+		configManager, _ := newTestPickerConfig(t, 2)
+		old, err := newPeerPicker(configManager, "Pick a peer", "")
+		require.NoError(t, err)
+		old.send(console)
+		oldData := buttonData("Peer 1 - 192.168.3.2/32")
+
+		picker, err := newPeerPicker(configManager, "Pick a peer", "")
+		require.NoError(t, err)
+		require.NotEqual(t, old.session, picker.session)
+		picker.send(console)
+
+		// Buttons of another list have a different session token
+		peer, _ := press(picker, oldData)
+		require.Nil(t, peer)
+		require.Equal(t, "This list is outdated", lastLines(1)[0])
+		for _, data := range []string{"", "garbage", picker.session + "|page"} {
+			peer, _ = press(picker, data)
+			require.Nil(t, peer)
+			require.Equal(t, "This list is outdated", lastLines(1)[0])
+		}
+
+		console.read("Peer 1")
+		peer, _ = picker.handle(console)
+		require.Nil(t, peer)
+		require.Equal(t, "Please select a peer using buttons above", lastLines(1)[0])
+	})
+
+	t.Run("removed peers", func(t *testing.T) {
+		configManager, keys := newTestPickerConfig(t, 2)
+		picker, err := newPeerPicker(configManager, "Pick a peer", "")
+		require.NoError(t, err)
+		picker.send(console)
+		first := buttonData("Peer 1 - 192.168.3.2/32")
+		second := buttonData("Peer 2 - 192.168.3.3/32")
+
+		// The list is redrawn without the peer
+		require.NoError(t, configManager.RemovePeer(audit.Actor{}, keys[0]))
+		peer, _ := press(picker, first)
+		require.Nil(t, peer)
+		require.Equal(t, []string{
+			"This peer no longer exists",
+			"Pick a peer",
+			"  [#1] Peer 2 - 192.168.3.3/32",
+		}, lastLines(3))
+
+		require.NoError(t, configManager.RemovePeer(audit.Actor{}, keys[1]))
+		peer, _ = press(picker, second)
+		require.Nil(t, peer)
+		require.Equal(t, []string{"This peer no longer exists", "No peers found"}, lastLines(2))
+	})
+}
@@ -0,0 +1,63 @@
+package chat
+
+import (
+	"log"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+// changeDiff returns diff of the change returned by a dry run, so it could be passed to sendPreview.
+func changeDiff(change *wireguard.Change, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return change.Diff(), nil
+}
+
+// previewedChange keeps the change shown in the preview, so exactly that change is applied once confirmed.
+type previewedChange struct {
+	change *wireguard.Change
+	// Whether the preview was shown, it isn't kept when the bot restarts
+	shown bool
+}
+
+// keep remembers the change made in a dry run and returns its diff, so it could be passed to sendPreview.
+func (p *previewedChange) keep(change *wireguard.Change, err error) (string, error) {
+	p.change, p.shown = change, true
+	return changeDiff(change, err)
+}
+
+// outdated reports whether the change has to be previewed again, because the preview was lost on restart
+// or the configuration file was changed since it was shown. Failed previews are not shown again, applying
+// the change reports the actual error.
+func (p *previewedChange) outdated(conv Conversation, configManager *audit.ConfigManager) bool {
+	if !p.shown {
+		conv.Send(tr(conv, i18n.ReviewChangeAgain), nil)
+		return true
+	}
+	if p.change == nil || !configManager.ChangeOutdated(p.change) {
+		return false
+	}
+	conv.Send(tr(conv, i18n.ChangeOutdated), nil)
+	return true
+}
+
+// sendPreview shows the pending change before it is confirmed. The confirmation is still asked
+// if the change can't be previewed, applying it reports the actual error.
+func sendPreview(conv Conversation, preview string, err error) {
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.PreviewError), nil)
+		return
+	}
+	if preview == "" {
+		return
+	}
+	err = conv.SendFormatted((&render.Message{}).Pre(preview))
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,40 @@
+package chat
+
+import (
+	"log"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+)
+
+func NewRemovePeerCommand(configManager *audit.ConfigManager) *Wizard {
+	return &Wizard{
+		Name: "remove_peer",
+		Steps: []WizardStep{
+			&PeerStep{ConfigManager: configManager, Name: "peer", Text: i18n.SelectPeerRemove},
+		},
+		Confirmation: func(conv Conversation, values map[string]string) string {
+			return tr(conv, i18n.RemoveConfirmation, values["peer"], values["peerName"])
+		},
+		Preview: func(conv Conversation, values map[string]string) (string, error) {
+			if configManager.Batch(Actor(conv)) != nil {
+				// Staged changes are reviewed together
+				return "", nil
+			}
+			return changeDiff(configManager.PreviewRemovePeer(values["peer"]))
+		},
+		Finish: func(conv Conversation, values map[string]string) {
+			if batch := configManager.Batch(Actor(conv)); batch != nil {
+				stageChange(conv, batch, batch.RemovePeer(values["peer"]))
+				return
+			}
+			err := configManager.RemovePeer(Actor(conv), values["peer"])
+			if err != nil {
+				conv.Send(tr(conv, i18n.RemovePeerError), RemoveKeyboard)
+				log.Println(err)
+				return
+			}
+			conv.Send(tr(conv, i18n.PeerRemoved), RemoveKeyboard)
+		},
+	}
+}
@@ -0,0 +1,309 @@
+package chat
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+const backCommand = "/back"
+
+// WizardStep asks user for a single value of a Wizard.
+type WizardStep interface {
+	// Key is a name the value of the step is stored under in Wizard.Values
+	Key() string
+	// Prompt asks user for the value. It returns false if the value can't be
+	// entered at all, which finishes the wizard.
+	Prompt(w *Wizard, conv Conversation) bool
+	// Handle processes user input. It returns true once the value was stored.
+	Handle(w *Wizard, conv Conversation) bool
+}
+
+// Wizard is a Command which asks user for values step by step, and asks for
+// a confirmation before finishing. User can return to previous step with "Back" button.
+// Steps which values are already set are skipped.
+type Wizard struct {
+	// Name of the command, used to restore the wizard after restart
+	Name  string
+	Steps []WizardStep
+	// Confirmation returns text of the confirmation question. Wizard finishes without
+	// a confirmation if it isn't set.
+	Confirmation func(conv Conversation, values map[string]string) string
+	// Preview returns the change the wizard is about to make, e.g. a diff, which is shown
+	// before the confirmation question. It is optional.
+	Preview func(conv Conversation, values map[string]string) (string, error)
+	// Finish is called once all values are entered and confirmed
+	Finish func(conv Conversation, values map[string]string)
+	// Outdated reports whether the previewed change can't be confirmed anymore, e.g. because the configuration
+	// file was changed since. The preview and the confirmation are shown again then. It is optional.
+	Outdated func(conv Conversation, values map[string]string) bool
+	// Declined is called instead of reporting that the command was cancelled if the
+	// confirmation is declined. It is optional.
+	Declined func(conv Conversation)
+	Values   map[string]string
+	// Keys of values which were set before the wizard started, they are never asked for
+	preset     map[string]bool
+	step       int
+	confirming bool
+}
+
+func (w *Wizard) Start(conv Conversation) bool {
+	if w.Values == nil {
+		w.Values = map[string]string{}
+	}
+	w.preset = map[string]bool{}
+	for key := range w.Values {
+		w.preset[key] = true
+	}
+	w.step = -1
+	return w.next(conv)
+}
+
+// next moves wizard to the next step which value is not preset. It returns true if wizard has finished.
+func (w *Wizard) next(conv Conversation) bool {
+	w.step++
+	for w.step < len(w.Steps) && w.preset[w.Steps[w.step].Key()] {
+		w.step++
+	}
+	if w.step < len(w.Steps) {
+		return !w.Steps[w.step].Prompt(w, conv)
+	}
+	if w.Confirmation == nil {
+		w.Finish(conv, w.Values)
+		return true
+	}
+	w.confirming = true
+	w.sendConfirmation(conv)
+	return false
+}
+
+// back returns wizard to the previous step which value is not preset. It returns true if wizard has finished.
+func (w *Wizard) back(conv Conversation) bool {
+	prev := w.step - 1
+	for prev >= 0 && w.preset[w.Steps[prev].Key()] {
+		prev--
+	}
+	if prev < 0 {
+		conv.Send(tr(conv, i18n.FirstStep), nil)
+		return false
+	}
+	w.confirming = false
+	w.step = prev
+	return !w.Steps[w.step].Prompt(w, conv)
+}
+
+// canGoBack checks if there is a step to return to from the current one.
+func (w *Wizard) canGoBack() bool {
+	for prev := w.step - 1; prev >= 0; prev-- {
+		if !w.preset[w.Steps[prev].Key()] {
+			return true
+		}
+	}
+	return false
+}
+
+// keyboard returns keyboard with the given answers and a "Back" answer if it is applicable.
+func (w *Wizard) keyboard(conv Conversation, answers ...string) *Keyboard {
+	if w.canGoBack() {
+		answers = append(answers, tr(conv, i18n.Back))
+	}
+	if len(answers) == 0 {
+		return RemoveKeyboard
+	}
+	return &Keyboard{Answers: answers}
+}
+
+func (w *Wizard) State() *CommandState {
+	state := &CommandState{
+		Command:    w.Name,
+		Values:     map[string]string{},
+		Step:       w.step,
+		Confirming: w.confirming,
+	}
+	for key, value := range w.Values {
+		state.Values[key] = value
+	}
+	for key := range w.preset {
+		state.Preset = append(state.Preset, key)
+	}
+	sort.Strings(state.Preset)
+	return state
+}
+
+func (w *Wizard) Resume(conv Conversation, state *CommandState) bool {
+	w.Values = state.Values
+	if w.Values == nil {
+		w.Values = map[string]string{}
+	}
+	w.preset = map[string]bool{}
+	for _, key := range state.Preset {
+		w.preset[key] = true
+	}
+	w.step = state.Step
+	w.confirming = state.Confirming
+	if w.step < 0 || w.step > len(w.Steps) || (w.step == len(w.Steps) && !w.confirming) {
+		conv.Send(tr(conv, i18n.CommandNotResumed), nil)
+		return true
+	}
+	if w.confirming {
+		w.sendConfirmation(conv)
+		return false
+	}
+	return !w.Steps[w.step].Prompt(w, conv)
+}
+
+func (w *Wizard) sendConfirmation(conv Conversation) {
+	if w.Preview != nil {
+		preview, err := w.Preview(conv, w.Values)
+		sendPreview(conv, preview, err)
+	}
+	conv.Send(w.Confirmation(conv, w.Values), w.keyboard(conv, tr(conv, i18n.Yes), tr(conv, i18n.No)))
+}
+
+func (w *Wizard) HandleInput(conv Conversation) bool {
+	responseText := strings.TrimSpace(conv.Text())
+	if !conv.Pressed() && (responseText == tr(conv, i18n.Back) || responseText == backCommand) {
+		return w.back(conv)
+	}
+	if !w.confirming {
+		if w.Steps[w.step].Handle(w, conv) {
+			return w.next(conv)
+		}
+		return false
+	}
+
+	// Handle confirmaton
+	if conv.Pressed() {
+		conv.Answer(tr(conv, i18n.AnswerQuestionFirst))
+		return false
+	}
+	switch {
+	case strings.EqualFold(responseText, tr(conv, i18n.Yes)):
+		if w.Outdated != nil && w.Outdated(conv, w.Values) {
+			w.sendConfirmation(conv)
+			return false
+		}
+		w.Finish(conv, w.Values)
+		return true
+	case strings.EqualFold(responseText, tr(conv, i18n.No)):
+		if w.Declined != nil {
+			w.Declined(conv)
+			return true
+		}
+		conv.Send(tr(conv, i18n.CommandCancelled), RemoveKeyboard)
+		return true
+	default:
+		conv.Send(tr(conv, i18n.AnswerYesOrNo, tr(conv, i18n.Yes), tr(conv, i18n.No)), nil)
+		return false
+	}
+}
+
+// ParseFunc validates text input and returns value to be stored.
+type ParseFunc func(input string) (string, error)
+
+// TextStep asks user to enter text, optionally offering predefined answers.
+type TextStep struct {
+	Name    string
+	Text    i18n.Key
+	Buttons []string
+	// Optional parser, error returned by it is shown to user. Errors which are
+	// i18n.Key are shown in the user's language.
+	Parse ParseFunc
+}
+
+func (s *TextStep) Key() string {
+	return s.Name
+}
+
+func (s *TextStep) Prompt(w *Wizard, conv Conversation) bool {
+	conv.Send(tr(conv, s.Text), w.keyboard(conv, s.Buttons...))
+	return true
+}
+
+func (s *TextStep) Handle(w *Wizard, conv Conversation) bool {
+	if conv.Pressed() {
+		conv.Answer(tr(conv, i18n.ListOutdated))
+		return false
+	}
+	value := strings.TrimSpace(conv.Text())
+	if value == "" {
+		return false
+	}
+	if s.Parse != nil {
+		var err error
+		value, err = s.Parse(value)
+		if err != nil {
+			var key i18n.Key
+			if errors.As(err, &key) {
+				conv.Send(tr(conv, key), nil)
+			} else {
+				conv.Send(err.Error(), nil)
+			}
+			return false
+		}
+	}
+	w.Values[s.Name] = value
+	return true
+}
+
+// PeerStep asks user to pick a peer. Public key of the peer is stored under
+// the step name, and peer name is stored under the step name with "Name" suffix.
+type PeerStep struct {
+	*audit.ConfigManager
+	Name   string
+	Text   i18n.Key
+	picker *peerPicker
+}
+
+func (s *PeerStep) Key() string {
+	return s.Name
+}
+
+func (s *PeerStep) Prompt(w *Wizard, conv Conversation) bool {
+	picker, err := newPeerPicker(s.ConfigManager, tr(conv, s.Text), "")
+	if err != nil {
+		log.Println(err)
+		conv.Send(tr(conv, i18n.PeerListError), RemoveKeyboard)
+		return false
+	}
+	if len(picker.peers) == 0 {
+		conv.Send(tr(conv, i18n.NoPeers), RemoveKeyboard)
+		return false
+	}
+	s.picker = picker
+	picker.send(conv)
+	return true
+}
+
+func (s *PeerStep) Handle(w *Wizard, conv Conversation) bool {
+	peer, _ := s.picker.handle(conv)
+	if peer == nil {
+		return false
+	}
+	w.Values[s.Name] = peer.PublicKey
+	w.Values[s.Name+"Name"] = peer.Name
+	return true
+}
+
+// ParsePublicKey validates WireGuard public key entered by user.
+func ParsePublicKey(input string) (string, error) {
+	key, err := wgtypes.ParseKey(input)
+	if err != nil {
+		return "", i18n.InvalidPublicKey
+	}
+	return key.String(), nil
+}
+
+// ParsePeerName validates peer name entered by user, it has to fit a single comment line of the configuration.
+func ParsePeerName(input string) (string, error) {
+	if wireguard.ValidatePeerName(input) != nil {
+		return "", i18n.InvalidPeerName
+	}
+	return input, nil
+}
@@ -0,0 +1,123 @@
+package chat
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const backText = "« Back"
+
+func newTestWizard(finished *map[string]string) *Wizard {
+	return &Wizard{
+		Steps: []WizardStep{
+			&TextStep{Name: "first", Text: "Enter first"},
+			&TextStep{Name: "second", Text: "Enter second", Parse: func(input string) (string, error) {
+				if input == "invalid" {
+					return "", errors.New("Invalid value")
+				}
+				return input, nil
+			}},
+			&TextStep{Name: "third", Text: "Enter third", Buttons: []string{"A", "B"}},
+		},
+		Confirmation: func(conv Conversation, values map[string]string) string {
+			return "Confirm " + values["first"] + values["second"] + values["third"]
+		},
+		Finish: func(conv Conversation, values map[string]string) {
+			*finished = values
+		},
+	}
+}
+
+func TestWizard(t *testing.T) {
+	out := &bytes.Buffer{}
+	console := &Console{Out: out}
+	start := func(w *Wizard) bool {
+		console.read("/test")
+		return w.Start(console)
+	}
+	input := func(w *Wizard, text string) bool {
+		console.read(text)
+		return w.HandleInput(console)
+	}
+	// lastMessage returns the latest message, skipping answers and buttons listed below it
+	lastMessage := func() string {
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		for i := len(lines) - 1; i > 0 && strings.HasPrefix(lines[i], "  "); i-- {
+			lines = lines[:i]
+		}
+		return lines[len(lines)-1]
+	}
+
+	t.Run("all steps and confirmation", func(t *testing.T) {
+		var finished map[string]string
+		w := newTestWizard(&finished)
+		require.False(t, start(w))
+		require.Equal(t, "Enter first", lastMessage())
+		require.False(t, input(w, "1"))
+		require.False(t, input(w, "invalid"))
+		require.Equal(t, "Invalid value", lastMessage())
+		require.False(t, input(w, "2"))
+		require.False(t, input(w, "3"))
+		require.Equal(t, "Confirm 123", lastMessage())
+		require.False(t, input(w, "maybe"))
+		require.Nil(t, finished)
+		require.True(t, input(w, "Yes"))
+		require.Equal(t, map[string]string{"first": "1", "second": "2", "third": "3"}, finished)
+	})
+
+	t.Run("back", func(t *testing.T) {
+		var finished map[string]string
+		w := newTestWizard(&finished)
+		start(w)
+		require.False(t, input(w, backText))
+		require.Equal(t, "This is the first step, use /cancel to cancel the command", lastMessage())
+		input(w, "1")
+		input(w, "2")
+		input(w, "3")
+		require.False(t, input(w, backText))
+		require.Equal(t, "Enter third", lastMessage())
+		require.False(t, input(w, backCommand))
+		require.Equal(t, "Enter second", lastMessage())
+		input(w, "two")
+		input(w, "three")
+		require.True(t, input(w, "yes"))
+		require.Equal(t, map[string]string{"first": "1", "second": "two", "third": "three"}, finished)
+	})
+
+	t.Run("preset values are skipped", func(t *testing.T) {
+		var finished map[string]string
+		w := newTestWizard(&finished)
+		w.Values = map[string]string{"first": "1", "third": "3"}
+		start(w)
+		require.Equal(t, "Enter second", lastMessage())
+		require.False(t, input(w, backText))
+		require.Equal(t, "This is the first step, use /cancel to cancel the command", lastMessage())
+		input(w, "2")
+		require.Equal(t, "Confirm 123", lastMessage())
+		require.True(t, input(w, "Yes"))
+		require.Equal(t, "2", finished["second"])
+	})
+
+	t.Run("declined confirmation", func(t *testing.T) {
+		var finished map[string]string
+		w := newTestWizard(&finished)
+		w.Values = map[string]string{"first": "1", "second": "2", "third": "3"}
+		require.False(t, start(w))
+		require.True(t, input(w, "No"))
+		require.Nil(t, finished)
+	})
+
+	t.Run("no confirmation", func(t *testing.T) {
+		var finished map[string]string
+		w := newTestWizard(&finished)
+		w.Confirmation = nil
+		w.Values = map[string]string{"first": "1", "second": "2"}
+		start(w)
+		require.True(t, input(w, "3"))
+		require.Equal(t, "3", finished["third"])
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+)
+
+type checkResult struct {
+	Name     string
+	Problems []error
+	// Problems which don't keep the bot from working, e.g. the ones /doctor fixes
+	Warnings []error
+	// Reason why the check was not performed, if it was skipped
+	Skipped string
+}
+
+// checkBotConfig checks that bot configuration is complete and consistent.
+func checkBotConfig(config *Config) []error {
+	problems := []error{}
+	if config.BotToken == "" {
+		problems = append(problems, errors.New("BotToken is not set"))
+	}
+	if len(config.UserIDs) == 0 {
+		problems = append(problems, errors.New("UserIDs is empty, nobody would be able to use the bot"))
+	}
+	for _, groupID := range config.GroupIDs {
+		if groupID >= 0 {
+			problems = append(problems, fmt.Errorf("GroupIDs contains %d, group chat IDs are negative", groupID))
+		}
+	}
+	if config.ConfigFilePath == "" {
+		problems = append(problems, errors.New("ConfigFilePath is not set"))
+	}
+	if config.Hostname == "" {
+		problems = append(problems, errors.New("Hostname is not set, it is needed for client configurations"))
+	}
+	if !config.UseStub && config.InterfaceName == "" {
+		problems = append(problems, errors.New("InterfaceName is not set"))
+	}
+	if _, err := wireguard.ParseStrategy(config.AddressAllocation); err != nil {
+		problems = append(problems, fmt.Errorf("AddressAllocation is not valid: %w", err))
+	}
+	if config.AccessRequestTTL <= 0 {
+		problems = append(problems, errors.New("AccessRequestTTL must be positive"))
+	}
+	if config.ConversationTimeout <= 0 {
+		problems = append(problems, errors.New("ConversationTimeout must be positive"))
+	}
+	if _, err := render.ParseMode(config.MessageFormat); err != nil {
+		problems = append(problems, err)
+	}
+	if config.AuditLogPath == "" && config.StateDir == "" {
+		problems = append(problems, errors.New("AuditLogPath and StateDir are not set, changes wouldn't be audited"))
+	}
+	if config.LogThreadID != 0 && config.LogChatID == 0 {
+		problems = append(problems, errors.New("LogThreadID is set without LogChatID"))
+	}
+	if config.WebhookURL != "" {
+		webhookURL, err := url.Parse(config.WebhookURL)
+		if err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" {
+			problems = append(problems, fmt.Errorf("WebhookURL %q is not a valid HTTPS URL", config.WebhookURL))
+		}
+	}
+	if (config.WebhookTLSCert == "") != (config.WebhookTLSKey == "") {
+		problems = append(problems, errors.New("WebhookTLSCert and WebhookTLSKey must be set together"))
+	}
+	return problems
+}
+
+// runChecks validates bot configuration, server configuration file and Wireguard interface.
+func runChecks(config *Config) []checkResult {
+	results := []checkResult{
+		{Name: "Bot configuration", Problems: checkBotConfig(config)},
+	}
+
+	wgConfig := checkResult{Name: "WireGuard configuration " + config.ConfigFilePath}
+	if config.ConfigFilePath == "" {
+		wgConfig.Skipped = "ConfigFilePath is not set"
+	} else {
+		configManager := &wireguard.ConfigManager{
+			ConfigFilePath: config.ConfigFilePath,
+			Reserved:       config.ReservedRanges,
+		}
+		wgConfig.Problems, wgConfig.Warnings = configManager.Validate()
+	}
+	results = append(results, wgConfig)
+
+	iface := checkResult{Name: "Interface"}
+	if config.InterfaceName != "" {
+		iface.Name += " " + config.InterfaceName
+	}
+	if config.UseStub {
+		iface.Skipped = "stub process manager is used"
+	} else if config.InterfaceName != "" {
+		_, err := net.InterfaceByName(config.InterfaceName)
+		if err != nil {
+			iface.Problems = append(iface.Problems, fmt.Errorf("interface doesn't exist: %w", err))
+		}
+	}
+	return append(results, iface)
+}
+
+// printReport writes readable check results. It returns false if any problems were found, warnings
+// are reported but don't count.
+func printReport(w io.Writer, results []checkResult) bool {
+	ok := true
+	for _, result := range results {
+		switch {
+		case result.Skipped != "":
+			fmt.Fprintf(w, "%s: skipped, %s\n", result.Name, result.Skipped)
+			continue
+		case len(result.Problems) == 0 && len(result.Warnings) == 0:
+			fmt.Fprintf(w, "%s: OK\n", result.Name)
+		case len(result.Problems) == 0:
+			fmt.Fprintf(w, "%s: %d warning(s)\n", result.Name, len(result.Warnings))
+		case len(result.Warnings) == 0:
+			ok = false
+			fmt.Fprintf(w, "%s: %d problem(s)\n", result.Name, len(result.Problems))
+		default:
+			ok = false
+			fmt.Fprintf(w, "%s: %d problem(s), %d warning(s)\n", result.Name, len(result.Problems), len(result.Warnings))
+		}
+		for _, problem := range result.Problems {
+			fmt.Fprintf(w, "  - %s\n", problem)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(w, "  - warning: %s\n", warning)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBotConfig(t *testing.T) {
+	config := &Config{
+		ConfigFilePath:      "/etc/wireguard/wg0.conf",
+		Hostname:            "example.com",
+		UseStub:             true,
+		BotToken:            "xxx",
+		UserIDs:             []int64{111},
+		AccessRequestTTL:    time.Hour,
+		ConversationTimeout: time.Minute,
+		MessageFormat:       "MarkdownV2",
+		StateDir:            "/var/lib/simple-wg-telegram-bot",
+	}
+	require.Empty(t, checkBotConfig(config))
+
+	config.BotToken = ""
+	config.UserIDs = nil
+	config.GroupIDs = []int64{-100123, 222}
+	config.WebhookURL = "http://example.com"
+	config.WebhookTLSCert = "cert.pem"
+	config.MessageFormat = "Markdown"
+	config.AddressAllocation = "highest"
+	config.StateDir = ""
+	problems := checkBotConfig(config)
+	require.Len(t, problems, 8)
+	require.EqualError(t, problems[0], "BotToken is not set")
+	require.EqualError(t, problems[1], "UserIDs is empty, nobody would be able to use the bot")
+	require.EqualError(t, problems[2], "GroupIDs contains 222, group chat IDs are negative")
+	require.EqualError(t, problems[3], `AddressAllocation is not valid: unknown allocation strategy "highest", it should be lowest, sequential or random`)
+	require.EqualError(t, problems[4], "unknown message format Markdown, should be MarkdownV2 or HTML")
+	require.EqualError(t, problems[5], "AuditLogPath and StateDir are not set, changes wouldn't be audited")
+	require.EqualError(t, problems[6], `WebhookURL "http://example.com" is not a valid HTTPS URL`)
+	require.EqualError(t, problems[7], "WebhookTLSCert and WebhookTLSKey must be set together")
+}
+
+func TestReport(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(configPath, []byte("[Interface]\nAddress = 10.0.0.1/24\nPrivateKey = xxx\n"), 0600))
+	config := &Config{
+		ConfigFilePath:      configPath,
+		Hostname:            "example.com",
+		UseStub:             true,
+		BotToken:            "xxx",
+		UserIDs:             []int64{111},
+		AccessRequestTTL:    time.Hour,
+		ConversationTimeout: time.Minute,
+		MessageFormat:       "HTML",
+		AuditLogPath:        filepath.Join(t.TempDir(), "audit.jsonl"),
+		ReservedRanges:      []string{".1-.20", ".250-.300"},
+	}
+
+	out := &bytes.Buffer{}
+	require.False(t, printReport(out, runChecks(config)))
+	require.Regexp(t, `^Bot configuration: OK
+WireGuard configuration .*wg0.conf: 2 problem\(s\)
+  - interface private key is not valid: .*
+  - reserved range ".250-.300" is not valid: .*
+Interface: skipped, stub process manager is used
+$`, out.String())
+}
+
+func TestReportWarnings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(configPath, []byte(`[Interface]
+Address    = 192.168.3.1/24
+PrivateKey = 4LCwyNHhuF91itgM8wdCNRCPQYcn6b+bHKJsaNrSTXU=
+
+# Alice
+[Peer]
+PublicKey  = Dc6HJYJHhm//iEeQDnXDPPtQ1u9slnkDaflP0ar4ISE=
+AllowedIPs = 192.168.3.2/32
+
+# Bob
+[Peer]
+PublicKey  = Dq7pWRg3Us+s8KxsWbRCdSEePGda1bPDqsoEvygyjhk=
+AllowedIPs = 192.168.3.2/32
+`), 0600))
+	config := &Config{
+		ConfigFilePath:      configPath,
+		Hostname:            "example.com",
+		UseStub:             true,
+		BotToken:            "xxx",
+		UserIDs:             []int64{111},
+		AccessRequestTTL:    time.Hour,
+		ConversationTimeout: time.Minute,
+		MessageFormat:       "HTML",
+		AuditLogPath:        filepath.Join(t.TempDir(), "audit.jsonl"),
+	}
+
+	// Problems of peers are reported, but don't keep the bot from starting, so /doctor could fix them
+	out := &bytes.Buffer{}
+	require.True(t, printReport(out, runChecks(config)))
+	require.Regexp(t, `^Bot configuration: OK
+WireGuard configuration .*wg0.conf: 1 warning\(s\)
+  - warning: AllowedIPs 192.168.3.2/32 of peer "Bob" overlap with 192.168.3.2/32 of peer "Alice"
+Interface: skipped, stub process manager is used
+$`, out.String())
+
+	config.ReservedRanges = []string{"10.0.0.1"}
+	out.Reset()
+	require.False(t, printReport(out, runChecks(config)))
+	require.Contains(t, out.String(), "wg0.conf: 1 problem(s), 1 warning(s)\n")
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"github.com/rem11/simple-wg-telegram-bot/telegram"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix of environment variables overriding configuration, e.g. WGBOT_BOT_TOKEN for BotToken
+const envPrefix = "WGBOT_"
+
+// Name of the bot token file in $CREDENTIALS_DIRECTORY, which is used if neither
+// BotToken nor BotTokenFile is set
+const credentialsBotToken = "bot_token"
+
+type Config struct {
+	ConfigFilePath string
+	Hostname       string
+	DNS            string
+	UseStub        bool
+	InterfaceName  string
+	// Ranges of the server subnet which aren't given to new peers, e.g. ".1-.20" for static devices
+	ReservedRanges []string
+	// Which of the free addresses new peers get: lowest, sequential or random
+	AddressAllocation string
+	BotToken          string
+	// File to read bot token from, relative paths are resolved against $CREDENTIALS_DIRECTORY
+	BotTokenFile string
+	UserIDs      []int64
+	// Group chats the bot works in, the bot works in any group it is added to if empty
+	GroupIDs []int64
+	// Directory to keep bot state (e.g. pending access requests) in
+	StateDir         string
+	AccessRequestTTL time.Duration
+	// Path to audit log, defaults to audit.jsonl in StateDir
+	AuditLogPath string
+	// Channel or group to mirror events to, and optional forum topic in it
+	LogChatID   int64
+	LogThreadID int
+	// Unfinished commands are cancelled after this period of inactivity
+	ConversationTimeout time.Duration
+	// Formatting of messages with client configs, MarkdownV2 or HTML
+	MessageFormat string
+	// Updates are received via webhook instead of long polling if WebhookURL is set
+	WebhookURL     string
+	WebhookListen  string
+	WebhookSecret  string
+	WebhookTLSCert string
+	WebhookTLSKey  string
+}
+
+// envName returns name of environment variable for the config field, e.g. WGBOT_USER_IDS for UserIDs.
+func envName(field string) string {
+	runes := []rune(field)
+	name := strings.Builder{}
+	name.WriteString(envPrefix)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			// Start of a word after lowercase letter, or the last letter of an abbreviation
+			// which starts a new word, except for a plural suffix, e.g. "TLSCert" and "IDs"
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			plural := i+1 < len(runes) && runes[i+1] == 's' && (i+2 == len(runes) || unicode.IsUpper(runes[i+2]))
+			if !unicode.IsUpper(prev) || (nextLower && !plural) {
+				name.WriteRune('_')
+			}
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
+
+// configKeys returns names of all configuration keys.
+func configKeys() []string {
+	configType := reflect.TypeOf(Config{})
+	keys := make([]string, configType.NumField())
+	for i := range keys {
+		keys[i] = configType.Field(i).Name
+	}
+	return keys
+}
+
+// formatValue converts value decoded from YAML or TOML to the ini format.
+func formatValue(key string, value interface{}) (string, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("%s must be a value, not a section", key)
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// loadConfigFile reads configuration file into a key-value map. YAML and TOML formats are
+// detected by the file extension, any other file is read as ini.
+func loadConfigFile(configPath string) (map[string]string, error) {
+	values := map[string]string{}
+	var decoded map[string]interface{}
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".yaml", ".yml":
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		err = yaml.Unmarshal(data, &decoded)
+		if err != nil {
+			return nil, err
+		}
+	case ".toml":
+		_, err := toml.DecodeFile(configPath, &decoded)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		cfgFile, err := ini.Load(configPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range cfgFile.Section("").Keys() {
+			values[key.Name()] = key.Value()
+		}
+		return values, nil
+	}
+
+	for key, value := range decoded {
+		str, err := formatValue(key, value)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = str
+	}
+	return values, nil
+}
+
+// readSecret reads secret from file, resolving relative path against $CREDENTIALS_DIRECTORY.
+func readSecret(path string) (string, error) {
+	credentialsDir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if !filepath.IsAbs(path) && credentialsDir != "" {
+		path = filepath.Join(credentialsDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolveBotToken reads bot token from BotTokenFile, or from the bot_token credential
+// if neither BotToken nor BotTokenFile is set.
+func resolveBotToken(config *Config) error {
+	if config.BotToken != "" && config.BotTokenFile != "" {
+		return errors.New("only one of BotToken and BotTokenFile can be set")
+	}
+	if config.BotToken != "" {
+		return nil
+	}
+	tokenFile := config.BotTokenFile
+	if tokenFile == "" {
+		credentialsDir := os.Getenv("CREDENTIALS_DIRECTORY")
+		if credentialsDir == "" {
+			return nil
+		}
+		tokenFile = filepath.Join(credentialsDir, credentialsBotToken)
+		if _, err := os.Stat(tokenFile); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+	token, err := readSecret(tokenFile)
+	if err != nil {
+		return fmt.Errorf("error reading bot token: %w", err)
+	}
+	config.BotToken = token
+	return nil
+}
+
+// readConfig loads configuration. Values are taken from environment variables first,
+// then from the configuration file, then defaults are used. Configuration file is optional
+// if everything is set with environment variables.
+func readConfig(configPath string) (*Config, error) {
+	values := map[string]string{}
+	if configPath != "" {
+		var err error
+		values, err = loadConfigFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("error loading bot configuration: %w", err)
+		}
+	}
+
+	for _, key := range configKeys() {
+		if value, ok := os.LookupEnv(envName(key)); ok {
+			values[key] = value
+		}
+	}
+
+	cfgFile := ini.Empty()
+	for key, value := range values {
+		_, err := cfgFile.Section("").NewKey(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("error loading bot configuration: %w", err)
+		}
+	}
+
+	config := &Config{
+		AccessRequestTTL:    24 * time.Hour,
+		ConversationTimeout: telegram.DefaultConversationTimeout,
+		WebhookListen:       ":8443",
+		MessageFormat:       string(render.MarkdownV2),
+	}
+
+	err := cfgFile.MapTo(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing bot configuration: %w", err)
+	}
+
+	err = resolveBotToken(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func statePath(config *Config, fileName string) string {
+	if config.StateDir == "" {
+		return ""
+	}
+	return filepath.Join(config.StateDir, fileName)
+}
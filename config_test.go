@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestEnvName(t *testing.T) {
+	names := map[string]string{}
+	for _, key := range configKeys() {
+		names[key] = envName(key)
+	}
+	require.Equal(t, "WGBOT_CONFIG_FILE_PATH", names["ConfigFilePath"])
+	require.Equal(t, "WGBOT_DNS", names["DNS"])
+	require.Equal(t, "WGBOT_BOT_TOKEN_FILE", names["BotTokenFile"])
+	require.Equal(t, "WGBOT_USER_IDS", names["UserIDs"])
+	require.Equal(t, "WGBOT_ACCESS_REQUEST_TTL", names["AccessRequestTTL"])
+	require.Equal(t, "WGBOT_LOG_CHAT_ID", names["LogChatID"])
+	require.Equal(t, "WGBOT_WEBHOOK_URL", names["WebhookURL"])
+	require.Equal(t, "WGBOT_WEBHOOK_TLS_CERT", names["WebhookTLSCert"])
+}
+
+func TestReadConfig(t *testing.T) {
+	expected := &Config{
+		ConfigFilePath:      "/etc/wireguard/wg0.conf",
+		Hostname:            "test.example.com",
+		UseStub:             true,
+		BotToken:            "xxx",
+		UserIDs:             []int64{111, 222},
+		AccessRequestTTL:    time.Hour,
+		ConversationTimeout: telegram.DefaultConversationTimeout,
+		MessageFormat:       "MarkdownV2",
+		WebhookListen:       ":8443",
+	}
+
+	t.Run("formats", func(t *testing.T) {
+		dir := t.TempDir()
+		files := []string{
+			writeFile(t, dir, "bot.conf", `ConfigFilePath = /etc/wireguard/wg0.conf
+Hostname = test.example.com
+UseStub = true
+BotToken = xxx
+UserIDs = 111, 222
+AccessRequestTTL = 1h
+`),
+			writeFile(t, dir, "bot.yaml", `ConfigFilePath: /etc/wireguard/wg0.conf
+Hostname: test.example.com
+UseStub: true
+BotToken: xxx
+UserIDs: [111, 222]
+AccessRequestTTL: 1h
+`),
+			writeFile(t, dir, "bot.toml", `ConfigFilePath = "/etc/wireguard/wg0.conf"
+Hostname = "test.example.com"
+UseStub = true
+BotToken = "xxx"
+UserIDs = [111, 222]
+AccessRequestTTL = "1h"
+`),
+		}
+		for _, file := range files {
+			config, err := readConfig(file)
+			require.NoError(t, err, file)
+			require.Equal(t, expected, config, file)
+		}
+	})
+
+	t.Run("sections are not allowed in YAML", func(t *testing.T) {
+		file := writeFile(t, t.TempDir(), "bot.yml", "Bot:\n  Token: xxx\n")
+		_, err := readConfig(file)
+		require.EqualError(t, err, "error loading bot configuration: Bot must be a value, not a section")
+	})
+
+	t.Run("environment variables override config file", func(t *testing.T) {
+		file := writeFile(t, t.TempDir(), "bot.conf", "Hostname = test.example.com\nBotToken = xxx\nUserIDs = 111\n")
+		t.Setenv("WGBOT_BOT_TOKEN", "yyy")
+		t.Setenv("WGBOT_USER_IDS", "333,444")
+		t.Setenv("WGBOT_CONVERSATION_TIMEOUT", "5m")
+		config, err := readConfig(file)
+		require.NoError(t, err)
+		require.Equal(t, "test.example.com", config.Hostname)
+		require.Equal(t, "yyy", config.BotToken)
+		require.Equal(t, []int64{333, 444}, config.UserIDs)
+		require.Equal(t, 5*time.Minute, config.ConversationTimeout)
+	})
+
+	t.Run("config file is optional", func(t *testing.T) {
+		t.Setenv("WGBOT_BOT_TOKEN", "yyy")
+		config, err := readConfig("")
+		require.NoError(t, err)
+		require.Equal(t, "yyy", config.BotToken)
+		require.Equal(t, 24*time.Hour, config.AccessRequestTTL)
+	})
+}
+
+func TestBotTokenFile(t *testing.T) {
+	t.Run("absolute path", func(t *testing.T) {
+		tokenFile := writeFile(t, t.TempDir(), "token", "xxx\n")
+		config, err := readConfig(writeFile(t, t.TempDir(), "bot.conf", "BotTokenFile = "+tokenFile+"\n"))
+		require.NoError(t, err)
+		require.Equal(t, "xxx", config.BotToken)
+	})
+
+	t.Run("path relative to credentials directory", func(t *testing.T) {
+		credentialsDir := t.TempDir()
+		writeFile(t, credentialsDir, "token", "xxx")
+		t.Setenv("CREDENTIALS_DIRECTORY", credentialsDir)
+		t.Setenv("WGBOT_BOT_TOKEN_FILE", "token")
+		config, err := readConfig("")
+		require.NoError(t, err)
+		require.Equal(t, "xxx", config.BotToken)
+	})
+
+	t.Run("default credential", func(t *testing.T) {
+		credentialsDir := t.TempDir()
+		writeFile(t, credentialsDir, "bot_token", "xxx")
+		t.Setenv("CREDENTIALS_DIRECTORY", credentialsDir)
+		config, err := readConfig("")
+		require.NoError(t, err)
+		require.Equal(t, "xxx", config.BotToken)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("WGBOT_BOT_TOKEN_FILE", filepath.Join(t.TempDir(), "token"))
+		_, err := readConfig("")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("both token and file are set", func(t *testing.T) {
+		t.Setenv("WGBOT_BOT_TOKEN", "xxx")
+		t.Setenv("WGBOT_BOT_TOKEN_FILE", "token")
+		_, err := readConfig("")
+		require.EqualError(t, err, "only one of BotToken and BotTokenFile can be set")
+	})
+}
@@ -0,0 +1,201 @@
+package i18n
+
+var german = map[Key]string{
+	CommandAddPeer:       "Neuen Peer zur Serverkonfiguration hinzufügen",
+	CommandRemovePeer:    "Peer aus der Serverkonfiguration entfernen",
+	CommandClientConfig:  "Clientkonfiguration eines Peers abrufen",
+	CommandFind:          "Peers nach Name, öffentlichem Schlüssel, IP-Adresse oder Metadaten suchen",
+	CommandAudit:         "Letzte Konfigurationsänderungen anzeigen",
+	CommandIPAM:          "Auslastung des Adresspools und die nächste Adresse anzeigen",
+	CommandDoctor:        "Serverkonfiguration prüfen und Probleme beheben",
+	CommandBatch:         "Änderungen sammeln, um sie auf einmal anzuwenden",
+	CommandCommit:        "Gesammelte Änderungen anwenden",
+	CommandAbort:         "Gesammelte Änderungen verwerfen",
+	CommandImport:        "Peers aus einer CSV- oder JSON-Datei importieren",
+	CommandExport:        "Liste der Peers als CSV- oder JSON-Datei exportieren",
+	CommandInvite:        "Einladungslink zum Hinzufügen eines Peers erstellen",
+	CommandInvites:       "Aktive Einladungslinks anzeigen",
+	CommandRevokeInvite:  "Einladungslink widerrufen",
+	CommandCancel:        "Aktuellen Befehl abbrechen",
+	CommandRequestAccess: "VPN-Zugang für dein Gerät anfordern",
+	CommandLanguage:      "Sprache des Bots ändern",
+
+	NoActiveCommand:          "Es gibt keinen aktiven Befehl zum Abbrechen",
+	CommandCancelled:         "Befehl wurde abgebrochen",
+	PreviousCommandCancelled: "Vorheriger Befehl wurde abgebrochen",
+	CommandTimedOut:          "Befehl wurde wegen Inaktivität abgebrochen",
+	CommandInterrupted:       "Dein Befehl wurde durch einen Neustart des Bots unterbrochen, bitte starte ihn erneut",
+	CommandResumed:           "Der Bot wurde neu gestartet, machen wir dort weiter, wo wir aufgehört haben",
+	CommandNotResumed:        "Der Befehl kann nicht fortgesetzt werden, bitte starte ihn erneut",
+	FirstStep:                "Das ist der erste Schritt, benutze /cancel, um den Befehl abzubrechen",
+	Yes:                      "Ja",
+	No:                       "Nein",
+	Back:                     "« Zurück",
+	AnswerQuestionFirst:      "Bitte beantworte zuerst die Frage",
+	AnswerYesOrNo:            "Bitte antworte mit „%s“ oder „%s“",
+	InvalidPublicKey:         "Der öffentliche Schlüssel ist ungültig, bitte versuche es erneut",
+	InvalidPeerName:          "Der Name muss eine einzelne Zeile ohne Sonderzeichen sein, bitte versuche es erneut",
+	UnknownCommand:           "Unbekannter Befehl, verfügbare Befehle: %s",
+
+	ListOutdated:        "Diese Liste ist veraltet",
+	PeerListError:       "Unerwarteter Fehler beim Abrufen der Peer-Liste",
+	NoPeers:             "Keine Peers in der Konfiguration gefunden",
+	NoPeersFound:        "Keine Peers gefunden",
+	SelectPeer:          "Bitte wähle einen Peer mit den Schaltflächen oben",
+	PeerSelected:        "%s\nAusgewählt: %s",
+	PeerGone:            "Dieser Peer existiert nicht mehr",
+	PageOf:              "%s (Seite %d von %d)",
+	PreviousPage:        "« Zurück",
+	NextPage:            "Weiter »",
+	FindUsage:           "Verwendung: /find <Name, Anfang des öffentlichen Schlüssels, IP-Adresse oder Metadaten>",
+	PeersMatching:       "Peers passend zu „%s“",
+	NoPeersMatching:     "Keine Peers passend zu „%s“ gefunden",
+	ActionConfig:        "Konfiguration",
+	ActionRemove:        "Entfernen",
+	ActionDisable:       "Sperren",
+	ActionEnable:        "Entsperren",
+	DisabledPeer:        "%s (gesperrt)",
+	PeerDisabled:        "%s ist gesperrt, die Adresse bleibt reserviert und der Peer kann wieder entsperrt werden",
+	PeerEnabled:         "%s ist entsperrt",
+	DisablePeerError:    "Unerwarteter Fehler beim Sperren oder Entsperren des Peers",
+	RemovalCancelled:    "Entfernen abgebrochen",
+	DisableConfirmation: "%s sperren?",
+	EnableConfirmation:  "%s entsperren?",
+	ToggleCancelled:     "Nichts wurde geändert",
+	ClientConfigErr:     "Unerwarteter Fehler beim Abrufen der Clientkonfiguration des Peers",
+	SelectPeerConfig:    "Wähle einen Peer, um seine Clientkonfiguration anzuzeigen",
+
+	ConfigInterface:  "Interface",
+	ConfigAddress:    "Adresse",
+	ConfigDNS:        "DNS",
+	ConfigPeer:       "Peer",
+	ConfigPublicKey:  "Öffentlicher Schlüssel",
+	ConfigAllowedIPs: "Erlaubte IPs",
+	ConfigEndpoint:   "Endpunkt",
+	ConfigTemplate:   "Konfigurationsvorlage",
+
+	PoolTitle:     "Adresspool",
+	PoolSubnet:    "Subnetz",
+	PoolUsed:      "Belegt",
+	PoolReserved:  "Reserviert",
+	PoolFree:      "Frei",
+	PoolNext:      "Nächste Adresse",
+	PoolExhausted: "keine freien Adressen mehr",
+	PoolRandom:    "zufällig, z. B.",
+	PoolError:     "Unerwarteter Fehler beim Lesen des Adresspools",
+
+	EnterPublicKey: "Gib den öffentlichen Schlüssel des neuen Peers ein",
+	EnterPeerName:  "Gib den Namen des Peers ein",
+	AddConfirmation: "Bist du sicher, dass du einen neuen Peer hinzufügen möchtest?\n" +
+		"Öffentlicher Schlüssel: %s\n" +
+		"Name: %s",
+	AddPeerError:     "Unerwarteter Fehler beim Hinzufügen des Peers",
+	PeerAdded:        "Peer wurde erfolgreich hinzugefügt! Konfiguration unten.",
+	SelectPeerRemove: "Wähle den Peer, der entfernt werden soll",
+	RemoveConfirmation: "Bist du sicher, dass du den Peer entfernen möchtest?\n" +
+		"Öffentlicher Schlüssel: %s\n" +
+		"Name: %s",
+	RemovePeerError:    "Unerwarteter Fehler beim Entfernen des Peers",
+	PeerRemoved:        "Peer wurde erfolgreich entfernt!",
+	AddPeerConfigError: "Die Serverkonfiguration hat Probleme, wegen derer keine Adresse für den Peer gewählt werden kann, verwende /doctor, um sie anzuzeigen und zu beheben",
+	PreviewError:       "Die Vorschau der Änderung konnte nicht erstellt werden, sie wird wahrscheinlich fehlschlagen",
+	ChangeOutdated:     "Die Konfigurationsdatei wurde seit der Vorschau geändert, bitte prüfe die Änderung erneut",
+	ReviewChangeAgain:  "Bitte prüfe die Änderung noch einmal, bevor sie angewendet wird",
+
+	AuditUsage: "Verwendung: /audit [N] [user=<ID|Benutzername>] [op=<Operation>] [key=<Anfang des öffentlichen Schlüssels>] [result=<success|failure|rolled_back>]",
+	AuditError: "Unerwarteter Fehler beim Lesen des Audit-Logs",
+	AuditEmpty: "Keine Einträge im Audit-Log gefunden",
+
+	InviteUsage: "Verwendung: /invite [Anzahl] [Gültigkeit]\n" +
+		"Beispiel: /invite 3 48h",
+	InviteCreated: "Einladungslink für %d Verwendung(en), gültig bis %s:\n" +
+		"%s",
+	InviteLine:        "%s - %d von %d Verwendung(en) übrig, läuft ab %s, erstellt von %s\n",
+	InviteError:       "Unerwarteter Fehler beim Erstellen der Einladung",
+	NoInvites:         "Es gibt keine aktiven Einladungen",
+	RevokeInviteUsage: "Verwendung: /revoke_invite <Token>",
+	RevokeInviteError: "Einladung kann nicht widerrufen werden: %s",
+	InviteRevoked:     "Einladung wurde widerrufen",
+	Greeting:          "Hallo! Wenn du Zugang zum VPN möchtest, sende %s",
+	InviteNotValid:    "Dieser Einladungslink ist nicht mehr gültig",
+	InviteWelcome: "Willkommen! Du wurdest zum VPN eingeladen.\n" +
+		"Erstelle einen leeren Tunnel in der Wireguard-App auf deinem Gerät und gib seinen öffentlichen Schlüssel ein",
+	EnterDeviceName: "Gib einen Namen für dein Gerät ein",
+	InviteConfirmation: "Bist du sicher, dass du dein Gerät hinzufügen möchtest?\n" +
+		"Öffentlicher Schlüssel: %s\n" +
+		"Name: %s",
+	InviteUsed:  "%s ist über deine Einladung beigetreten, Peer „%s“ wurde hinzugefügt",
+	DeviceAdded: "Dein Gerät wurde erfolgreich hinzugefügt! Konfiguration unten.",
+
+	AccessRequestUsage:   "Verwendung: /request_access <öffentlicher Schlüssel> <Gerätename>",
+	AccessRequestError:   "Unerwarteter Fehler beim Erstellen der Zugangsanfrage",
+	AccessRequestPending: "Du hast bereits eine offene Zugangsanfrage",
+	AccessRequestSent:    "Deine Anfrage wurde an die Administratoren gesendet. Du erhältst deine Konfiguration, sobald sie genehmigt ist.",
+	AccessRequestMessage: "Neue Zugangsanfrage von %s\n" +
+		"Öffentlicher Schlüssel: %s\n" +
+		"Name: %s",
+	Approve:                  "Genehmigen",
+	Reject:                   "Ablehnen",
+	RequestNotPending:        "Diese Anfrage ist nicht mehr offen",
+	RequestFailed:            "Peer konnte nicht hinzugefügt werden: %s",
+	RequestApprovedBy:        "Genehmigt von %s",
+	RequestRejectedBy:        "Abgelehnt von %s",
+	RequestExpired:           "Abgelaufen",
+	AccessRequestNotComplete: "Deine Zugangsanfrage konnte nicht abgeschlossen werden, bitte wende dich an die Administratoren",
+	AccessRequestNoConfig:    "Deine Zugangsanfrage wurde genehmigt, aber die Clientkonfiguration konnte nicht abgerufen werden, bitte wende dich an die Administratoren",
+	AccessRequestApproved:    "Deine Zugangsanfrage wurde genehmigt! Konfiguration unten.",
+	AccessRequestRejected:    "Deine Zugangsanfrage wurde abgelehnt",
+	AccessRequestExpired:     "Deine Zugangsanfrage ist abgelaufen",
+
+	ChooseLanguage:  "Wähle die Sprache des Bots",
+	LanguageChanged: "Die Sprache des Bots wurde auf %s geändert",
+	UnknownLanguage: "Unbekannte Sprache, unterstützte Sprachen: %s",
+
+	NoProblems:      "Keine Probleme in der Serverkonfiguration gefunden",
+	ProblemsFound:   "Probleme in der Serverkonfiguration gefunden:",
+	MoreProblems:    "…und %d weitere",
+	CheckError:      "Unerwarteter Fehler beim Prüfen der Serverkonfiguration",
+	FixReaddress:    "Neue Adresse",
+	FixRemove:       "Entfernen",
+	SelectFix:       "Bitte wähle eine Korrektur mit den Schaltflächen oben",
+	FixConfirmation: "%s\n\nKorrektur „%s“ anwenden?",
+	PeerReaddressed: "Der Peer hat die neue Adresse %s erhalten",
+	FixOutdated:     "Der Peer hat sich seit der Prüfung geändert, bitte sieh dir die Probleme erneut an",
+	FixError:        "Unerwarteter Fehler beim Anwenden der Korrektur",
+
+	BatchStarted:        "Stapelmodus ist an: Hinzugefügte und entfernte Peers sowie Korrekturen werden bis /commit gesammelt. Mit /batch ansehen, mit /abort verwerfen",
+	NoBatch:             "Stapelmodus ist aus, verwende /batch, um Änderungen zu sammeln",
+	NoStagedChanges:     "Stapelmodus ist an, noch keine Änderungen gesammelt",
+	StagedChanges:       "Gesammelte Änderungen:",
+	StagedAddPeer:       "%s hinzufügen",
+	StagedRemovePeer:    "%s entfernen",
+	StagedReaddressPeer: "Neue Adresse für %s",
+	StagedDisablePeer:   "%s sperren",
+	StagedEnablePeer:    "%s entsperren",
+	ChangeStaged:        "Änderung wurde gesammelt, Änderungen warten auf /commit: %d",
+	StageError:          "Änderung konnte nicht gesammelt werden, sie widerspricht der Konfiguration oder gesammelten Änderungen",
+	BatchEmpty:          "Es gab keine gesammelten Änderungen, Stapelmodus ist aus",
+	BatchAborted:        "Gesammelte Änderungen verworfen: %d. Stapelmodus ist aus",
+	CommitConfirmation:  "Gesammelte Änderungen anwenden (%d)?",
+	BatchCommitted:      "Änderungen angewendet: %d. Stapelmodus ist aus",
+	CommitError:         "Gesammelte Änderungen konnten nicht angewendet werden, die Konfiguration blieb unverändert. Mit /batch ansehen oder mit /abort verwerfen",
+	BatchLost:           "Mit /batch gesammelte Änderungen gingen beim Neustart des Bots verloren, da die Konfiguration inzwischen geändert wurde. Stapelmodus ist aus",
+
+	ImportHint:            "Sende eine CSV- oder JSON-Datei mit Zeilen name,public_key[,address], um Peers zu importieren",
+	ImportReplyHint:       "Sende die Datei als Antwort auf diese Nachricht oder mit der Beschriftung /import",
+	ImportTooLarge:        "Die Datei ist zu groß, sie darf höchstens %d KiB haben",
+	ImportUnsupportedFile: "Nur CSV- und JSON-Dateien können importiert werden",
+	ImportReadError:       "Die Datei konnte nicht gelesen werden",
+	ImportParseError:      "Die Datei konnte nicht verarbeitet werden: %s",
+	ImportFailed:          "Unerwarteter Fehler beim Importieren der Peers",
+	RowsSkipped:           "Diese Zeilen werden übersprungen:",
+	NothingToImport:       "Es gibt keine Peers zum Importieren",
+	ImportConfirmation:    "%d Peers hinzufügen?",
+	PeersImported:         "Peers hinzugefügt: %d. Die Client-Konfigurationen folgen",
+	ImportStaged:          "Peers gesammelt: %d, Änderungen warten auf /commit: %d",
+	ClientConfigsCaption:  "Client-Konfigurationen, der private Schlüssel jedes Peers muss eingetragen werden",
+
+	ExportUsage:   "Verwendung: /export [csv|json]",
+	ExportError:   "Unerwarteter Fehler beim Exportieren der Peers",
+	ExportCaption: "Peers, Stand %s",
+}
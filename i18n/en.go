@@ -0,0 +1,201 @@
+package i18n
+
+var english = map[Key]string{
+	CommandAddPeer:       "Add new peer to server configuration",
+	CommandRemovePeer:    "Remove peer from server configuration",
+	CommandClientConfig:  "Get client config for the specific peer",
+	CommandFind:          "Find peers by name, public key, IP address or metadata",
+	CommandAudit:         "Show latest configuration changes",
+	CommandIPAM:          "Show address pool utilization and the next address",
+	CommandDoctor:        "Check server configuration and fix problems",
+	CommandBatch:         "Stage changes to apply them at once",
+	CommandCommit:        "Apply staged changes",
+	CommandAbort:         "Drop staged changes",
+	CommandImport:        "Import peers from a CSV or JSON file",
+	CommandExport:        "Export the list of peers as a CSV or JSON file",
+	CommandInvite:        "Create invite link for adding new peer",
+	CommandInvites:       "List active invite links",
+	CommandRevokeInvite:  "Revoke invite link",
+	CommandCancel:        "Cancel current command",
+	CommandRequestAccess: "Request access to VPN for your device",
+	CommandLanguage:      "Change bot language",
+
+	NoActiveCommand:          "There is no active command to cancel",
+	CommandCancelled:         "Command was cancelled",
+	PreviousCommandCancelled: "Previous command was cancelled",
+	CommandTimedOut:          "Command was cancelled due to inactivity",
+	CommandInterrupted:       "Your command was interrupted by bot restart, please start it again",
+	CommandResumed:           "Bot was restarted, let's continue where we left off",
+	CommandNotResumed:        "Can't resume the command, please start it again",
+	FirstStep:                "This is the first step, use /cancel to cancel the command",
+	Yes:                      "Yes",
+	No:                       "No",
+	Back:                     "« Back",
+	AnswerQuestionFirst:      "Please answer the question first",
+	AnswerYesOrNo:            "Please answer '%s' or '%s'",
+	InvalidPublicKey:         "Public key is not valid, please try again",
+	InvalidPeerName:          "Name should be a single line without special characters, please try again",
+	UnknownCommand:           "Unknown command, available commands: %s",
+
+	ListOutdated:        "This list is outdated",
+	PeerListError:       "Unexpected error while fetching peer list",
+	NoPeers:             "No peers found in configuration",
+	NoPeersFound:        "No peers found",
+	SelectPeer:          "Please select a peer using buttons above",
+	PeerSelected:        "%s\nSelected: %s",
+	PeerGone:            "This peer no longer exists",
+	PageOf:              "%s (page %d of %d)",
+	PreviousPage:        "« Previous",
+	NextPage:            "Next »",
+	FindUsage:           "Usage: /find <name, public key prefix, IP address or metadata>",
+	PeersMatching:       "Peers matching '%s'",
+	NoPeersMatching:     "No peers found matching '%s'",
+	ActionConfig:        "Config",
+	ActionRemove:        "Remove",
+	ActionDisable:       "Disable",
+	ActionEnable:        "Enable",
+	DisabledPeer:        "%s (disabled)",
+	PeerDisabled:        "%s is disabled, it keeps its address and could be enabled again",
+	PeerEnabled:         "%s is enabled",
+	DisablePeerError:    "Unexpected error occured while disabling or enabling peer",
+	RemovalCancelled:    "Removal cancelled",
+	DisableConfirmation: "Disable %s?",
+	EnableConfirmation:  "Enable %s?",
+	ToggleCancelled:     "Nothing was changed",
+	ClientConfigErr:     "Unexpected error occured while trying to obtain client config for peer",
+	SelectPeerConfig:    "Select peer to display its client configuration",
+
+	ConfigInterface:  "Interface",
+	ConfigAddress:    "Address",
+	ConfigDNS:        "DNS",
+	ConfigPeer:       "Peer",
+	ConfigPublicKey:  "Public key",
+	ConfigAllowedIPs: "Allowed IPs",
+	ConfigEndpoint:   "Endpoint",
+	ConfigTemplate:   "Config template",
+
+	PoolTitle:     "Address pool",
+	PoolSubnet:    "Subnet",
+	PoolUsed:      "Used",
+	PoolReserved:  "Reserved",
+	PoolFree:      "Free",
+	PoolNext:      "Next address",
+	PoolExhausted: "no free addresses left",
+	PoolRandom:    "random, e.g.",
+	PoolError:     "Unexpected error while reading address pool",
+
+	EnterPublicKey: "Enter public key for new peer",
+	EnterPeerName:  "Enter peer name",
+	AddConfirmation: "Are you sure that you want to add new peer?\n" +
+		"Public key: %s\n" +
+		"Name: %s",
+	AddPeerError:     "Unexpected error occured while adding peer",
+	PeerAdded:        "Peer was added successfully! Config below.",
+	SelectPeerRemove: "Select peer to remove",
+	RemoveConfirmation: "Are you sure that you want to remove peer?\n" +
+		"Public key: %s\n" +
+		"Name: %s",
+	RemovePeerError:    "Unexpected error occured while removing peer",
+	PeerRemoved:        "Peer was removed successfully!",
+	AddPeerConfigError: "Server configuration has problems which prevent choosing an address for the peer, use /doctor to see and fix them",
+	PreviewError:       "Could not prepare a preview of the change, it will probably fail",
+	ChangeOutdated:     "The configuration file was changed since the preview, please review the change again",
+	ReviewChangeAgain:  "Please review the change once more before it is applied",
+
+	AuditUsage: "Usage: /audit [N] [user=<id|username>] [op=<operation>] [key=<public key prefix>] [result=<success|failure|rolled_back>]",
+	AuditError: "Unexpected error occured while reading audit log",
+	AuditEmpty: "No audit log entries found",
+
+	InviteUsage: "Usage: /invite [uses] [ttl]\n" +
+		"Example: /invite 3 48h",
+	InviteCreated: "Invite link for %d use(s), valid until %s:\n" +
+		"%s",
+	InviteLine:        "%s - %d of %d use(s) left, expires %s, created by %s\n",
+	InviteError:       "Unexpected error occured while creating invite",
+	NoInvites:         "There are no active invites",
+	RevokeInviteUsage: "Usage: /revoke_invite <token>",
+	RevokeInviteError: "Can't revoke invite: %s",
+	InviteRevoked:     "Invite was revoked",
+	Greeting:          "Hi! If you'd like to get access to VPN, send %s",
+	InviteNotValid:    "This invite link is no longer valid",
+	InviteWelcome: "Welcome! You were invited to join VPN.\n" +
+		"Create an empty tunnel in Wireguard app on your device and enter its public key",
+	EnterDeviceName: "Enter a name for your device",
+	InviteConfirmation: "Are you sure that you want to add your device?\n" +
+		"Public key: %s\n" +
+		"Name: %s",
+	InviteUsed:  "%s joined using your invite, peer '%s' was added",
+	DeviceAdded: "Your device was added successfully! Config below.",
+
+	AccessRequestUsage:   "Usage: /request_access <public key> <device name>",
+	AccessRequestError:   "Unexpected error occured while creating access request",
+	AccessRequestPending: "You already have a pending access request",
+	AccessRequestSent:    "Your request was sent to administrators. You will receive your config once it is approved.",
+	AccessRequestMessage: "New access request from %s\n" +
+		"Public key: %s\n" +
+		"Name: %s",
+	Approve:                  "Approve",
+	Reject:                   "Reject",
+	RequestNotPending:        "This request is no longer pending",
+	RequestFailed:            "Failed to add peer: %s",
+	RequestApprovedBy:        "Approved by %s",
+	RequestRejectedBy:        "Rejected by %s",
+	RequestExpired:           "Expired",
+	AccessRequestNotComplete: "Your access request could not be completed, please contact administrators",
+	AccessRequestNoConfig:    "Your access request was approved, but client config could not be obtained, please contact administrators",
+	AccessRequestApproved:    "Your access request was approved! Config below.",
+	AccessRequestRejected:    "Your access request was rejected",
+	AccessRequestExpired:     "Your access request has expired",
+
+	ChooseLanguage:  "Choose bot language",
+	LanguageChanged: "Bot language was set to %s",
+	UnknownLanguage: "Unknown language, supported languages: %s",
+
+	NoProblems:      "No problems found in server configuration",
+	ProblemsFound:   "Problems found in server configuration:",
+	MoreProblems:    "…and %d more",
+	CheckError:      "Unexpected error while checking server configuration",
+	FixReaddress:    "Re-address",
+	FixRemove:       "Remove",
+	SelectFix:       "Please choose a fix using buttons above",
+	FixConfirmation: "%s\n\nApply fix '%s'?",
+	PeerReaddressed: "Peer got new address %s",
+	FixOutdated:     "The peer has changed since the check, please review the problems again",
+	FixError:        "Unexpected error occured while applying the fix",
+
+	BatchStarted:        "Batch mode is on: peers you add or remove and fixes you apply are staged until /commit. Review them with /batch, drop them with /abort",
+	NoBatch:             "Batch mode is off, use /batch to start staging changes",
+	NoStagedChanges:     "Batch mode is on, no changes are staged yet",
+	StagedChanges:       "Staged changes:",
+	StagedAddPeer:       "Add %s",
+	StagedRemovePeer:    "Remove %s",
+	StagedReaddressPeer: "Re-address %s",
+	StagedDisablePeer:   "Disable %s",
+	StagedEnablePeer:    "Enable %s",
+	ChangeStaged:        "Change was staged, changes waiting for /commit: %d",
+	StageError:          "Change could not be staged, it conflicts with the configuration or with staged changes",
+	BatchEmpty:          "There were no staged changes, batch mode is off",
+	BatchAborted:        "Staged changes were dropped: %d. Batch mode is off",
+	CommitConfirmation:  "Apply staged changes (%d)?",
+	BatchCommitted:      "Changes were applied: %d. Batch mode is off",
+	CommitError:         "Staged changes could not be applied, configuration was left unchanged. Review them with /batch or drop them with /abort",
+	BatchLost:           "Changes you staged with /batch were lost when the bot restarted, as the configuration was changed in the meantime. Batch mode is off",
+
+	ImportHint:            "Send a CSV or JSON file with name,public_key[,address] rows to import peers",
+	ImportReplyHint:       "Reply to this message with the file, or send it with /import caption",
+	ImportTooLarge:        "The file is too large, it should be at most %d KiB",
+	ImportUnsupportedFile: "Only CSV and JSON files can be imported",
+	ImportReadError:       "Could not read the file",
+	ImportParseError:      "Could not parse the file: %s",
+	ImportFailed:          "Unexpected error while importing peers",
+	RowsSkipped:           "These rows will be skipped:",
+	NothingToImport:       "There are no peers to import",
+	ImportConfirmation:    "Add %d peers?",
+	PeersImported:         "Peers were added: %d. Client configs are below",
+	ImportStaged:          "Peers were staged: %d, changes waiting for /commit: %d",
+	ClientConfigsCaption:  "Client configs, the private key of every peer should be filled in",
+
+	ExportUsage:   "Usage: /export [csv|json]",
+	ExportError:   "Unexpected error while exporting peers",
+	ExportCaption: "Peers as of %s",
+}
@@ -0,0 +1,89 @@
+// Package i18n contains translations of bot messages.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Key identifies a message in the catalog. Key is an error as well, so parsers of
+// user input could return messages which are shown in the user's language.
+type Key string
+
+// Error returns the message in the default language.
+func (k Key) Error() string {
+	return Translate(DefaultLanguage, k)
+}
+
+// DefaultLanguage is used when the user's language is not supported or a message isn't translated.
+const DefaultLanguage = "en"
+
+var catalogs = map[string]map[Key]string{
+	"en": english,
+	"ru": russian,
+	"de": german,
+}
+
+var names = map[string]string{
+	"en": "English",
+	"ru": "Русский",
+	"de": "Deutsch",
+}
+
+// Languages returns codes of supported languages, the default one first.
+func Languages() []string {
+	languages := []string{}
+	for lang := range catalogs {
+		if lang != DefaultLanguage {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Strings(languages)
+	return append([]string{DefaultLanguage}, languages...)
+}
+
+// Name returns name of the language in that language.
+func Name(lang string) string {
+	return names[lang]
+}
+
+// Match returns supported language for IETF language tag sent by Telegram clients,
+// e.g. "de" for "de-AT". Empty string is returned if the language is not supported.
+func Match(code string) string {
+	lang := strings.ToLower(strings.SplitN(code, "-", 2)[0])
+	if _, ok := catalogs[lang]; ok {
+		return lang
+	}
+	return ""
+}
+
+// Translate returns the message in the given language, formatted with args. Messages which
+// are not translated are returned in the default language, and unknown keys are returned as is.
+func Translate(lang string, key Key, args ...interface{}) string {
+	text, ok := catalogs[lang][key]
+	if !ok {
+		text, ok = catalogs[DefaultLanguage][key]
+	}
+	if !ok {
+		text = string(key)
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Missing returns keys of messages which are not translated to the language, sorted.
+func Missing(lang string) []Key {
+	missing := []Key{}
+	for key := range catalogs[DefaultLanguage] {
+		if _, ok := catalogs[lang][key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i] < missing[j]
+	})
+	return missing
+}
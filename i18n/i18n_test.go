@@ -0,0 +1,51 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var verbPattern = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+func TestCatalogsAreComplete(t *testing.T) {
+	for _, lang := range Languages() {
+		t.Run(lang, func(t *testing.T) {
+			require.Empty(t, Missing(lang), "missing translations")
+			require.NotEmpty(t, Name(lang))
+			for key, text := range catalogs[lang] {
+				_, ok := english[key]
+				require.True(t, ok, "unknown key %s", key)
+				require.Equal(t, verbPattern.FindAllString(english[key], -1), verbPattern.FindAllString(text, -1),
+					"format verbs of %s don't match english", key)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	require.Equal(t, "en", Match("en"))
+	require.Equal(t, "de", Match("de-AT"))
+	require.Equal(t, "ru", Match("RU"))
+	require.Equal(t, "", Match("fr"))
+	require.Equal(t, "", Match(""))
+}
+
+func TestTranslate(t *testing.T) {
+	require.Equal(t, "Yes", Translate("en", Yes))
+	require.Equal(t, "Ja", Translate("de", Yes))
+	require.Equal(t, "Yes", Translate("fr", Yes))
+	require.Equal(t, "Please answer 'Yes' or 'No'", Translate("en", AnswerYesOrNo, "Yes", "No"))
+	require.Equal(t, "unknown_key", Translate("en", Key("unknown_key")))
+	require.Equal(t, "Public key is not valid, please try again", InvalidPublicKey.Error())
+	require.Equal(t, []string{"en", "de", "ru"}, Languages())
+}
+
+func TestMissing(t *testing.T) {
+	catalogs["test"] = map[Key]string{Yes: "yes"}
+	defer delete(catalogs, "test")
+	missing := Missing("test")
+	require.Len(t, missing, len(english)-1)
+	require.NotContains(t, missing, Yes)
+}
@@ -0,0 +1,230 @@
+package i18n
+
+// Bot commands
+const (
+	CommandAddPeer       Key = "command_add_peer"
+	CommandRemovePeer    Key = "command_remove_peer"
+	CommandClientConfig  Key = "command_client_config"
+	CommandFind          Key = "command_find"
+	CommandAudit         Key = "command_audit"
+	CommandIPAM          Key = "command_ipam"
+	CommandDoctor        Key = "command_doctor"
+	CommandBatch         Key = "command_batch"
+	CommandCommit        Key = "command_commit"
+	CommandAbort         Key = "command_abort"
+	CommandImport        Key = "command_import"
+	CommandExport        Key = "command_export"
+	CommandInvite        Key = "command_invite"
+	CommandInvites       Key = "command_invites"
+	CommandRevokeInvite  Key = "command_revoke_invite"
+	CommandCancel        Key = "command_cancel"
+	CommandRequestAccess Key = "command_request_access"
+	CommandLanguage      Key = "command_language"
+)
+
+// Conversations
+const (
+	NoActiveCommand          Key = "no_active_command"
+	CommandCancelled         Key = "command_cancelled"
+	PreviousCommandCancelled Key = "previous_command_cancelled"
+	CommandTimedOut          Key = "command_timed_out"
+	CommandInterrupted       Key = "command_interrupted"
+	CommandResumed           Key = "command_resumed"
+	CommandNotResumed        Key = "command_not_resumed"
+	FirstStep                Key = "first_step"
+	Yes                      Key = "yes"
+	No                       Key = "no"
+	Back                     Key = "back"
+	AnswerQuestionFirst      Key = "answer_question_first"
+	AnswerYesOrNo            Key = "answer_yes_or_no"
+	InvalidPublicKey         Key = "invalid_public_key"
+	InvalidPeerName          Key = "invalid_peer_name"
+	UnknownCommand           Key = "unknown_command"
+)
+
+// Peer lists
+const (
+	ListOutdated        Key = "list_outdated"
+	PeerListError       Key = "peer_list_error"
+	NoPeers             Key = "no_peers"
+	NoPeersFound        Key = "no_peers_found"
+	SelectPeer          Key = "select_peer"
+	PeerSelected        Key = "peer_selected"
+	PeerGone            Key = "peer_gone"
+	PageOf              Key = "page_of"
+	PreviousPage        Key = "previous_page"
+	NextPage            Key = "next_page"
+	FindUsage           Key = "find_usage"
+	PeersMatching       Key = "peers_matching"
+	NoPeersMatching     Key = "no_peers_matching"
+	ActionConfig        Key = "action_config"
+	ActionRemove        Key = "action_remove"
+	ActionDisable       Key = "action_disable"
+	ActionEnable        Key = "action_enable"
+	DisabledPeer        Key = "disabled_peer"
+	PeerDisabled        Key = "peer_disabled"
+	PeerEnabled         Key = "peer_enabled"
+	DisablePeerError    Key = "disable_peer_error"
+	RemovalCancelled    Key = "removal_cancelled"
+	DisableConfirmation Key = "disable_confirmation"
+	EnableConfirmation  Key = "enable_confirmation"
+	ToggleCancelled     Key = "toggle_cancelled"
+	ClientConfigErr     Key = "client_config_error"
+	SelectPeerConfig    Key = "select_peer_config"
+)
+
+// Client configs
+const (
+	ConfigInterface  Key = "config_interface"
+	ConfigAddress    Key = "config_address"
+	ConfigDNS        Key = "config_dns"
+	ConfigPeer       Key = "config_peer"
+	ConfigPublicKey  Key = "config_public_key"
+	ConfigAllowedIPs Key = "config_allowed_ips"
+	ConfigEndpoint   Key = "config_endpoint"
+	ConfigTemplate   Key = "config_template"
+)
+
+// Address pool
+const (
+	PoolTitle     Key = "pool_title"
+	PoolSubnet    Key = "pool_subnet"
+	PoolUsed      Key = "pool_used"
+	PoolReserved  Key = "pool_reserved"
+	PoolFree      Key = "pool_free"
+	PoolNext      Key = "pool_next"
+	PoolExhausted Key = "pool_exhausted"
+	PoolRandom    Key = "pool_random"
+	PoolError     Key = "pool_error"
+)
+
+// Adding and removing peers
+const (
+	EnterPublicKey     Key = "enter_public_key"
+	EnterPeerName      Key = "enter_peer_name"
+	AddConfirmation    Key = "add_confirmation"
+	AddPeerError       Key = "add_peer_error"
+	PeerAdded          Key = "peer_added"
+	SelectPeerRemove   Key = "select_peer_remove"
+	RemoveConfirmation Key = "remove_confirmation"
+	RemovePeerError    Key = "remove_peer_error"
+	PeerRemoved        Key = "peer_removed"
+	AddPeerConfigError Key = "add_peer_config_error"
+	PreviewError       Key = "preview_error"
+	ChangeOutdated     Key = "change_outdated"
+	ReviewChangeAgain  Key = "review_change_again"
+)
+
+// Audit log
+const (
+	AuditUsage Key = "audit_usage"
+	AuditError Key = "audit_error"
+	AuditEmpty Key = "audit_empty"
+)
+
+// Invites
+const (
+	InviteUsage        Key = "invite_usage"
+	InviteCreated      Key = "invite_created"
+	InviteLine         Key = "invite_line"
+	InviteError        Key = "invite_error"
+	NoInvites          Key = "no_invites"
+	RevokeInviteUsage  Key = "revoke_invite_usage"
+	RevokeInviteError  Key = "revoke_invite_error"
+	InviteRevoked      Key = "invite_revoked"
+	Greeting           Key = "greeting"
+	InviteNotValid     Key = "invite_not_valid"
+	InviteWelcome      Key = "invite_welcome"
+	EnterDeviceName    Key = "enter_device_name"
+	InviteConfirmation Key = "invite_confirmation"
+	InviteUsed         Key = "invite_used"
+	DeviceAdded        Key = "device_added"
+)
+
+// Access requests
+const (
+	AccessRequestUsage       Key = "access_request_usage"
+	AccessRequestError       Key = "access_request_error"
+	AccessRequestPending     Key = "access_request_pending"
+	AccessRequestSent        Key = "access_request_sent"
+	AccessRequestMessage     Key = "access_request_message"
+	Approve                  Key = "approve"
+	Reject                   Key = "reject"
+	RequestNotPending        Key = "request_not_pending"
+	RequestFailed            Key = "request_failed"
+	RequestApprovedBy        Key = "request_approved_by"
+	RequestRejectedBy        Key = "request_rejected_by"
+	RequestExpired           Key = "request_expired"
+	AccessRequestNotComplete Key = "access_request_not_complete"
+	AccessRequestNoConfig    Key = "access_request_no_config"
+	AccessRequestApproved    Key = "access_request_approved"
+	AccessRequestRejected    Key = "access_request_rejected"
+	AccessRequestExpired     Key = "access_request_expired"
+)
+
+// Languages
+const (
+	ChooseLanguage  Key = "choose_language"
+	LanguageChanged Key = "language_changed"
+	UnknownLanguage Key = "unknown_language"
+)
+
+// Configuration check
+const (
+	NoProblems      Key = "no_problems"
+	ProblemsFound   Key = "problems_found"
+	MoreProblems    Key = "more_problems"
+	CheckError      Key = "check_error"
+	FixReaddress    Key = "fix_readdress"
+	FixRemove       Key = "fix_remove"
+	SelectFix       Key = "select_fix"
+	FixConfirmation Key = "fix_confirmation"
+	PeerReaddressed Key = "peer_readdressed"
+	FixOutdated     Key = "fix_outdated"
+	FixError        Key = "fix_error"
+)
+
+// Batches
+const (
+	BatchStarted        Key = "batch_started"
+	NoBatch             Key = "no_batch"
+	NoStagedChanges     Key = "no_staged_changes"
+	StagedChanges       Key = "staged_changes"
+	StagedAddPeer       Key = "staged_add_peer"
+	StagedRemovePeer    Key = "staged_remove_peer"
+	StagedReaddressPeer Key = "staged_readdress_peer"
+	StagedDisablePeer   Key = "staged_disable_peer"
+	StagedEnablePeer    Key = "staged_enable_peer"
+	ChangeStaged        Key = "change_staged"
+	StageError          Key = "stage_error"
+	BatchEmpty          Key = "batch_empty"
+	BatchAborted        Key = "batch_aborted"
+	CommitConfirmation  Key = "commit_confirmation"
+	BatchCommitted      Key = "batch_committed"
+	CommitError         Key = "commit_error"
+	BatchLost           Key = "batch_lost"
+)
+
+// Import
+const (
+	ImportHint            Key = "import_hint"
+	ImportReplyHint       Key = "import_reply_hint"
+	ImportTooLarge        Key = "import_too_large"
+	ImportUnsupportedFile Key = "import_unsupported_file"
+	ImportReadError       Key = "import_read_error"
+	ImportParseError      Key = "import_parse_error"
+	ImportFailed          Key = "import_failed"
+	RowsSkipped           Key = "rows_skipped"
+	NothingToImport       Key = "nothing_to_import"
+	ImportConfirmation    Key = "import_confirmation"
+	PeersImported         Key = "peers_imported"
+	ImportStaged          Key = "import_staged"
+	ClientConfigsCaption  Key = "client_configs_caption"
+)
+
+// Export
+const (
+	ExportUsage   Key = "export_usage"
+	ExportError   Key = "export_error"
+	ExportCaption Key = "export_caption"
+)
@@ -0,0 +1,201 @@
+package i18n
+
+var russian = map[Key]string{
+	CommandAddPeer:       "Добавить пир в конфигурацию сервера",
+	CommandRemovePeer:    "Удалить пир из конфигурации сервера",
+	CommandClientConfig:  "Получить клиентскую конфигурацию пира",
+	CommandFind:          "Найти пиры по имени, публичному ключу, IP-адресу или метаданным",
+	CommandAudit:         "Показать последние изменения конфигурации",
+	CommandIPAM:          "Показать использование пула адресов и следующий адрес",
+	CommandDoctor:        "Проверить конфигурацию сервера и исправить проблемы",
+	CommandBatch:         "Накопить изменения, чтобы применить их разом",
+	CommandCommit:        "Применить накопленные изменения",
+	CommandAbort:         "Отменить накопленные изменения",
+	CommandImport:        "Импортировать пиры из файла CSV или JSON",
+	CommandExport:        "Выгрузить список пиров в файл CSV или JSON",
+	CommandInvite:        "Создать ссылку-приглашение для добавления пира",
+	CommandInvites:       "Показать активные приглашения",
+	CommandRevokeInvite:  "Отозвать приглашение",
+	CommandCancel:        "Отменить текущую команду",
+	CommandRequestAccess: "Запросить доступ к VPN для своего устройства",
+	CommandLanguage:      "Изменить язык бота",
+
+	NoActiveCommand:          "Нет активной команды для отмены",
+	CommandCancelled:         "Команда отменена",
+	PreviousCommandCancelled: "Предыдущая команда отменена",
+	CommandTimedOut:          "Команда отменена из-за бездействия",
+	CommandInterrupted:       "Ваша команда была прервана перезапуском бота, пожалуйста, начните её заново",
+	CommandResumed:           "Бот был перезапущен, продолжим с того же места",
+	CommandNotResumed:        "Не удалось продолжить команду, пожалуйста, начните её заново",
+	FirstStep:                "Это первый шаг, используйте /cancel, чтобы отменить команду",
+	Yes:                      "Да",
+	No:                       "Нет",
+	Back:                     "« Назад",
+	AnswerQuestionFirst:      "Сначала ответьте на вопрос",
+	AnswerYesOrNo:            "Пожалуйста, ответьте «%s» или «%s»",
+	InvalidPublicKey:         "Неверный публичный ключ, попробуйте ещё раз",
+	InvalidPeerName:          "Имя должно быть одной строкой без специальных символов, попробуйте ещё раз",
+	UnknownCommand:           "Неизвестная команда, доступные команды: %s",
+
+	ListOutdated:        "Этот список устарел",
+	PeerListError:       "Непредвиденная ошибка при получении списка пиров",
+	NoPeers:             "В конфигурации нет пиров",
+	NoPeersFound:        "Пиры не найдены",
+	SelectPeer:          "Пожалуйста, выберите пир кнопками выше",
+	PeerSelected:        "%s\nВыбран: %s",
+	PeerGone:            "Этого пира больше нет",
+	PageOf:              "%s (страница %d из %d)",
+	PreviousPage:        "« Назад",
+	NextPage:            "Вперёд »",
+	FindUsage:           "Использование: /find <имя, начало публичного ключа, IP-адрес или метаданные>",
+	PeersMatching:       "Пиры по запросу «%s»",
+	NoPeersMatching:     "По запросу «%s» пиры не найдены",
+	ActionConfig:        "Конфигурация",
+	ActionRemove:        "Удалить",
+	ActionDisable:       "Откл",
+	ActionEnable:        "Вкл",
+	DisabledPeer:        "%s (отключён)",
+	PeerDisabled:        "%s отключён, адрес за ним сохранён, его можно включить снова",
+	PeerEnabled:         "%s включён",
+	DisablePeerError:    "Непредвиденная ошибка при отключении или включении пира",
+	RemovalCancelled:    "Удаление отменено",
+	DisableConfirmation: "Отключить %s?",
+	EnableConfirmation:  "Включить %s?",
+	ToggleCancelled:     "Ничего не изменено",
+	ClientConfigErr:     "Непредвиденная ошибка при получении клиентской конфигурации пира",
+	SelectPeerConfig:    "Выберите пир, чтобы показать его клиентскую конфигурацию",
+
+	ConfigInterface:  "Интерфейс",
+	ConfigAddress:    "Адрес",
+	ConfigDNS:        "DNS",
+	ConfigPeer:       "Пир",
+	ConfigPublicKey:  "Публичный ключ",
+	ConfigAllowedIPs: "Разрешённые IP",
+	ConfigEndpoint:   "Endpoint",
+	ConfigTemplate:   "Шаблон конфигурации",
+
+	PoolTitle:     "Пул адресов",
+	PoolSubnet:    "Подсеть",
+	PoolUsed:      "Занято",
+	PoolReserved:  "Зарезервировано",
+	PoolFree:      "Свободно",
+	PoolNext:      "Следующий адрес",
+	PoolExhausted: "свободных адресов не осталось",
+	PoolRandom:    "случайный, например",
+	PoolError:     "Непредвиденная ошибка при чтении пула адресов",
+
+	EnterPublicKey: "Введите публичный ключ нового пира",
+	EnterPeerName:  "Введите имя пира",
+	AddConfirmation: "Вы уверены, что хотите добавить новый пир?\n" +
+		"Публичный ключ: %s\n" +
+		"Имя: %s",
+	AddPeerError:     "Непредвиденная ошибка при добавлении пира",
+	PeerAdded:        "Пир успешно добавлен! Конфигурация ниже.",
+	SelectPeerRemove: "Выберите пир для удаления",
+	RemoveConfirmation: "Вы уверены, что хотите удалить пир?\n" +
+		"Публичный ключ: %s\n" +
+		"Имя: %s",
+	RemovePeerError:    "Непредвиденная ошибка при удалении пира",
+	PeerRemoved:        "Пир успешно удалён!",
+	AddPeerConfigError: "В конфигурации сервера есть проблемы, из-за которых нельзя выбрать адрес для пира, используйте /doctor, чтобы увидеть и исправить их",
+	PreviewError:       "Не удалось подготовить предпросмотр изменения, скорее всего оно не удастся",
+	ChangeOutdated:     "Файл конфигурации изменился после предпросмотра, проверьте изменение ещё раз",
+	ReviewChangeAgain:  "Пожалуйста, проверьте изменение ещё раз перед применением",
+
+	AuditUsage: "Использование: /audit [N] [user=<id|имя пользователя>] [op=<операция>] [key=<начало публичного ключа>] [result=<success|failure|rolled_back>]",
+	AuditError: "Непредвиденная ошибка при чтении журнала аудита",
+	AuditEmpty: "Записи в журнале аудита не найдены",
+
+	InviteUsage: "Использование: /invite [количество] [срок]\n" +
+		"Пример: /invite 3 48h",
+	InviteCreated: "Ссылка-приглашение на %d использований, действует до %s:\n" +
+		"%s",
+	InviteLine:        "%s - осталось %d из %d использований, истекает %s, создал %s\n",
+	InviteError:       "Непредвиденная ошибка при создании приглашения",
+	NoInvites:         "Активных приглашений нет",
+	RevokeInviteUsage: "Использование: /revoke_invite <токен>",
+	RevokeInviteError: "Не удалось отозвать приглашение: %s",
+	InviteRevoked:     "Приглашение отозвано",
+	Greeting:          "Привет! Если вы хотите получить доступ к VPN, отправьте %s",
+	InviteNotValid:    "Эта ссылка-приглашение больше не действует",
+	InviteWelcome: "Добро пожаловать! Вас пригласили подключиться к VPN.\n" +
+		"Создайте пустой туннель в приложении Wireguard на своём устройстве и введите его публичный ключ",
+	EnterDeviceName: "Введите название своего устройства",
+	InviteConfirmation: "Вы уверены, что хотите добавить своё устройство?\n" +
+		"Публичный ключ: %s\n" +
+		"Имя: %s",
+	InviteUsed:  "%s присоединился по вашему приглашению, добавлен пир «%s»",
+	DeviceAdded: "Ваше устройство успешно добавлено! Конфигурация ниже.",
+
+	AccessRequestUsage:   "Использование: /request_access <публичный ключ> <название устройства>",
+	AccessRequestError:   "Непредвиденная ошибка при создании запроса доступа",
+	AccessRequestPending: "У вас уже есть ожидающий запрос доступа",
+	AccessRequestSent:    "Ваш запрос отправлен администраторам. Вы получите конфигурацию, когда его одобрят.",
+	AccessRequestMessage: "Новый запрос доступа от %s\n" +
+		"Публичный ключ: %s\n" +
+		"Имя: %s",
+	Approve:                  "Одобрить",
+	Reject:                   "Отклонить",
+	RequestNotPending:        "Этот запрос уже обработан",
+	RequestFailed:            "Не удалось добавить пир: %s",
+	RequestApprovedBy:        "Одобрен пользователем %s",
+	RequestRejectedBy:        "Отклонён пользователем %s",
+	RequestExpired:           "Истёк",
+	AccessRequestNotComplete: "Не удалось выполнить ваш запрос доступа, пожалуйста, свяжитесь с администраторами",
+	AccessRequestNoConfig:    "Ваш запрос доступа одобрен, но получить клиентскую конфигурацию не удалось, пожалуйста, свяжитесь с администраторами",
+	AccessRequestApproved:    "Ваш запрос доступа одобрен! Конфигурация ниже.",
+	AccessRequestRejected:    "Ваш запрос доступа отклонён",
+	AccessRequestExpired:     "Срок действия вашего запроса доступа истёк",
+
+	ChooseLanguage:  "Выберите язык бота",
+	LanguageChanged: "Язык бота изменён на %s",
+	UnknownLanguage: "Неизвестный язык, поддерживаются: %s",
+
+	NoProblems:      "В конфигурации сервера проблем не найдено",
+	ProblemsFound:   "В конфигурации сервера найдены проблемы:",
+	MoreProblems:    "…и ещё %d",
+	CheckError:      "Непредвиденная ошибка при проверке конфигурации сервера",
+	FixReaddress:    "Сменить адрес",
+	FixRemove:       "Удалить",
+	SelectFix:       "Пожалуйста, выберите исправление с помощью кнопок выше",
+	FixConfirmation: "%s\n\nПрименить исправление «%s»?",
+	PeerReaddressed: "Пир получил новый адрес %s",
+	FixOutdated:     "Пир изменился после проверки, пожалуйста, просмотрите проблемы ещё раз",
+	FixError:        "Непредвиденная ошибка при применении исправления",
+
+	BatchStarted:        "Пакетный режим включён: добавление и удаление пиров и исправления накапливаются до /commit. Просмотреть их можно с помощью /batch, отменить — с помощью /abort",
+	NoBatch:             "Пакетный режим выключен, используйте /batch, чтобы начать накапливать изменения",
+	NoStagedChanges:     "Пакетный режим включён, изменений пока нет",
+	StagedChanges:       "Накопленные изменения:",
+	StagedAddPeer:       "Добавить %s",
+	StagedRemovePeer:    "Удалить %s",
+	StagedReaddressPeer: "Сменить адрес %s",
+	StagedDisablePeer:   "Отключить %s",
+	StagedEnablePeer:    "Включить %s",
+	ChangeStaged:        "Изменение добавлено в пакет, изменений ожидает /commit: %d",
+	StageError:          "Не удалось добавить изменение в пакет, оно противоречит конфигурации или накопленным изменениям",
+	BatchEmpty:          "Накопленных изменений не было, пакетный режим выключен",
+	BatchAborted:        "Отменено накопленных изменений: %d. Пакетный режим выключен",
+	CommitConfirmation:  "Применить накопленные изменения (%d)?",
+	BatchCommitted:      "Применено изменений: %d. Пакетный режим выключен",
+	CommitError:         "Не удалось применить накопленные изменения, конфигурация осталась прежней. Просмотрите их с помощью /batch или отмените с помощью /abort",
+	BatchLost:           "Изменения, накопленные с /batch, потеряны при перезапуске бота, так как конфигурация за это время изменилась. Пакетный режим выключен",
+
+	ImportHint:            "Отправьте файл CSV или JSON со строками name,public_key[,address], чтобы импортировать пиры",
+	ImportReplyHint:       "Отправьте файл в ответ на это сообщение или с подписью /import",
+	ImportTooLarge:        "Файл слишком большой, он должен быть не больше %d КиБ",
+	ImportUnsupportedFile: "Импортировать можно только файлы CSV и JSON",
+	ImportReadError:       "Не удалось прочитать файл",
+	ImportParseError:      "Не удалось разобрать файл: %s",
+	ImportFailed:          "Непредвиденная ошибка при импорте пиров",
+	RowsSkipped:           "Эти строки будут пропущены:",
+	NothingToImport:       "Нет пиров для импорта",
+	ImportConfirmation:    "Добавить пиров: %d?",
+	PeersImported:         "Добавлено пиров: %d. Конфигурации клиентов ниже",
+	ImportStaged:          "Пиров добавлено в пакет: %d, изменений ожидает /commit: %d",
+	ClientConfigsCaption:  "Конфигурации клиентов, в каждую нужно вписать приватный ключ пира",
+
+	ExportUsage:   "Использование: /export [csv|json]",
+	ExportError:   "Непредвиденная ошибка при выгрузке пиров",
+	ExportCaption: "Пиры на %s",
+}
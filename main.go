@@ -2,51 +2,46 @@ package main
 
 import (
 	"flag"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
 	"time"
 
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/chat"
+	"github.com/rem11/simple-wg-telegram-bot/render"
 	"github.com/rem11/simple-wg-telegram-bot/telegram"
 	"github.com/rem11/simple-wg-telegram-bot/wireguard"
-	"gopkg.in/ini.v1"
 )
 
-type Config struct {
-	ConfigFilePath string
-	Hostname       string
-	DNS            string
-	UseStub        bool
-	InterfaceName  string
-	BotToken       string
-	UserIDs        []int64
-}
+func main() {
+	var configPath string
+	var check bool
+	var console bool
+	flag.StringVar(&configPath, "config", "", "Configuration file path (ini, YAML or TOML)")
+	flag.BoolVar(&check, "check", false, "Check configuration and exit")
+	flag.BoolVar(&console, "console", false, "Manage peers from the terminal instead of Telegram")
+	flag.Parse()
 
-func readConfig(configPath string) *Config {
-	cfgFile, err := ini.Load(configPath)
+	config, err := readConfig(configPath)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	config := &Config{}
-
-	err = cfgFile.MapTo(config)
-	if err != nil {
-		log.Fatal(err)
+	ok := printReport(os.Stderr, runChecks(config))
+	if check {
+		if !ok {
+			os.Exit(1)
+		}
+		return
 	}
-
-	return config
-}
-
-func main() {
-	var configPath string
-	flag.StringVar(&configPath, "config", "", "Configuration file path")
-	flag.Parse()
-
-	if configPath == "" {
-		log.Fatal("Please specify path to configuration file")
+	if !ok {
+		log.Fatal("Configuration is not valid, please fix the problems above")
 	}
 
-	config := readConfig(configPath)
-
 	var processManager wireguard.ProcessManagerInterface
 	if config.UseStub {
 		processManager = &wireguard.ProcessManagerStub{}
@@ -56,23 +51,171 @@ func main() {
 		}
 	}
 
-	configManager := &wireguard.ConfigManager{
+	// Strategy was validated along with the rest of the configuration
+	allocation, _ := wireguard.ParseStrategy(config.AddressAllocation)
+
+	auditLogPath := config.AuditLogPath
+	if auditLogPath == "" {
+		auditLogPath = statePath(config, "audit.jsonl")
+	}
+
+	configManager := audit.NewConfigManager(&wireguard.ConfigManager{
 		ConfigFilePath: config.ConfigFilePath,
 		Hostname:       config.Hostname,
 		DNS:            config.DNS,
 		ProcessManager: processManager,
+		Reserved:       config.ReservedRanges,
+		Allocation:     allocation,
+	}, &audit.Log{
+		FilePath: auditLogPath,
+	})
+
+	if console {
+		err = runConsole(configManager)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	accessRequests, err := telegram.NewAccessRequestStore(statePath(config, "access_requests.json"), config.AccessRequestTTL)
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	invites, err := telegram.NewInviteStore(statePath(config, "invites.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	languages, err := telegram.NewLanguageStore(statePath(config, "languages.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	batches, err := telegram.NewBatchStore(statePath(config, "batches.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	configManager.Batches = batches
+
+	var events *telegram.EventNotifier
+	if config.LogChatID != 0 {
+		events = telegram.NewEventNotifier(config.LogChatID, config.LogThreadID)
+		configManager.Sinks = append(configManager.Sinks, events)
+	}
+
+	// Format was validated along with the rest of the configuration
+	format, _ := render.ParseMode(config.MessageFormat)
+
 	bot := telegram.Bot{
 		ConfigManager:     configManager,
-		CommandController: telegram.NewCommandController(),
+		CommandController: telegram.NewCommandController(config.ConversationTimeout, statePath(config, "conversations.json")),
+		AccessRequests:    accessRequests,
+		Invites:           invites,
+		Languages:         languages,
+		Batches:           batches,
+		Events:            events,
+		Format:            format,
 		PollingTimeout:    30 * time.Second,
 		Token:             config.BotToken,
 		UserIDs:           config.UserIDs,
+		GroupIDs:          config.GroupIDs,
+	}
+	if config.WebhookURL != "" {
+		secret := config.WebhookSecret
+		if secret == "" {
+			secret, err = telegram.RandomSecretToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		bot.Poller = &telegram.WebhookPoller{
+			Listen:      config.WebhookListen,
+			PublicURL:   config.WebhookURL,
+			SecretToken: secret,
+			TLSCert:     config.WebhookTLSCert,
+			TLSKey:      config.WebhookTLSKey,
+		}
 	}
 
-	err := bot.Start()
+	// Signals are handled one by one, so shutdown waits for the reload in progress
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, os.Interrupt)
+	stop := make(chan struct{})
+	go func() {
+		for sig := range signals {
+			if sig != syscall.SIGHUP {
+				log.Printf("Received %s, shutting down\n", sig)
+				close(stop)
+				return
+			}
+			log.Println("Reloading configuration")
+			reloaded, err := reloadConfig(configPath, config, &bot)
+			if err != nil {
+				log.Printf("Configuration was not reloaded, keeping the current one: %s\n", err)
+				continue
+			}
+			config = reloaded
+			log.Println("Configuration was reloaded")
+		}
+	}()
+
+	err = bot.Start(stop)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// runConsole runs peer management commands for the local user, reading input from stdin.
+func runConsole(configManager *audit.ConfigManager) error {
+	console := &chat.Console{
+		In:  os.Stdin,
+		Out: os.Stdout,
+		Commands: map[string]func(payload string) chat.Command{
+			"/add_peer": func(string) chat.Command {
+				return chat.NewAddPeerCommand(configManager)
+			},
+			"/remove_peer": func(string) chat.Command {
+				return chat.NewRemovePeerCommand(configManager)
+			},
+			"/client_config": func(string) chat.Command {
+				return chat.NewClientConfigCommand(configManager)
+			},
+			"/find": func(query string) chat.Command {
+				return &chat.FindCommand{ConfigManager: configManager, Query: query}
+			},
+			"/ipam": func(string) chat.Command {
+				return &chat.IPAMCommand{ConfigManager: configManager}
+			},
+			"/export": func(format string) chat.Command {
+				return &chat.ExportCommand{ConfigManager: configManager, Format: format}
+			},
+			"/doctor": func(string) chat.Command {
+				return &chat.DoctorCommand{ConfigManager: configManager}
+			},
+			"/batch": func(string) chat.Command {
+				return &chat.BatchCommand{ConfigManager: configManager}
+			},
+			"/commit": func(string) chat.Command {
+				return &chat.CommitCommand{ConfigManager: configManager}
+			},
+			"/abort": func(string) chat.Command {
+				return &chat.AbortCommand{ConfigManager: configManager}
+			},
+			"/import": func(path string) chat.Command {
+				cmd := &chat.ImportCommand{ConfigManager: configManager}
+				if path != "" {
+					cmd.Open = func() (io.ReadCloser, error) {
+						return os.Open(path)
+					}
+				}
+				return cmd
+			},
+		},
+	}
+	if current, err := user.Current(); err == nil {
+		console.User = chat.User{Username: current.Username, Name: current.Name}
+	}
+	return console.Run()
+}
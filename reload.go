@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/telegram"
+)
+
+// Settings which are applied on reload, changing any other setting requires restart
+var reloadableSettings = map[string]bool{
+	"UserIDs":  true,
+	"GroupIDs": true,
+	"Hostname": true,
+	"DNS":      true,
+}
+
+// changedSettings returns names of settings which differ between two configurations.
+func changedSettings(old *Config, new *Config) []string {
+	changed := []string{}
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(new).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changed = append(changed, oldValue.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
+
+// reloadConfig re-reads configuration and applies settings which can be changed without restart
+// to the running bot. It returns configuration the bot runs with after the reload. If the new
+// configuration is not valid, nothing is applied.
+func reloadConfig(configPath string, current *Config, bot *telegram.Bot) (*Config, error) {
+	config, err := readConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	report := &strings.Builder{}
+	results := runChecks(config)
+	if !printReport(report, results) {
+		return nil, fmt.Errorf("configuration is not valid:\n%s", report)
+	}
+	for _, result := range results {
+		if len(result.Warnings) > 0 {
+			log.Printf("Configuration has warnings, /doctor can fix them:\n%s", report)
+			break
+		}
+	}
+
+	restart := []string{}
+	for _, name := range changedSettings(current, config) {
+		if !reloadableSettings[name] {
+			restart = append(restart, name)
+		}
+	}
+	if len(restart) > 0 {
+		log.Printf("Changes of %s are not applied, they require restart\n", strings.Join(restart, ", "))
+	}
+
+	bot.Reconfigure(config.UserIDs, config.GroupIDs, config.Hostname, config.DNS)
+	applied := *current
+	applied.UserIDs = config.UserIDs
+	applied.GroupIDs = config.GroupIDs
+	applied.Hostname = config.Hostname
+	applied.DNS = config.DNS
+	return &applied, nil
+}
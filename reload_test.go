@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/telegram"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadServerConfig = `[Interface]
+Address    = 192.168.3.1/24
+ListenPort = 11111
+PrivateKey = sLsJoF6gLXYWfRcpRkA7ugzvkYX15Lpvif5oBeZeaHA=
+`
+
+func TestReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	serverConfig := writeFile(t, dir, "wg0.conf", reloadServerConfig)
+	botConfig := `ConfigFilePath = ` + serverConfig + `
+UseStub = true
+BotToken = xxx
+`
+	configPath := writeFile(t, dir, "bot.conf", botConfig+"UserIDs = 111\nHostname = example.com\nDNS = 8.8.8.8\n")
+	config, err := readConfig(configPath)
+	require.NoError(t, err)
+
+	wgConfig := &wireguard.ConfigManager{
+		ConfigFilePath: serverConfig,
+		Hostname:       config.Hostname,
+		DNS:            config.DNS,
+	}
+	bot := &telegram.Bot{
+		ConfigManager: audit.NewConfigManager(wgConfig, &audit.Log{}),
+		UserIDs:       config.UserIDs,
+	}
+
+	t.Run("new settings are applied", func(t *testing.T) {
+		writeFile(t, dir, "bot.conf", botConfig+"UserIDs = 111, 222\nGroupIDs = -100123\nHostname = vpn.example.org\nDNS = 1.1.1.1\nStateDir = /tmp\n")
+		reloaded, err := reloadConfig(configPath, config, bot)
+		require.NoError(t, err)
+		require.Equal(t, []int64{111, 222}, bot.UserIDs)
+		require.Equal(t, []int64{-100123}, bot.GroupIDs)
+		require.Equal(t, "vpn.example.org", wgConfig.Hostname)
+		require.Equal(t, "1.1.1.1", wgConfig.DNS)
+
+		require.Equal(t, []int64{111, 222}, reloaded.UserIDs)
+		require.Equal(t, []int64{-100123}, reloaded.GroupIDs)
+		require.Equal(t, "vpn.example.org", reloaded.Hostname)
+		require.Empty(t, reloaded.StateDir, "settings which require restart are not applied")
+		config = reloaded
+	})
+
+	t.Run("invalid config is not applied", func(t *testing.T) {
+		writeFile(t, dir, "bot.conf", "ConfigFilePath = "+serverConfig+"\nUseStub = true\nHostname = other.example.org\n")
+		_, err := reloadConfig(configPath, config, bot)
+		require.ErrorContains(t, err, "BotToken is not set")
+		require.ErrorContains(t, err, "UserIDs is empty")
+		require.Equal(t, []int64{111, 222}, bot.UserIDs)
+		require.Equal(t, "vpn.example.org", wgConfig.Hostname)
+	})
+
+	t.Run("problems of peers don't block reload", func(t *testing.T) {
+		writeFile(t, dir, "wg0.conf", reloadServerConfig+`
+# Alice
+[Peer]
+PublicKey  = Dc6HJYJHhm//iEeQDnXDPPtQ1u9slnkDaflP0ar4ISE=
+AllowedIPs = 192.168.3.2/32
+
+# Bob
+[Peer]
+PublicKey  = Dc6HJYJHhm//iEeQDnXDPPtQ1u9slnkDaflP0ar4ISE=
+AllowedIPs = 192.168.3.3/32
+`)
+		writeFile(t, dir, "bot.conf", botConfig+"UserIDs = 111\nHostname = vpn.example.org\nDNS = 1.1.1.1\nStateDir = /tmp\n")
+		reloaded, err := reloadConfig(configPath, config, bot)
+		require.NoError(t, err)
+		require.Equal(t, []int64{111}, reloaded.UserIDs)
+		require.Equal(t, []int64{111}, bot.UserIDs)
+	})
+}
+
+func TestChangedSettings(t *testing.T) {
+	old := &Config{UserIDs: []int64{111}, Hostname: "example.com", StateDir: "/tmp"}
+	new := &Config{UserIDs: []int64{111, 222}, Hostname: "example.com"}
+	require.Equal(t, []string{"UserIDs", "StateDir"}, changedSettings(old, new))
+}
@@ -0,0 +1,301 @@
+// Package render builds formatted Telegram messages. Text is escaped according to the
+// context it is placed in, so user-provided values like peer names can't break formatting,
+// and long output is split into several messages which fit into Telegram limits.
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxLength is the maximal length of a Telegram message text.
+const MaxLength = 4096
+
+// Mode is a Telegram formatting mode. Its values are the parse_mode values of Bot API.
+type Mode string
+
+const (
+	Plain      Mode = ""
+	MarkdownV2 Mode = "MarkdownV2"
+	HTML       Mode = "HTML"
+)
+
+// ParseMode returns formatting mode with the given name, case insensitive. Only modes with
+// formatting are accepted.
+func ParseMode(name string) (Mode, error) {
+	for _, mode := range []Mode{MarkdownV2, HTML} {
+		if strings.EqualFold(name, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown message format %s, should be MarkdownV2 or HTML", name)
+}
+
+var (
+	markdownText = markdownEscaper("_*[]()~`>#+-=|{}.!\\")
+	markdownCode = markdownEscaper("`\\")
+	htmlText     = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+func markdownEscaper(special string) *strings.Replacer {
+	pairs := []string{}
+	for _, c := range special {
+		pairs = append(pairs, string(c), "\\"+string(c))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+type kind int
+
+const (
+	textKind kind = iota
+	boldKind
+	codeKind
+	preKind
+)
+
+// style describes how text of some kind is rendered. Escaping is done rune by rune,
+// so any part of the text could be rendered separately.
+type style struct {
+	open, close string
+	escape      func(string) string
+}
+
+func identity(s string) string {
+	return s
+}
+
+func (m Mode) style(k kind) style {
+	switch m {
+	case MarkdownV2:
+		switch k {
+		case boldKind:
+			return style{"*", "*", markdownText.Replace}
+		case codeKind:
+			return style{"`", "`", markdownCode.Replace}
+		case preKind:
+			return style{"```\n", "\n```", markdownCode.Replace}
+		}
+		return style{"", "", markdownText.Replace}
+	case HTML:
+		switch k {
+		case boldKind:
+			return style{"<b>", "</b>", htmlText.Replace}
+		case codeKind:
+			return style{"<code>", "</code>", htmlText.Replace}
+		case preKind:
+			return style{"<pre>", "</pre>", htmlText.Replace}
+		}
+		return style{"", "", htmlText.Replace}
+	}
+	return style{"", "", identity}
+}
+
+func (s style) render(raw string) string {
+	if raw == "" {
+		// Telegram rejects empty entities
+		return ""
+	}
+	return s.open + s.escape(raw) + s.close
+}
+
+// Length returns length of the text the way Telegram counts it, in UTF-16 code units.
+func Length(s string) int {
+	length := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			length += 2
+		} else {
+			length++
+		}
+	}
+	return length
+}
+
+type segment struct {
+	kind kind
+	raw  string
+}
+
+// Message is a formatted message which is rendered once it is complete. It is split
+// into several messages at line breaks if it doesn't fit into a single one. Lines and
+// preformatted blocks which are too long are split as well, every part being formatted
+// on its own, so formatting stays valid.
+type Message struct {
+	lines [][]segment
+}
+
+func (m *Message) add(k kind, raw string) *Message {
+	// Telegram rejects texts which aren't valid UTF-8
+	raw = strings.ToValidUTF8(raw, "\uFFFD")
+	for i, text := range strings.Split(raw, "\n") {
+		if i > 0 || len(m.lines) == 0 {
+			m.lines = append(m.lines, nil)
+		}
+		if text == "" {
+			continue
+		}
+		line := m.lines[len(m.lines)-1]
+		if len(line) > 0 && line[len(line)-1].kind == k {
+			// Adjacent entities of the same kind are merged, "*a**b*" would be ambiguous
+			line[len(line)-1].raw += text
+			continue
+		}
+		m.lines[len(m.lines)-1] = append(line, segment{k, text})
+	}
+	return m
+}
+
+// Text appends plain text, line breaks in it are kept.
+func (m *Message) Text(s string) *Message {
+	return m.add(textKind, s)
+}
+
+// Textf appends formatted plain text.
+func (m *Message) Textf(format string, args ...interface{}) *Message {
+	return m.Text(fmt.Sprintf(format, args...))
+}
+
+// Bold appends bold text.
+func (m *Message) Bold(s string) *Message {
+	return m.add(boldKind, s)
+}
+
+// Code appends inline monospace text.
+func (m *Message) Code(s string) *Message {
+	return m.add(codeKind, s)
+}
+
+// Pre appends preformatted block on its own lines.
+func (m *Message) Pre(s string) *Message {
+	s = strings.ToValidUTF8(strings.TrimSuffix(s, "\n"), "\uFFFD")
+	if len(m.lines) == 0 || len(m.lines[len(m.lines)-1]) > 0 {
+		m.Newline()
+	}
+	m.lines[len(m.lines)-1] = []segment{{preKind, s}}
+	return m.Newline()
+}
+
+// Newline starts a new line.
+func (m *Message) Newline() *Message {
+	m.lines = append(m.lines, nil)
+	return m
+}
+
+// Render returns texts of messages in the given mode, each of them no longer than limit.
+func (m *Message) Render(mode Mode, limit int) []string {
+	messages := []string{}
+	current := strings.Builder{}
+	currentLength := 0
+	flush := func() {
+		// Telegram rejects messages without any visible text
+		if strings.TrimSpace(current.String()) != "" {
+			messages = append(messages, current.String())
+		}
+		current.Reset()
+		currentLength = 0
+	}
+	lines := m.lines
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		for _, part := range renderLine(mode, line, limit) {
+			length := Length(part)
+			if current.Len() > 0 && currentLength+1+length > limit {
+				flush()
+			}
+			if current.Len() > 0 {
+				current.WriteString("\n")
+				currentLength++
+			}
+			current.WriteString(part)
+			currentLength += length
+		}
+	}
+	flush()
+	return messages
+}
+
+// renderLine renders a single line, splitting it into several ones if it is longer than limit.
+func renderLine(mode Mode, line []segment, limit int) []string {
+	if len(line) == 1 && line[0].kind == preKind {
+		return renderPre(mode.style(preKind), line[0].raw, limit)
+	}
+	parts := []string{}
+	current := strings.Builder{}
+	currentLength := 0
+	for _, seg := range line {
+		s := mode.style(seg.kind)
+		rendered := s.render(seg.raw)
+		if currentLength+Length(rendered) <= limit {
+			current.WriteString(rendered)
+			currentLength += Length(rendered)
+			continue
+		}
+		if current.Len() > 0 {
+			parts = append(parts, current.String())
+			current.Reset()
+			currentLength = 0
+		}
+		chunks := splitRaw(s, seg.raw, limit)
+		for _, chunk := range chunks[:len(chunks)-1] {
+			parts = append(parts, s.render(chunk))
+		}
+		rendered = s.render(chunks[len(chunks)-1])
+		current.WriteString(rendered)
+		currentLength = Length(rendered)
+	}
+	return append(parts, current.String())
+}
+
+// renderPre renders preformatted block, splitting it at line breaks into several blocks if it is longer than limit.
+func renderPre(s style, raw string, limit int) []string {
+	rendered := s.render(raw)
+	if Length(rendered) <= limit {
+		return []string{rendered}
+	}
+	blocks := []string{}
+	lines := []string{}
+	length := Length(s.open) + Length(s.close)
+	for _, line := range strings.Split(raw, "\n") {
+		lineLength := Length(s.escape(line))
+		if len(lines) > 0 && length+1+lineLength > limit {
+			blocks = append(blocks, s.render(strings.Join(lines, "\n")))
+			lines = nil
+			length = Length(s.open) + Length(s.close)
+		}
+		if length+lineLength > limit {
+			chunks := splitRaw(s, line, limit)
+			for _, chunk := range chunks[:len(chunks)-1] {
+				blocks = append(blocks, s.render(chunk))
+			}
+			line = chunks[len(chunks)-1]
+			lineLength = Length(s.escape(line))
+		}
+		if len(lines) > 0 {
+			length++
+		}
+		lines = append(lines, line)
+		length += lineLength
+	}
+	return append(blocks, s.render(strings.Join(lines, "\n")))
+}
+
+// splitRaw splits text at rune boundaries into chunks which don't exceed limit once rendered.
+func splitRaw(s style, raw string, limit int) []string {
+	budget := limit - Length(s.open) - Length(s.close)
+	chunks := []string{}
+	start := 0
+	length := 0
+	for i, r := range raw {
+		runeLength := Length(s.escape(string(r)))
+		if length+runeLength > budget && i > start {
+			chunks = append(chunks, raw[start:i])
+			start = i
+			length = 0
+		}
+		length += runeLength
+	}
+	return append(chunks, raw[start:])
+}
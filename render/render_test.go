@@ -0,0 +1,267 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var hostileNames = []string{
+	"bob_laptop (old)",
+	"*bold* _italic_ __underline__ ~strike~ ||spoiler||",
+	"[link](http://example.com)",
+	"`code` ```pre```",
+	"back\\slash\\",
+	"<b>tag</b> & &amp; &lt;",
+	"1. item - 2 + 3 = 5 # > quote | pipe {brace}!",
+	"line\nbreak",
+	"😀 emoji 👨‍👩‍👧",
+	"invalid \xff\xfe utf-8",
+	"",
+	" ",
+}
+
+func TestEscaping(t *testing.T) {
+	message := (&Message{}).
+		Bold("bob_laptop (old)").Newline().
+		Text("Address: ").Code("10.0.0.2/32").Newline().
+		Pre("[Interface]\nPrivateKey = <put `key` here>\n")
+
+	require.Equal(t, []string{"*bob\\_laptop \\(old\\)*\n" +
+		"Address: `10.0.0.2/32`\n" +
+		"```\n[Interface]\nPrivateKey = <put \\`key\\` here>\n```"}, message.Render(MarkdownV2, MaxLength))
+	require.Equal(t, []string{"<b>bob_laptop (old)</b>\n" +
+		"Address: <code>10.0.0.2/32</code>\n" +
+		"<pre>[Interface]\nPrivateKey = &lt;put `key` here&gt;</pre>"}, message.Render(HTML, MaxLength))
+	require.Equal(t, []string{"bob_laptop (old)\n" +
+		"Address: 10.0.0.2/32\n" +
+		"[Interface]\nPrivateKey = <put `key` here>"}, message.Render(Plain, MaxLength))
+}
+
+func TestAdjacentEntitiesAreMerged(t *testing.T) {
+	message := (&Message{}).Bold("a").Bold("b").Code("c").Code("d")
+	require.Equal(t, []string{"*ab*`cd`"}, message.Render(MarkdownV2, MaxLength))
+}
+
+func TestEmptyEntities(t *testing.T) {
+	message := (&Message{}).Text("DNS: ").Code("").Bold("")
+	require.Equal(t, []string{"DNS: "}, message.Render(MarkdownV2, MaxLength))
+	require.Empty(t, (&Message{}).Newline().Text(" ").Render(MarkdownV2, MaxLength))
+}
+
+func TestSplit(t *testing.T) {
+	message := &Message{}
+	for i := 1; i <= 100; i++ {
+		message.Textf("%d. peer_%d", i, i).Newline()
+	}
+	messages := message.Render(MarkdownV2, 200)
+	require.Greater(t, len(messages), 1)
+	lines := []string{}
+	for _, text := range messages {
+		require.LessOrEqual(t, Length(text), 200)
+		lines = append(lines, strings.Split(strings.TrimSuffix(text, "\n"), "\n")...)
+	}
+	require.Len(t, lines, 100)
+	require.Equal(t, "1\\. peer\\_1", lines[0])
+	require.Equal(t, "100\\. peer\\_100", lines[99])
+}
+
+func TestSplitPre(t *testing.T) {
+	lines := []string{}
+	for i := 0; i < 50; i++ {
+		lines = append(lines, fmt.Sprintf("AllowedIPs = 10.0.0.%d/32", i))
+	}
+	messages := (&Message{}).Pre(strings.Join(lines, "\n")).Render(HTML, 300)
+	require.Greater(t, len(messages), 1)
+	for _, text := range messages {
+		require.LessOrEqual(t, Length(text), 300)
+		require.True(t, strings.HasPrefix(text, "<pre>"), text)
+		require.True(t, strings.HasSuffix(strings.TrimSuffix(text, "\n"), "</pre>"), text)
+	}
+}
+
+func TestLength(t *testing.T) {
+	require.Equal(t, 3, Length("abc"))
+	require.Equal(t, 3, Length("äöü"))
+	require.Equal(t, 2, Length("😀"))
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("html")
+	require.NoError(t, err)
+	require.Equal(t, HTML, mode)
+	mode, err = ParseMode("MarkdownV2")
+	require.NoError(t, err)
+	require.Equal(t, MarkdownV2, mode)
+	_, err = ParseMode("Markdown")
+	require.Error(t, err)
+	_, err = ParseMode("")
+	require.Error(t, err)
+}
+
+func tripleBacktick(runes []rune, i int) bool {
+	return i+2 < len(runes) && runes[i] == '`' && runes[i+1] == '`' && runes[i+2] == '`'
+}
+
+// decodeMarkdown parses MarkdownV2 the way Telegram does, and returns the text without formatting.
+// Only entities which are produced by Message are accepted.
+func decodeMarkdown(s string) (string, error) {
+	result := strings.Builder{}
+	bold := false
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 == len(runes) || runes[i+1] > 126 {
+				return "", fmt.Errorf("bad escape at %d", i)
+			}
+			i++
+			result.WriteRune(runes[i])
+		case r == '*':
+			bold = !bold
+		case r == '`':
+			pre := tripleBacktick(runes, i)
+			if pre {
+				i += 3
+			} else {
+				i++
+			}
+			closed := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '`' || runes[i+1] == '\\') {
+					i++
+					result.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == '\\' {
+					return "", fmt.Errorf("bad escape in code at %d", i)
+				}
+				if runes[i] == '`' {
+					if pre && !tripleBacktick(runes, i) {
+						return "", fmt.Errorf("unescaped backtick in pre at %d", i)
+					}
+					if pre {
+						i += 2
+					}
+					closed = true
+					break
+				}
+				result.WriteRune(runes[i])
+			}
+			if !closed {
+				return "", fmt.Errorf("unclosed code entity")
+			}
+		case strings.ContainsRune("_[]()~>#+-=|{}.!", r):
+			return "", fmt.Errorf("unescaped %q at %d", r, i)
+		default:
+			result.WriteRune(r)
+		}
+	}
+	if bold {
+		return "", fmt.Errorf("unclosed bold entity")
+	}
+	return result.String(), nil
+}
+
+// decodeHTML parses HTML the way Telegram does, and returns the text without formatting.
+// Only tags which are produced by Message are accepted.
+func decodeHTML(s string) (string, error) {
+	result := strings.Builder{}
+	open := []string{}
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '<':
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed tag at %d", i)
+			}
+			tag := s[i+1 : i+end]
+			switch tag {
+			case "b", "code", "pre":
+				if len(open) > 0 {
+					return "", fmt.Errorf("nested tag %s at %d", tag, i)
+				}
+				open = append(open, tag)
+			case "/b", "/code", "/pre":
+				if len(open) == 0 || open[len(open)-1] != tag[1:] {
+					return "", fmt.Errorf("unexpected closing tag %s at %d", tag, i)
+				}
+				open = open[:len(open)-1]
+			default:
+				return "", fmt.Errorf("unsupported tag %s at %d", tag, i)
+			}
+			i += end + 1
+		case '&':
+			decoded := false
+			for entity, char := range map[string]string{"&amp;": "&", "&lt;": "<", "&gt;": ">"} {
+				if strings.HasPrefix(s[i:], entity) {
+					result.WriteString(char)
+					i += len(entity)
+					decoded = true
+					break
+				}
+			}
+			if !decoded {
+				return "", fmt.Errorf("unescaped & at %d", i)
+			}
+		case '>':
+			return "", fmt.Errorf("unescaped > at %d", i)
+		default:
+			result.WriteByte(s[i])
+			i++
+		}
+	}
+	if len(open) > 0 {
+		return "", fmt.Errorf("unclosed tag %s", open[0])
+	}
+	return result.String(), nil
+}
+
+var decoders = map[Mode]func(string) (string, error){
+	MarkdownV2: decodeMarkdown,
+	HTML:       decodeHTML,
+	Plain:      func(s string) (string, error) { return s, nil },
+}
+
+// requireRoundTrip checks that all messages fit into the limit, are accepted by Telegram,
+// and contain the whole text. Line breaks are ignored, as messages could be split at them.
+func requireRoundTrip(t *testing.T, message *Message, expected string, limit int) {
+	expected = strings.ReplaceAll(strings.ToValidUTF8(expected, "�"), "\n", "")
+	for mode, decode := range decoders {
+		decoded := strings.Builder{}
+		for _, text := range message.Render(mode, limit) {
+			require.LessOrEqual(t, Length(text), limit, "mode %q", mode)
+			require.NotEmpty(t, strings.TrimSpace(text), "mode %q", mode)
+			plain, err := decode(text)
+			require.NoError(t, err, "mode %q: %s", mode, text)
+			decoded.WriteString(plain)
+		}
+		require.Equal(t, expected, strings.ReplaceAll(decoded.String(), "\n", ""), "mode %q", mode)
+	}
+}
+
+func FuzzMessage(f *testing.F) {
+	for _, name := range hostileNames {
+		f.Add(name, 64)
+		f.Add(name, MaxLength)
+	}
+	f.Add(strings.Repeat("_", 5000), MaxLength)
+	f.Add(strings.Repeat("😀", 3000), MaxLength)
+	f.Add(strings.Repeat("`\\", 100), 40)
+	f.Add(strings.Repeat("a\n", 300), 100)
+
+	f.Fuzz(func(t *testing.T, name string, limit int) {
+		// Every entity has to fit into a message along with its markup
+		if limit < 32 || limit > MaxLength {
+			limit = MaxLength
+		}
+		message := (&Message{}).
+			Bold(name).Newline().
+			Text("Name: ").Code(name).Text(" (").Text(name).Text(")").Newline().
+			Pre(name)
+		requireRoundTrip(t, message, name+"Name: "+name+" ("+name+")"+strings.TrimSuffix(name, "\n"), limit)
+	})
+}
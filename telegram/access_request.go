@@ -0,0 +1,236 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/chat"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"gopkg.in/telebot.v3"
+)
+
+var (
+	approveAccessButton = telebot.InlineButton{Unique: "approve_access"}
+	rejectAccessButton  = telebot.InlineButton{Unique: "reject_access"}
+)
+
+type AccessRequest struct {
+	ID        string
+	UserID    int64
+	User      string
+	PublicKey string
+	Name      string
+	CreatedAt time.Time
+	// Messages sent to admins, so they could be updated once request is resolved
+	Messages []telebot.StoredMessage
+}
+
+type AccessRequestStore struct {
+	FilePath string
+	TTL      time.Duration
+	mu       sync.Mutex
+	requests map[string]*AccessRequest
+}
+
+func NewAccessRequestStore(filePath string, ttl time.Duration) (*AccessRequestStore, error) {
+	store := &AccessRequestStore{
+		FilePath: filePath,
+		TTL:      ttl,
+		requests: map[string]*AccessRequest{},
+	}
+	err := loadState(filePath, &store.requests)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *AccessRequestStore) expired(req *AccessRequest) bool {
+	return s.TTL > 0 && time.Since(req.CreatedAt) > s.TTL
+}
+
+func (s *AccessRequestStore) save() {
+	err := saveState(s.FilePath, s.requests)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *AccessRequestStore) Add(req *AccessRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.requests {
+		if existing.UserID == req.UserID && !s.expired(existing) {
+			return fmt.Errorf("user %d already has a pending request", req.UserID)
+		}
+	}
+	s.requests[req.ID] = req
+	s.save()
+	return nil
+}
+
+func (s *AccessRequestStore) Update(req *AccessRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.requests[req.ID]; ok {
+		s.requests[req.ID] = req
+		s.save()
+	}
+}
+
+// Take removes pending request from the store and returns it. Nil is returned
+// if there is no such request, or it has already expired.
+func (s *AccessRequestStore) Take(id string) *AccessRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req := s.requests[id]
+	if req == nil || s.expired(req) {
+		return nil
+	}
+	delete(s.requests, id)
+	s.save()
+	return req
+}
+
+// Prune removes all expired requests from the store and returns them.
+func (s *AccessRequestStore) Prune() []*AccessRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*AccessRequest
+	for id, req := range s.requests {
+		if s.expired(req) {
+			result = append(result, req)
+			delete(s.requests, id)
+		}
+	}
+	if len(result) > 0 {
+		s.save()
+	}
+	return result
+}
+
+func (bot *Bot) requestAccess(ctx telebot.Context) error {
+	args := ctx.Args()
+	if len(args) < 2 {
+		return ctx.Send(tr(ctx, i18n.AccessRequestUsage))
+	}
+	publicKey := args[0]
+	name := strings.Join(args[1:], " ")
+	_, err := wgtypes.ParseKey(publicKey)
+	if err != nil {
+		return ctx.Send(tr(ctx, i18n.InvalidPublicKey))
+	}
+	name, err = chat.ParsePeerName(name)
+	if err != nil {
+		return ctx.Send(tr(ctx, i18n.InvalidPeerName))
+	}
+
+	id, err := randomToken(9)
+	if err != nil {
+		log.Println(err)
+		return ctx.Send(tr(ctx, i18n.AccessRequestError))
+	}
+	req := &AccessRequest{
+		ID:        id,
+		UserID:    ctx.Sender().ID,
+		User:      formatUser(ctx.Sender()),
+		PublicKey: publicKey,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	err = bot.AccessRequests.Add(req)
+	if err != nil {
+		log.Println(err)
+		return ctx.Send(tr(ctx, i18n.AccessRequestPending))
+	}
+
+	for _, userID := range bot.settings(ctx).UserIDs {
+		lang := bot.Languages.Get(userID)
+		markup := ctx.Bot().NewMarkup()
+		markup.Inline(markup.Row(
+			markup.Data(i18n.Translate(lang, i18n.Approve), approveAccessButton.Unique, req.ID),
+			markup.Data(i18n.Translate(lang, i18n.Reject), rejectAccessButton.Unique, req.ID),
+		))
+		text := i18n.Translate(lang, i18n.AccessRequestMessage, req.User, req.PublicKey, req.Name)
+		msg, err := ctx.Bot().Send(&telebot.User{ID: userID}, text, markup)
+		if err != nil {
+			log.Printf("Can't deliver access request to user %d: %s\n", userID, err)
+			continue
+		}
+		msgID, chatID := msg.MessageSig()
+		req.Messages = append(req.Messages, telebot.StoredMessage{MessageID: msgID, ChatID: chatID})
+	}
+	bot.AccessRequests.Update(req)
+
+	log.Printf("User %s requested access for public key %s and name '%s'\n", req.User, req.PublicKey, req.Name)
+	return ctx.Send(tr(ctx, i18n.AccessRequestSent))
+}
+
+// resolveAccessRequest updates messages sent to admins with the status of the request,
+// which is translated to the language of every admin.
+func (bot *Bot) resolveAccessRequest(b *telebot.Bot, req *AccessRequest, status i18n.Key, args ...interface{}) {
+	for i := range req.Messages {
+		lang := bot.Languages.Get(req.Messages[i].ChatID)
+		text := i18n.Translate(lang, i18n.AccessRequestMessage, req.User, req.PublicKey, req.Name) +
+			"\n\n" + i18n.Translate(lang, status, args...)
+		_, err := b.Edit(&req.Messages[i], text)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (bot *Bot) approveAccess(ctx telebot.Context) error {
+	req := bot.AccessRequests.Take(ctx.Data())
+	if req == nil {
+		return ctx.Respond(&telebot.CallbackResponse{Text: tr(ctx, i18n.RequestNotPending)})
+	}
+	ctx.Respond()
+
+	requester := &telebot.User{ID: req.UserID}
+	lang := bot.Languages.Get(req.UserID)
+	err := bot.ConfigManager.AddPeer(chat.Actor(newConversation(ctx)), req.PublicKey, req.Name)
+	if err != nil {
+		log.Println(err)
+		bot.resolveAccessRequest(ctx.Bot(), req, i18n.RequestFailed, err)
+		ctx.Bot().Send(requester, i18n.Translate(lang, i18n.AccessRequestNotComplete))
+		return nil
+	}
+	bot.resolveAccessRequest(ctx.Bot(), req, i18n.RequestApprovedBy, formatUser(ctx.Sender()))
+
+	cfg, cfgStr, err := bot.ConfigManager.GetClientConfig(req.PublicKey)
+	if err != nil {
+		log.Println(err)
+		ctx.Bot().Send(requester, i18n.Translate(lang, i18n.AccessRequestNoConfig))
+		return nil
+	}
+	ctx.Bot().Send(requester, i18n.Translate(lang, i18n.AccessRequestApproved))
+	err = send(ctx.Bot(), requester, bot.format(), chat.FormatClientConfig(lang, cfg, cfgStr))
+	if err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+func (bot *Bot) rejectAccess(ctx telebot.Context) error {
+	req := bot.AccessRequests.Take(ctx.Data())
+	if req == nil {
+		return ctx.Respond(&telebot.CallbackResponse{Text: tr(ctx, i18n.RequestNotPending)})
+	}
+	ctx.Respond()
+	log.Printf("Rejected access request of %s for public key %s\n", req.User, req.PublicKey)
+	bot.resolveAccessRequest(ctx.Bot(), req, i18n.RequestRejectedBy, formatUser(ctx.Sender()))
+	ctx.Bot().Send(&telebot.User{ID: req.UserID}, i18n.Translate(bot.Languages.Get(req.UserID), i18n.AccessRequestRejected))
+	return nil
+}
+
+func (bot *Bot) pruneAccessRequests(b *telebot.Bot) {
+	for _, req := range bot.AccessRequests.Prune() {
+		bot.resolveAccessRequest(b, req, i18n.RequestExpired)
+		b.Send(&telebot.User{ID: req.UserID}, i18n.Translate(bot.Languages.Get(req.UserID), i18n.AccessRequestExpired))
+	}
+}
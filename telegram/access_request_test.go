@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+func TestAccessRequestStore(t *testing.T) {
+	t.Run("requests expire after TTL", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			ttl     time.Duration
+			age     time.Duration
+			expired bool
+		}{
+			{name: "fresh request", ttl: time.Hour, age: time.Minute},
+			{name: "old request", ttl: time.Hour, age: 2 * time.Hour, expired: true},
+			{name: "without TTL", ttl: 0, age: 1000 * time.Hour},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				store, err := NewAccessRequestStore("", tt.ttl)
+				require.NoError(t, err)
+				require.NoError(t, store.Add(&AccessRequest{ID: "req", UserID: 1, CreatedAt: time.Now().Add(-tt.age)}))
+
+				// Another request of the same user is accepted only once the pending one expires
+				err = store.Add(&AccessRequest{ID: "other", UserID: 1, CreatedAt: time.Now()})
+				if tt.expired {
+					require.NoError(t, err)
+					require.Nil(t, store.Take("req"))
+				} else {
+					require.Error(t, err)
+					require.NotNil(t, store.Take("req"))
+				}
+			})
+		}
+	})
+
+	t.Run("take is single use", func(t *testing.T) {
+		store, err := NewAccessRequestStore("", time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, store.Add(&AccessRequest{ID: "req", UserID: 1, Name: "Bob", CreatedAt: time.Now()}))
+
+		req := store.Take("req")
+		require.NotNil(t, req)
+		require.Equal(t, "Bob", req.Name)
+		require.Nil(t, store.Take("req"))
+		require.Nil(t, store.Take("unknown"))
+
+		// The user may request access again once the request is resolved
+		require.NoError(t, store.Add(&AccessRequest{ID: "again", UserID: 1, CreatedAt: time.Now()}))
+	})
+
+	t.Run("prune removes only expired requests", func(t *testing.T) {
+		store, err := NewAccessRequestStore("", time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, store.Add(&AccessRequest{ID: "fresh", UserID: 1, CreatedAt: time.Now()}))
+		require.NoError(t, store.Add(&AccessRequest{ID: "old", UserID: 2, CreatedAt: time.Now().Add(-2 * time.Hour)}))
+
+		pruned := store.Prune()
+		require.Len(t, pruned, 1)
+		require.Equal(t, "old", pruned[0].ID)
+		require.Empty(t, store.Prune())
+		require.NotNil(t, store.Take("fresh"))
+	})
+
+	t.Run("requests are persisted", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "access_requests.json")
+		store, err := NewAccessRequestStore(filePath, time.Hour)
+		require.NoError(t, err)
+		req := &AccessRequest{ID: "req", UserID: 1, PublicKey: e2ePublicKey, Name: "Bob", CreatedAt: time.Now()}
+		require.NoError(t, store.Add(req))
+		req.Messages = []telebot.StoredMessage{{MessageID: "10", ChatID: e2eAdminID}}
+		store.Update(req)
+
+		reloaded, err := NewAccessRequestStore(filePath, time.Hour)
+		require.NoError(t, err)
+		saved := reloaded.Take("req")
+		require.NotNil(t, saved)
+		require.Equal(t, e2ePublicKey, saved.PublicKey)
+		require.Equal(t, req.Messages, saved.Messages)
+		require.Equal(t, req.CreatedAt.Unix(), saved.CreatedAt.Unix())
+
+		// Taken requests are gone after restart
+		reloaded, err = NewAccessRequestStore(filePath, time.Hour)
+		require.NoError(t, err)
+		require.Nil(t, reloaded.Take("req"))
+	})
+}
+
+func TestAccessRequestButtons(t *testing.T) {
+	t.Run("approve", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+		stranger := h.user(e2eStranger)
+
+		stranger.sends("/request_access " + e2ePublicKey + " Bob laptop").
+			receives("Your request was sent to administrators. You will receive your config once it is approved.")
+		stranger.sends("/request_access " + e2ePublicKey + " Bob phone").receives("You already have a pending access request")
+		admin.receives("New access request from User999 (999)\nPublic key: " + e2ePublicKey + "\nName: Bob laptop")
+
+		admin.clicks("Approve").receivesContaining("Approved by User111")
+		stranger.receives("Your access request was approved! Config below.")
+		stranger.receivesContaining("Address: `192.168.3.2/24`")
+		h.requireConfig(e2eServerConfig + `
+# Bob laptop
+[Peer]
+PublicKey  = ` + e2ePublicKey + `
+AllowedIPs = 192.168.3.2/32
+`)
+
+		// The request can't be resolved twice
+		admin.clicks("Reject")
+		require.Eventually(t, func() bool {
+			answers := h.api.answers()
+			return len(answers) > 0 && answers[len(answers)-1] == "This request is no longer pending"
+		}, e2eWaitTimeout, 5*time.Millisecond)
+		stranger.receivesNothing()
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+		stranger := h.user(e2eStranger)
+
+		stranger.sends("/request_access " + e2ePublicKey + " Bob laptop").receivesContaining("Your request was sent")
+		admin.receivesContaining("New access request from User999")
+		admin.clicks("Reject").receivesContaining("Rejected by User111")
+		stranger.receives("Your access request was rejected")
+		h.requireConfig(e2eServerConfig)
+	})
+
+	t.Run("expired requests are pruned", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+		stranger := h.user(e2eStranger)
+
+		stranger.sends("/request_access " + e2ePublicKey + " Bob laptop").receivesContaining("Your request was sent")
+		admin.receivesContaining("New access request from User999")
+		h.bot.AccessRequests.mu.Lock()
+		for _, req := range h.bot.AccessRequests.requests {
+			req.CreatedAt = time.Now().Add(-2 * time.Hour)
+		}
+		h.bot.AccessRequests.mu.Unlock()
+
+		h.bot.pruneAccessRequests(h.bot.telebot)
+		admin.receivesContaining("Expired")
+		stranger.receives("Your access request has expired")
+		admin.clicks("Approve")
+		require.Eventually(t, func() bool {
+			answers := h.api.answers()
+			return len(answers) > 0 && answers[len(answers)-1] == "This request is no longer pending"
+		}, e2eWaitTimeout, 5*time.Millisecond)
+		h.requireConfig(e2eServerConfig)
+	})
+}
@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"log"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"gopkg.in/telebot.v3"
+)
+
+const defaultAuditLimit = 10
+
+func (bot *Bot) showAudit(ctx telebot.Context) error {
+	filter, err := audit.ParseFilter(ctx.Args())
+	if err != nil {
+		return ctx.Send(err.Error() + "\n" + tr(ctx, i18n.AuditUsage))
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	entries, err := bot.ConfigManager.Log.Tail(filter)
+	if err != nil {
+		log.Println(err)
+		return ctx.Send(tr(ctx, i18n.AuditError))
+	}
+	if len(entries) == 0 {
+		return ctx.Send(tr(ctx, i18n.AuditEmpty))
+	}
+	msg := &render.Message{}
+	for _, entry := range entries {
+		msg.Text(entry.String()).Newline()
+	}
+	return reply(ctx, render.Plain, msg)
+}
@@ -0,0 +1,65 @@
+package telegram
+
+import (
+	"log"
+	"sync"
+
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"gopkg.in/telebot.v3"
+)
+
+// BatchStore keeps changes staged with /batch, so they are restored when the bot restarts.
+type BatchStore struct {
+	FilePath string
+	mu       sync.Mutex
+	batches  map[int64][]wireguard.SavedOp
+}
+
+func NewBatchStore(filePath string) (*BatchStore, error) {
+	store := &BatchStore{
+		FilePath: filePath,
+		batches:  map[int64][]wireguard.SavedOp{},
+	}
+	err := loadState(filePath, &store.batches)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// SaveBatches replaces saved batches, it is called by audit.ConfigManager whenever they change.
+func (s *BatchStore) SaveBatches(batches map[int64][]wireguard.SavedOp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = batches
+	err := saveState(s.FilePath, s.batches)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Saved returns batches which were staged when the bot was stopped.
+func (s *BatchStore) Saved() map[int64][]wireguard.SavedOp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := make(map[int64][]wireguard.SavedOp, len(s.batches))
+	for userID, ops := range s.batches {
+		saved[userID] = ops
+	}
+	return saved
+}
+
+// restoreBatches stages changes saved before the restart again. Users whose changes
+// don't apply anymore are notified that their batch was lost.
+func (bot *Bot) restoreBatches(b *telebot.Bot) {
+	if bot.Batches == nil {
+		return
+	}
+	for _, userID := range bot.ConfigManager.RestoreBatches(bot.Batches.Saved()) {
+		_, err := b.Send(&telebot.User{ID: userID}, i18n.Translate(bot.Languages.Get(userID), i18n.BatchLost))
+		if err != nil {
+			log.Printf("Can't notify user %d that the batch was lost: %s\n", userID, err)
+		}
+	}
+}
@@ -1,32 +1,146 @@
 package telegram
 
 import (
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
-	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/chat"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
 	"gopkg.in/telebot.v3"
-	"gopkg.in/telebot.v3/middleware"
 )
 
 type Bot struct {
-	ConfigManager  *wireguard.ConfigManager
+	ConfigManager  *audit.ConfigManager
 	PollingTimeout time.Duration
+	// Optional poller, long polling is used if it is not set
+	Poller telebot.Poller
+	// Optional Bot API server URL, default one is used if it is not set
+	URL string
 	*CommandController
+	AccessRequests *AccessRequestStore
+	Invites        *InviteStore
+	Languages      *LanguageStore
+	// Optional store of changes staged with /batch, they are lost on restart without it
+	Batches *BatchStore
 	Token   string
 	UserIDs []int64
+	// Group chats the bot works in, any group it is added to is allowed if empty
+	GroupIDs []int64
+	// Optional notifier mirroring events to a log channel
+	Events *EventNotifier
+	// Formatting of messages with client configs, MarkdownV2 is used if not set
+	Format render.Mode
+	// Guards UserIDs and GroupIDs, which can be changed with Reconfigure while the bot is running
+	settingsMu sync.RWMutex
+	telebot    *telebot.Bot
 }
 
 func handleError(err error, ctx telebot.Context) {
 	log.Println(err)
 }
 
-func (bot *Bot) Start() error {
-	pref := telebot.Settings{
-		Token: bot.Token,
-		Poller: &telebot.LongPoller{
+// settings are the ones which can be changed with Reconfigure while the bot is running.
+type settings struct {
+	UserIDs  []int64
+	GroupIDs []int64
+}
+
+// settingsKey is the context key settings of the update are stored under.
+const settingsKey = "settings"
+
+// currentSettings returns a snapshot of the settings. Reconfigure replaces the slices
+// instead of changing them, so the snapshot stays as it is.
+func (bot *Bot) currentSettings() *settings {
+	bot.settingsMu.RLock()
+	defer bot.settingsMu.RUnlock()
+	return &settings{UserIDs: bot.UserIDs, GroupIDs: bot.GroupIDs}
+}
+
+// settings returns settings the update is processed with.
+func (bot *Bot) settings(ctx telebot.Context) *settings {
+	if s, ok := ctx.Get(settingsKey).(*settings); ok {
+		return s
+	}
+	return bot.currentSettings()
+}
+
+// isAdmin reports whether the user is whitelisted.
+func (bot *Bot) isAdmin(ctx telebot.Context, user *telebot.User) bool {
+	return user != nil && containsID(bot.settings(ctx).UserIDs, user.ID)
+}
+
+// withSettings takes a snapshot of settings, so they don't change while an update is being processed.
+// Handlers run without holding the lock, so a slow one doesn't block Reconfigure.
+func (bot *Bot) withSettings(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		ctx.Set(settingsKey, bot.currentSettings())
+		ctx.Set(formatKey, bot.format())
+		return next(ctx)
+	}
+}
+
+func (bot *Bot) format() render.Mode {
+	if bot.Format == render.Plain {
+		return render.MarkdownV2
+	}
+	return bot.Format
+}
+
+// Reconfigure applies new settings to the running bot. Every update is authorized
+// either with old or with new users and groups, never with a mix of them. The endpoint
+// is changed under the same lock, so updates which start after the new users are
+// authorized get client configs with the new endpoint.
+func (bot *Bot) Reconfigure(userIDs []int64, groupIDs []int64, hostname string, dns string) {
+	bot.settingsMu.Lock()
+	defer bot.settingsMu.Unlock()
+	bot.ConfigManager.SetEndpoint(hostname, dns)
+	bot.UserIDs = userIDs
+	bot.GroupIDs = groupIDs
+}
+
+func (bot *Bot) authorize(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		sender := ctx.Sender()
+		if sender == nil {
+			return nil
+		}
+		if bot.isAdmin(ctx, sender) {
+			return next(ctx)
+		}
+		bot.reportUnauthorized(ctx)
+		return nil
+	}
+}
+
+// reportUnauthorized logs the attempt of the sender to do what only whitelisted users can.
+func (bot *Bot) reportUnauthorized(ctx telebot.Context) {
+	action := ctx.Text()
+	if ctx.Callback() != nil {
+		action = "button " + ctx.Callback().Unique
+	}
+	event := fmt.Sprintf("Unauthorized access attempt by %s: %s", formatUser(ctx.Sender()), action)
+	log.Println(event)
+	if bot.Events != nil {
+		bot.Events.Post(event)
+	}
+}
+
+// init creates Telegram bot and registers all handlers.
+func (bot *Bot) init() error {
+	poller := bot.Poller
+	if poller == nil {
+		poller = &telebot.LongPoller{
 			Timeout: bot.PollingTimeout,
-		},
+		}
+	}
+	pref := telebot.Settings{
+		URL:     bot.URL,
+		Token:   bot.Token,
+		Poller:  poller,
 		OnError: handleError,
 	}
 
@@ -34,51 +148,223 @@ func (bot *Bot) Start() error {
 	if err != nil {
 		return err
 	}
+	bot.telebot = b
 
-	b.Use(middleware.Whitelist(bot.UserIDs...))
+	b.Use(bot.withSettings)
+	b.Use(bot.withGroups)
+	b.Use(bot.withLanguage)
 
-	b.Handle("/add_peer", func(ctx telebot.Context) error {
-		bot.CommandController.Start(&AddPeerCommand{
-			ConfigManager: bot.ConfigManager,
-		}, ctx)
+	// Access requests and invites are the only things available to users outside of the whitelist
+	b.Handle("/start", bot.start)
+	b.Handle("/request_access", bot.requestAccess)
+	b.Handle("/language", bot.changeLanguage)
+	b.Handle(&languageButton, bot.selectLanguage)
+	b.Handle("/cancel", func(ctx telebot.Context) error {
+		if !bot.CommandController.Cancel(ctx) {
+			return ctx.Send(tr(ctx, i18n.NoActiveCommand))
+		}
+		return ctx.Send(tr(ctx, i18n.CommandCancelled), telebot.RemoveKeyboard)
+	})
+
+	// Conversations can only be started by whitelisted users or by following an invite link.
+	// Other users may only answer their invite in a private chat. Other conversations of users
+	// who were removed from the whitelist since starting them, e.g. with SIGHUP or while the bot
+	// was stopped, are cancelled.
+	b.Handle(telebot.OnText, func(ctx telebot.Context) error {
+		if !bot.isAdmin(ctx, ctx.Sender()) {
+			if ctx.Chat().Type != telebot.ChatPrivate {
+				return nil
+			}
+			if bot.CommandController.CancelUnless(ctx, isInviteCommand) {
+				bot.reportUnauthorized(ctx)
+				return ctx.Send(tr(ctx, i18n.CommandCancelled), telebot.RemoveKeyboard)
+			}
+		}
+		bot.CommandController.HandleInput(ctx)
 		return nil
 	})
 
-	b.Handle("/remove_peer", func(ctx telebot.Context) error {
-		bot.CommandController.Start(&RemovePeerCommand{
-			ConfigManager: bot.ConfigManager,
-		}, ctx)
+	admin := b.Group()
+	admin.Use(bot.authorize)
+
+	admin.Handle(&commandButton, func(ctx telebot.Context) error {
+		if !bot.CommandController.HandleInput(ctx) {
+			return ctx.Respond(&telebot.CallbackResponse{Text: tr(ctx, i18n.ListOutdated)})
+		}
+		return nil
+	})
+	admin.Handle(&approveAccessButton, bot.approveAccess)
+	admin.Handle(&rejectAccessButton, bot.rejectAccess)
+
+	admin.Handle("/add_peer", func(ctx telebot.Context) error {
+		bot.CommandController.Start(chat.NewAddPeerCommand(bot.ConfigManager), ctx)
 		return nil
 	})
 
-	b.Handle("/client_config", func(ctx telebot.Context) error {
-		bot.CommandController.Start(&ClientConfigCommand{
+	admin.Handle("/remove_peer", func(ctx telebot.Context) error {
+		bot.CommandController.Start(chat.NewRemovePeerCommand(bot.ConfigManager), ctx)
+		return nil
+	})
+
+	admin.Handle("/client_config", func(ctx telebot.Context) error {
+		bot.CommandController.Start(chat.NewClientConfigCommand(bot.ConfigManager), ctx)
+		return nil
+	})
+
+	admin.Handle("/find", func(ctx telebot.Context) error {
+		bot.CommandController.Start(&chat.FindCommand{
 			ConfigManager: bot.ConfigManager,
+			Query:         ctx.Message().Payload,
 		}, ctx)
 		return nil
 	})
 
-	b.Handle(telebot.OnText, func(ctx telebot.Context) error {
-		bot.CommandController.HandleInput(ctx)
+	// Shown without going through CommandController, so the active command isn't cancelled
+	admin.Handle("/ipam", func(ctx telebot.Context) error {
+		(&chat.IPAMCommand{ConfigManager: bot.ConfigManager}).Start(newConversation(ctx))
+		return nil
+	})
+	admin.Handle("/export", func(ctx telebot.Context) error {
+		(&chat.ExportCommand{ConfigManager: bot.ConfigManager, Format: ctx.Message().Payload}).Start(newConversation(ctx))
 		return nil
 	})
 
-	b.SetCommands([]telebot.Command{
-		{
-			Text:        "add_peer",
-			Description: "Add new peer to server configuration",
-		},
-		{
-			Text:        "remove_peer",
-			Description: "Remove peer from server configuration",
-		},
-		{
-			Text:        "client_config",
-			Description: "Get client config for the specific peer",
-		},
+	admin.Handle("/doctor", func(ctx telebot.Context) error {
+		bot.CommandController.Start(&chat.DoctorCommand{ConfigManager: bot.ConfigManager}, ctx)
+		return nil
 	})
 
-	b.Start()
+	// Batch mode is shown and dropped without going through CommandController, so the active command isn't cancelled
+	admin.Handle("/batch", func(ctx telebot.Context) error {
+		(&chat.BatchCommand{ConfigManager: bot.ConfigManager}).Start(newConversation(ctx))
+		return nil
+	})
+	admin.Handle("/abort", func(ctx telebot.Context) error {
+		(&chat.AbortCommand{ConfigManager: bot.ConfigManager}).Start(newConversation(ctx))
+		return nil
+	})
+	admin.Handle("/commit", func(ctx telebot.Context) error {
+		bot.CommandController.Start(&chat.CommitCommand{ConfigManager: bot.ConfigManager}, ctx)
+		return nil
+	})
+
+	admin.Handle("/import", func(ctx telebot.Context) error {
+		return ctx.Send(importHint(language(ctx)))
+	})
+	admin.Handle(telebot.OnDocument, bot.importDocument)
 
+	admin.Handle("/audit", bot.showAudit)
+
+	admin.Handle("/invite", bot.createInvite)
+	admin.Handle("/invites", bot.listInvites)
+	admin.Handle("/revoke_invite", bot.revokeInvite)
+
+	bot.CommandController.Register("add_peer", func() chat.PersistentCommand {
+		return chat.NewAddPeerCommand(bot.ConfigManager)
+	})
+	bot.CommandController.Register("remove_peer", func() chat.PersistentCommand {
+		return chat.NewRemovePeerCommand(bot.ConfigManager)
+	})
+	bot.CommandController.Register("client_config", func() chat.PersistentCommand {
+		return chat.NewClientConfigCommand(bot.ConfigManager)
+	})
+	bot.CommandController.Register(inviteCommandName, func() chat.PersistentCommand {
+		return NewInviteCommand(bot.ConfigManager, bot.Invites, bot.Languages, "")
+	})
 	return nil
 }
+
+// Start processes updates until the stop channel is closed.
+func (bot *Bot) Start(stop <-chan struct{}) error {
+	err := bot.init()
+	if err != nil {
+		return err
+	}
+	bot.run(stop)
+	return nil
+}
+
+// run starts background jobs and processes updates until the stop channel is closed.
+func (bot *Bot) run(stop <-chan struct{}) {
+	b := bot.telebot
+
+	bot.restoreBatches(b)
+
+	err := bot.CommandController.Resume(b)
+	if err != nil {
+		log.Println(err)
+	}
+
+	bot.setCommands(b)
+
+	if bot.Events != nil {
+		go bot.Events.Run(b, stop)
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				bot.pruneAccessRequests(b)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		<-stop
+		b.Stop()
+	}()
+
+	b.Start()
+}
+
+// botCommands are commands shown in Telegram clients, with their descriptions.
+var botCommands = []struct {
+	Text        string
+	Description i18n.Key
+}{
+	{"add_peer", i18n.CommandAddPeer},
+	{"remove_peer", i18n.CommandRemovePeer},
+	{"client_config", i18n.CommandClientConfig},
+	{"find", i18n.CommandFind},
+	{"ipam", i18n.CommandIPAM},
+	{"doctor", i18n.CommandDoctor},
+	{"batch", i18n.CommandBatch},
+	{"commit", i18n.CommandCommit},
+	{"abort", i18n.CommandAbort},
+	{"import", i18n.CommandImport},
+	{"export", i18n.CommandExport},
+	{"audit", i18n.CommandAudit},
+	{"invite", i18n.CommandInvite},
+	{"invites", i18n.CommandInvites},
+	{"revoke_invite", i18n.CommandRevokeInvite},
+	{"cancel", i18n.CommandCancel},
+	{"request_access", i18n.CommandRequestAccess},
+	{"language", i18n.CommandLanguage},
+}
+
+// setCommands registers commands with descriptions in every supported language. Commands in the
+// default language are registered without a language code, so they are shown to everyone else.
+func (bot *Bot) setCommands(b *telebot.Bot) {
+	for _, lang := range i18n.Languages() {
+		commands := []telebot.Command{}
+		for _, command := range botCommands {
+			commands = append(commands, telebot.Command{
+				Text:        command.Text,
+				Description: i18n.Translate(lang, command.Description),
+			})
+		}
+		languageCode := lang
+		if lang == i18n.DefaultLanguage {
+			languageCode = ""
+		}
+		err := b.SetCommands(commands, languageCode)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -1,32 +1,319 @@
 package telegram
 
 import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/chat"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
 	"gopkg.in/telebot.v3"
 )
 
+const DefaultConversationTimeout = 10 * time.Minute
+
+// conversationKey identifies a conversation. Every user has their own conversation
+// in every chat, so several people could run commands in the same group at once.
+type conversationKey struct {
+	ChatID int64
+	UserID int64
+}
+
+func keyOf(ctx telebot.Context) conversationKey {
+	key := conversationKey{ChatID: ctx.Chat().ID}
+	if sender := ctx.Sender(); sender != nil {
+		key.UserID = sender.ID
+	}
+	return key
+}
+
+// timer is the part of *time.Timer the controller uses, so tests could expire conversations at will.
+type timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type conversation struct {
+	// Serializes input handling, so command state is never accessed concurrently
+	mu    sync.Mutex
+	cmd   chat.Command
+	timer timer
+	// Language of the user who started the command
+	language string
+	// Forum topic the command was started in
+	thread int
+	// Latest state of the command, nil if it can't be persisted
+	state *chat.CommandState
+}
+
+// savedConversation is how conversation is kept in the state file.
+type savedConversation struct {
+	ChatID int64
+	// Zero in conversations saved before group chats were supported, which were private ones
+	UserID    int64
+	ThreadID  int
+	State     *chat.CommandState
+	Language  string
+	UpdatedAt time.Time
+}
+
+func (s *savedConversation) key() conversationKey {
+	if s.UserID == 0 {
+		return conversationKey{ChatID: s.ChatID, UserID: s.ChatID}
+	}
+	return conversationKey{ChatID: s.ChatID, UserID: s.UserID}
+}
+
+// CommandController keeps track of active commands, one per user in every chat. It is safe for concurrent use.
 type CommandController struct {
-	Commands map[int64]Command
+	// Conversation is cancelled if there was no input during this time
+	Timeout time.Duration
+	// File to keep conversations in, so they could be resumed after restart
+	FilePath      string
+	mu            sync.Mutex
+	conversations map[conversationKey]*conversation
+	saved         map[conversationKey]*savedConversation
+	factories     map[string]func() chat.PersistentCommand
+	// afterFunc starts timeout of a conversation, it is time.AfterFunc unless replaced by tests
+	afterFunc func(d time.Duration, f func()) timer
 }
 
-func NewCommandController() *CommandController {
+func NewCommandController(timeout time.Duration, filePath string) *CommandController {
 	return &CommandController{
-		Commands: map[int64]Command{},
+		Timeout:       timeout,
+		FilePath:      filePath,
+		conversations: map[conversationKey]*conversation{},
+		saved:         map[conversationKey]*savedConversation{},
+		factories:     map[string]func() chat.PersistentCommand{},
+		afterFunc: func(d time.Duration, f func()) timer {
+			return time.AfterFunc(d, f)
+		},
 	}
 }
 
-func (cc *CommandController) Start(cmd Command, ctx telebot.Context) {
-	result := cmd.Start(ctx)
-	if !result {
-		cc.Commands[ctx.Chat().ID] = cmd
+// Register registers factory for the command with the given name, so it could be resumed after restart.
+func (cc *CommandController) Register(name string, factory func() chat.PersistentCommand) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.factories[name] = factory
+}
+
+// save writes conversations to the state file, cc.mu must be held.
+func (cc *CommandController) save() {
+	saved := []*savedConversation{}
+	for _, conv := range cc.saved {
+		saved = append(saved, conv)
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		if saved[i].ChatID != saved[j].ChatID {
+			return saved[i].ChatID < saved[j].ChatID
+		}
+		return saved[i].UserID < saved[j].UserID
+	})
+	err := saveState(cc.FilePath, saved)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// snapshot updates saved state of the conversation, conv.mu must be held.
+func (cc *CommandController) snapshot(key conversationKey, conv *conversation) {
+	if cmd, ok := conv.cmd.(chat.PersistentCommand); ok {
+		conv.state = cmd.State()
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.conversations[key] != conv {
+		return
 	}
+	cc.saved[key] = &savedConversation{
+		ChatID:    key.ChatID,
+		UserID:    key.UserID,
+		ThreadID:  conv.thread,
+		State:     conv.state,
+		Language:  conv.language,
+		UpdatedAt: time.Now(),
+	}
+	cc.save()
+}
+
+// remove drops conversation if it is still the active one for the user.
+func (cc *CommandController) remove(key conversationKey, conv *conversation) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.conversations[key] != conv {
+		return false
+	}
+	conv.timer.Stop()
+	delete(cc.conversations, key)
+	delete(cc.saved, key)
+	cc.save()
+	return true
 }
 
-func (cc *CommandController) HandleInput(ctx telebot.Context) {
-	cmd := cc.Commands[ctx.Chat().ID]
-	if cmd != nil {
-		result := cmd.HandleInput(ctx)
-		if result {
-			delete(cc.Commands, ctx.Chat().ID)
+func (cc *CommandController) expire(b *telebot.Bot, key conversationKey, conv *conversation) {
+	if cc.remove(key, conv) {
+		_, err := b.Send(&telebot.Chat{ID: key.ChatID}, i18n.Translate(conv.language, i18n.CommandTimedOut), &telebot.SendOptions{
+			ThreadID:    conv.thread,
+			ReplyMarkup: &telebot.ReplyMarkup{RemoveKeyboard: true},
+		})
+		if err != nil {
+			log.Println(err)
 		}
 	}
 }
+
+// activate makes conversation the active one for the user.
+func (cc *CommandController) activate(b *telebot.Bot, key conversationKey, conv *conversation) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	previous := cc.conversations[key]
+	if previous != nil {
+		previous.timer.Stop()
+	}
+	cc.conversations[key] = conv
+	conv.timer = cc.afterFunc(cc.Timeout, func() {
+		cc.expire(b, key, conv)
+	})
+}
+
+// Start starts the command, cancelling currently active one of the same user in the same chat.
+func (cc *CommandController) Start(cmd chat.Command, ctx telebot.Context) {
+	if cc.Cancel(ctx) {
+		ctx.Send(tr(ctx, i18n.PreviousCommandCancelled))
+	}
+	// Conversation is activated before the command starts, so input which comes
+	// right after the first prompt waits for the command instead of being dropped
+	conv := &conversation{cmd: cmd, language: language(ctx)}
+	if msg := ctx.Message(); msg != nil && msg.TopicMessage {
+		conv.thread = msg.ThreadID
+	}
+	key := keyOf(ctx)
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	cc.activate(ctx.Bot(), key, conv)
+	if cmd.Start(newConversation(ctx)) {
+		cc.remove(key, conv)
+		return
+	}
+	cc.snapshot(key, conv)
+}
+
+// HandleInput passes input to the active command of the sender. It returns false if there is no active command.
+func (cc *CommandController) HandleInput(ctx telebot.Context) bool {
+	key := keyOf(ctx)
+	cc.mu.Lock()
+	conv := cc.conversations[key]
+	cc.mu.Unlock()
+	if conv == nil {
+		return false
+	}
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	cc.mu.Lock()
+	active := cc.conversations[key] == conv
+	if active {
+		conv.timer.Reset(cc.Timeout)
+	}
+	cc.mu.Unlock()
+	if !active {
+		// Conversation was cancelled or replaced while waiting for the lock
+		return false
+	}
+
+	result := conv.cmd.HandleInput(newConversation(ctx))
+	if result {
+		cc.remove(key, conv)
+	} else {
+		cc.snapshot(key, conv)
+	}
+	return true
+}
+
+// CancelUnless drops the active command of the sender unless allowed reports true for it. It returns
+// true if the command was dropped.
+func (cc *CommandController) CancelUnless(ctx telebot.Context, allowed func(cmd chat.Command) bool) bool {
+	key := keyOf(ctx)
+	cc.mu.Lock()
+	conv := cc.conversations[key]
+	cc.mu.Unlock()
+	if conv == nil {
+		return false
+	}
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	if allowed(conv.cmd) {
+		return false
+	}
+	return cc.remove(key, conv)
+}
+
+// Cancel drops the active command of the sender. It returns false if there was no active command.
+func (cc *CommandController) Cancel(ctx telebot.Context) bool {
+	key := keyOf(ctx)
+	cc.mu.Lock()
+	conv := cc.conversations[key]
+	cc.mu.Unlock()
+	if conv == nil {
+		return false
+	}
+	// Input which is being handled is let to finish, the command could finish with it
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return cc.remove(key, conv)
+}
+
+// Resume loads conversations which were active when the bot was stopped. Conversations
+// which can be restored are resumed, other users are notified that their command was interrupted.
+func (cc *CommandController) Resume(b *telebot.Bot) error {
+	saved := []*savedConversation{}
+	err := loadState(cc.FilePath, &saved)
+	if err != nil {
+		return err
+	}
+	for _, s := range saved {
+		key := s.key()
+		savedChat := &telebot.Chat{ID: key.ChatID, Type: telebot.ChatPrivate}
+		if key.ChatID != key.UserID {
+			savedChat.Type = telebot.ChatGroup
+		}
+		ctx := groupContext{b.NewContext(telebot.Update{Message: &telebot.Message{
+			Chat:         savedChat,
+			Sender:       &telebot.User{ID: key.UserID},
+			ThreadID:     s.ThreadID,
+			TopicMessage: s.ThreadID != 0,
+		}})}
+		ctx.Set(languageKey, s.Language)
+
+		cc.mu.Lock()
+		var factory func() chat.PersistentCommand
+		if s.State != nil {
+			factory = cc.factories[s.State.Command]
+		}
+		cc.mu.Unlock()
+
+		if factory == nil || time.Since(s.UpdatedAt) > cc.Timeout {
+			ctx.Send(tr(ctx, i18n.CommandInterrupted), telebot.RemoveKeyboard)
+			continue
+		}
+
+		ctx.Send(tr(ctx, i18n.CommandResumed))
+		cmd := factory()
+		conv := &conversation{cmd: cmd, language: language(ctx), thread: s.ThreadID}
+		conv.mu.Lock()
+		if cmd.Resume(newConversation(ctx), s.State) {
+			conv.mu.Unlock()
+			continue
+		}
+		cc.activate(b, key, conv)
+		cc.snapshot(key, conv)
+		conv.mu.Unlock()
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.save()
+	return nil
+}
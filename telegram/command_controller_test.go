@@ -0,0 +1,299 @@
+package telegram
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/chat"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+// testCommand finishes after receiving "done". Its state isn't protected by a mutex
+// on purpose, so the race detector catches unsynchronized access by the controller.
+type testCommand struct {
+	inputs []string
+}
+
+func (cmd *testCommand) Start(conv chat.Conversation) bool {
+	return false
+}
+
+func (cmd *testCommand) HandleInput(conv chat.Conversation) bool {
+	cmd.inputs = append(cmd.inputs, conv.Text())
+	return conv.Text() == "done"
+}
+
+func newTestWizard(finished *map[string]string) *chat.Wizard {
+	return &chat.Wizard{
+		Name: "test",
+		Steps: []chat.WizardStep{
+			&chat.TextStep{Name: "first", Text: "Enter first"},
+			&chat.TextStep{Name: "second", Text: "Enter second"},
+			&chat.TextStep{Name: "third", Text: "Enter third"},
+		},
+		Confirmation: func(conv chat.Conversation, values map[string]string) string {
+			return "Confirm " + values["first"] + values["second"] + values["third"]
+		},
+		Finish: func(conv chat.Conversation, values map[string]string) {
+			*finished = values
+		},
+	}
+}
+
+// fakeTimer is a conversation timeout which expires only when the test fires it.
+type fakeTimer struct {
+	mu       sync.Mutex
+	duration time.Duration
+	f        func()
+	stopped  bool
+	resets   int
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := !t.stopped
+	t.stopped = true
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := !t.stopped
+	t.duration, t.stopped = d, false
+	t.resets++
+	return active
+}
+
+// fire runs the timer function as if the timeout passed, unless the timer was stopped.
+func (t *fakeTimer) fire() {
+	t.mu.Lock()
+	stopped := t.stopped
+	t.stopped = true
+	t.mu.Unlock()
+	if !stopped {
+		t.f()
+	}
+}
+
+// useFakeTimers makes the controller use fake timers and returns the list of created ones.
+func useFakeTimers(cc *CommandController) *[]*fakeTimer {
+	timers := []*fakeTimer{}
+	cc.afterFunc = func(d time.Duration, f func()) timer {
+		t := &fakeTimer{duration: d, f: f}
+		timers = append(timers, t)
+		return t
+	}
+	return &timers
+}
+
+func textContext(b *telebot.Bot, chatID int64, text string) telebot.Context {
+	return b.NewContext(telebot.Update{
+		Message: &telebot.Message{
+			Chat:   &telebot.Chat{ID: chatID},
+			Sender: &telebot.User{ID: chatID},
+			Text:   text,
+		},
+	})
+}
+
+// memberContext returns context of a message sent by the user in a group.
+func memberContext(b *telebot.Bot, chatID int64, userID int64, text string) telebot.Context {
+	return b.NewContext(telebot.Update{
+		Message: &telebot.Message{
+			Chat:   &telebot.Chat{ID: chatID, Type: telebot.ChatGroup},
+			Sender: &telebot.User{ID: userID},
+			Text:   text,
+		},
+	})
+}
+
+func TestCommandController(t *testing.T) {
+	b, sent := newTestBot(t)
+
+	t.Run("input is passed to active command until it finishes", func(t *testing.T) {
+		cc := NewCommandController(time.Minute, "")
+		cmd := &testCommand{}
+		cc.Start(cmd, textContext(b, 1, "/test"))
+		require.True(t, cc.HandleInput(textContext(b, 1, "first")))
+		require.False(t, cc.HandleInput(textContext(b, 2, "other chat")))
+		require.True(t, cc.HandleInput(textContext(b, 1, "done")))
+		require.False(t, cc.HandleInput(textContext(b, 1, "after")))
+		require.Equal(t, []string{"first", "done"}, cmd.inputs)
+	})
+
+	t.Run("users in a group have separate conversations", func(t *testing.T) {
+		cc := NewCommandController(time.Minute, "")
+		first := &testCommand{}
+		second := &testCommand{}
+		cc.Start(first, memberContext(b, -100, 1, "/first"))
+		cc.Start(second, memberContext(b, -100, 2, "/second"))
+		require.True(t, cc.HandleInput(memberContext(b, -100, 1, "one")))
+		require.True(t, cc.HandleInput(memberContext(b, -100, 2, "two")))
+		require.False(t, cc.HandleInput(memberContext(b, -100, 3, "three")))
+		require.False(t, cc.HandleInput(textContext(b, 1, "private")))
+		require.Equal(t, []string{"one"}, first.inputs)
+		require.Equal(t, []string{"two"}, second.inputs)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		cc := NewCommandController(time.Minute, "")
+		cmd := &testCommand{}
+		cc.Start(cmd, textContext(b, 1, "/test"))
+		require.True(t, cc.Cancel(textContext(b, 1, "/cancel")))
+		require.False(t, cc.Cancel(textContext(b, 1, "/cancel")))
+		require.False(t, cc.HandleInput(textContext(b, 1, "input")))
+		require.Empty(t, cmd.inputs)
+	})
+
+	t.Run("new command replaces active one", func(t *testing.T) {
+		cc := NewCommandController(time.Minute, "")
+		first := &testCommand{}
+		second := &testCommand{}
+		cc.Start(first, textContext(b, 1, "/first"))
+		cc.Start(second, textContext(b, 1, "/second"))
+		require.True(t, cc.HandleInput(textContext(b, 1, "input")))
+		require.Empty(t, first.inputs)
+		require.Equal(t, []string{"input"}, second.inputs)
+		require.Contains(t, sent.texts(), "Previous command was cancelled")
+	})
+
+	t.Run("idle conversation times out", func(t *testing.T) {
+		cc := NewCommandController(time.Minute, "")
+		timers := useFakeTimers(cc)
+		cmd := &testCommand{}
+		cc.Start(cmd, textContext(b, 1, "/test"))
+		require.Len(t, *timers, 1)
+		require.Equal(t, time.Minute, (*timers)[0].duration)
+		(*timers)[0].fire()
+		require.Contains(t, sent.texts(), "Command was cancelled due to inactivity")
+		require.False(t, cc.HandleInput(textContext(b, 1, "late")))
+		require.Empty(t, cmd.inputs)
+	})
+
+	t.Run("input resets timeout", func(t *testing.T) {
+		cc := NewCommandController(time.Minute, "")
+		timers := useFakeTimers(cc)
+		cmd := &testCommand{}
+		cc.Start(cmd, textContext(b, 1, "/test"))
+		for i := 0; i < 5; i++ {
+			require.True(t, cc.HandleInput(textContext(b, 1, "input")))
+		}
+		require.Len(t, *timers, 1)
+		require.Equal(t, 5, (*timers)[0].resets)
+		require.Equal(t, time.Minute, (*timers)[0].duration)
+
+		// Timer of the finished command is stopped
+		require.True(t, cc.HandleInput(textContext(b, 1, "done")))
+		count := len(sent.texts())
+		(*timers)[0].fire()
+		require.Len(t, sent.texts(), count)
+	})
+}
+
+func TestCommandControllerConcurrency(t *testing.T) {
+	b, _ := newTestBot(t)
+	cc := NewCommandController(time.Minute, "")
+
+	const chats = 4
+	commands := make([]*testCommand, chats)
+	for i := range commands {
+		commands[i] = &testCommand{}
+		cc.Start(commands[i], textContext(b, int64(i), "/test"))
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < chats; i++ {
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func(chatID int64) {
+				defer wg.Done()
+				for k := 0; k < 20; k++ {
+					cc.HandleInput(textContext(b, chatID, "input"))
+				}
+			}(int64(i))
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 20; k++ {
+			cc.Cancel(textContext(b, chats-1, "/cancel"))
+			cc.Start(&testCommand{}, textContext(b, chats-1, "/test"))
+		}
+	}()
+	wg.Wait()
+
+	for i := 0; i < chats-1; i++ {
+		require.Len(t, commands[i].inputs, 200)
+	}
+}
+
+func TestCommandControllerResume(t *testing.T) {
+	b, sent := newTestBot(t)
+	filePath := filepath.Join(t.TempDir(), "conversations.json")
+	var finished map[string]string
+	factory := func() chat.PersistentCommand {
+		return newTestWizard(&finished)
+	}
+
+	cc := NewCommandController(time.Minute, filePath)
+	cc.Register("test", factory)
+	cc.Start(factory(), textContext(b, 1, "/test"))
+	cc.HandleInput(textContext(b, 1, "1"))
+	cc.Start(&testCommand{}, textContext(b, 2, "/other"))
+
+	// Simulate restart
+	cc = NewCommandController(time.Minute, filePath)
+	cc.Register("test", factory)
+	require.NoError(t, cc.Resume(b))
+	messages := sent.texts()
+	require.Contains(t, messages, "Your command was interrupted by bot restart, please start it again")
+	require.Contains(t, messages, "Bot was restarted, let's continue where we left off")
+	require.Contains(t, messages, "Enter second")
+
+	require.False(t, cc.HandleInput(textContext(b, 2, "input")))
+	require.True(t, cc.HandleInput(textContext(b, 1, "2")))
+	require.True(t, cc.HandleInput(textContext(b, 1, "3")))
+	require.True(t, cc.HandleInput(textContext(b, 1, "Yes")))
+	require.Equal(t, map[string]string{"first": "1", "second": "2", "third": "3"}, finished)
+
+	// Finished conversations are not resumed again
+	cc = NewCommandController(time.Minute, filePath)
+	cc.Register("test", factory)
+	count := len(sent.texts())
+	require.NoError(t, cc.Resume(b))
+	require.Len(t, sent.texts(), count)
+}
+
+func TestCommandControllerResumeWithoutUserID(t *testing.T) {
+	b, sent := newTestBot(t)
+	filePath := filepath.Join(t.TempDir(), "conversations.json")
+	var finished map[string]string
+	factory := func() chat.PersistentCommand {
+		return newTestWizard(&finished)
+	}
+
+	cc := NewCommandController(time.Minute, filePath)
+	cc.Register("test", factory)
+	cc.Start(factory(), textContext(b, 1, "/test"))
+
+	// Conversations saved before group chats were supported don't have user ID
+	saved := []map[string]interface{}{}
+	require.NoError(t, loadState(filePath, &saved))
+	for _, conv := range saved {
+		delete(conv, "UserID")
+		delete(conv, "ThreadID")
+	}
+	require.NoError(t, saveState(filePath, saved))
+
+	cc = NewCommandController(time.Minute, filePath)
+	cc.Register("test", factory)
+	require.NoError(t, cc.Resume(b))
+	require.Contains(t, sent.texts(), "Bot was restarted, let's continue where we left off")
+	require.True(t, cc.HandleInput(textContext(b, 1, "1")))
+}
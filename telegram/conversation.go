@@ -0,0 +1,122 @@
+package telegram
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/chat"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"gopkg.in/telebot.v3"
+)
+
+// commandButton is the callback of buttons which commands attach to messages. Its Unique is
+// kept from the time peer pickers were the only ones to have buttons, so old messages still work.
+var commandButton = telebot.InlineButton{Unique: "peer"}
+
+// telegramConversation is chat.Conversation on top of Telegram update context.
+type telegramConversation struct {
+	ctx telebot.Context
+}
+
+func newConversation(ctx telebot.Context) chat.Conversation {
+	return &telegramConversation{ctx: ctx}
+}
+
+func chatUser(user *telebot.User) chat.User {
+	return chat.User{
+		ID:       user.ID,
+		Username: user.Username,
+		Name:     strings.TrimSpace(user.FirstName + " " + user.LastName),
+	}
+}
+
+func (c *telegramConversation) Sender() chat.User {
+	if sender := c.ctx.Sender(); sender != nil {
+		return chatUser(sender)
+	}
+	return chat.User{}
+}
+
+func (c *telegramConversation) Language() string {
+	return language(c.ctx)
+}
+
+func (c *telegramConversation) Text() string {
+	if c.Pressed() {
+		return ""
+	}
+	return stripBotName(c.ctx, c.ctx.Text())
+}
+
+func (c *telegramConversation) Pressed() bool {
+	return c.ctx.Callback() != nil
+}
+
+func (c *telegramConversation) Data() string {
+	return c.ctx.Data()
+}
+
+func inlineMarkup(b *telebot.Bot, buttons [][]chat.Button) *telebot.ReplyMarkup {
+	markup := b.NewMarkup()
+	rows := []telebot.Row{}
+	for _, buttonRow := range buttons {
+		row := telebot.Row{}
+		for _, button := range buttonRow {
+			row = append(row, markup.Data(button.Text, commandButton.Unique, button.Data))
+		}
+		rows = append(rows, row)
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
+func (c *telegramConversation) Send(text string, keyboard *chat.Keyboard) error {
+	if keyboard == nil {
+		return c.ctx.Send(text)
+	}
+	if keyboard.Remove {
+		return c.ctx.Send(text, telebot.RemoveKeyboard)
+	}
+	if len(keyboard.Buttons) > 0 {
+		return c.ctx.Send(text, inlineMarkup(c.ctx.Bot(), keyboard.Buttons))
+	}
+	markup := c.ctx.Bot().NewMarkup()
+	row := telebot.Row{}
+	for _, answer := range keyboard.Answers {
+		row = append(row, markup.Text(answer))
+	}
+	markup.Reply(row)
+	markup.ResizeKeyboard = true
+	return c.ctx.Send(text, markup)
+}
+
+func (c *telegramConversation) SendFormatted(msg *render.Message) error {
+	return reply(c.ctx, format(c.ctx), msg)
+}
+
+func (c *telegramConversation) SendDocument(doc *chat.Document) error {
+	return c.ctx.Send(&telebot.Document{
+		File:     telebot.FromReader(bytes.NewReader(doc.Content)),
+		FileName: doc.FileName,
+		Caption:  doc.Caption,
+	})
+}
+
+func (c *telegramConversation) Edit(text string, buttons [][]chat.Button) error {
+	if len(buttons) == 0 {
+		return c.ctx.Edit(text)
+	}
+	return c.ctx.Edit(text, inlineMarkup(c.ctx.Bot(), buttons))
+}
+
+func (c *telegramConversation) Answer(text string) error {
+	if text == "" {
+		return c.ctx.Respond()
+	}
+	return c.ctx.Respond(&telebot.CallbackResponse{Text: text})
+}
+
+func (c *telegramConversation) Notify(userID int64, text string) error {
+	_, err := c.ctx.Bot().Send(&telebot.User{ID: userID}, text)
+	return err
+}
@@ -0,0 +1,536 @@
+package telegram
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+const e2eServerConfig = `[Interface]
+Address    = 192.168.3.1/24
+ListenPort = 11111
+PrivateKey = sLsJoF6gLXYWfRcpRkA7ugzvkYX15Lpvif5oBeZeaHA=
+`
+
+const (
+	e2eAdminID   = 111
+	e2eStranger  = 999
+	e2ePublicKey = "Dc6HJYJHhm//iEeQDnXDPPtQ1u9slnkDaflP0ar4ISE="
+	e2eOtherKey  = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+	e2eGroupID   = -100123
+)
+
+const backText = "« Back"
+
+// e2eWaitTimeout is how long harness waits for the bot to reply.
+const e2eWaitTimeout = 2 * time.Second
+
+// harness runs the whole bot against a fake Bot API and a server config in a temporary
+// directory, with the stub process manager instead of the real Wireguard.
+type harness struct {
+	t          *testing.T
+	api        *fakeAPI
+	bot        *Bot
+	configPath string
+	// Wrapped by the audited ConfigManager of the bot, so tests could change its settings
+	wgConfig *wireguard.ConfigManager
+	users    map[int64]*testUser
+}
+
+func newHarness(t *testing.T, serverConfig string, userIDs ...int64) *harness {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "wg0.conf")
+	require.NoError(t, os.WriteFile(configPath, []byte(serverConfig), 0600))
+
+	wgConfig := &wireguard.ConfigManager{
+		ConfigFilePath: configPath,
+		Hostname:       "example.com",
+		DNS:            "8.8.8.8",
+		ProcessManager: &wireguard.ProcessManagerStub{},
+	}
+	configManager := audit.NewConfigManager(wgConfig, &audit.Log{FilePath: filepath.Join(dir, "audit.jsonl")})
+	accessRequests, err := NewAccessRequestStore("", time.Hour)
+	require.NoError(t, err)
+	invites, err := NewInviteStore("")
+	require.NoError(t, err)
+	languages, err := NewLanguageStore("")
+	require.NoError(t, err)
+
+	api := newFakeAPI(t)
+	bot := &Bot{
+		ConfigManager:     configManager,
+		CommandController: NewCommandController(time.Minute, ""),
+		AccessRequests:    accessRequests,
+		Invites:           invites,
+		Languages:         languages,
+		URL:               api.URL,
+		Token:             "test",
+		UserIDs:           userIDs,
+	}
+	require.NoError(t, bot.init())
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		bot.run(stop)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-stopped
+	})
+
+	return &harness{
+		t:          t,
+		api:        api,
+		bot:        bot,
+		wgConfig:   wgConfig,
+		configPath: configPath,
+		users:      map[int64]*testUser{},
+	}
+}
+
+// user returns a user chatting with the bot in a private chat.
+func (h *harness) user(id int64) *testUser {
+	u := h.users[id]
+	if u == nil {
+		u = &testUser{h: h, ID: id}
+		h.users[id] = u
+	}
+	return u
+}
+
+// member returns a user chatting with the bot in a group, in the given forum topic if threadID is not zero.
+func (h *harness) member(id int64, chatID int64, threadID int) *testUser {
+	return &testUser{h: h, ID: id, ChatID: chatID, ThreadID: threadID, sent: map[int]bool{}}
+}
+
+// requireConfig checks the server configuration file.
+func (h *harness) requireConfig(expected string) {
+	h.t.Helper()
+	content, err := os.ReadFile(h.configPath)
+	require.NoError(h.t, err)
+	require.Equal(h.t, expected, string(content))
+}
+
+// testUser sends updates on behalf of a user and reads the bot replies in order.
+type testUser struct {
+	h  *harness
+	ID int64
+	// Language of the user's Telegram client
+	LanguageCode string
+	// Group the user writes in and forum topic in it, zero for a private chat
+	ChatID   int64
+	ThreadID int
+	// IDs of messages sent by the user in the group
+	sent map[int]bool
+	// Number of messages to the user which were already read
+	read int
+}
+
+func (u *testUser) sender() *telebot.User {
+	return &telebot.User{ID: u.ID, FirstName: fmt.Sprintf("User%d", u.ID), LanguageCode: u.LanguageCode}
+}
+
+func (u *testUser) chat() *telebot.Chat {
+	if u.ChatID != 0 {
+		return &telebot.Chat{ID: u.ChatID, Type: telebot.ChatSuperGroup}
+	}
+	return &telebot.Chat{ID: u.ID, Type: telebot.ChatPrivate}
+}
+
+func (u *testUser) sends(text string) *testUser {
+	msg := &telebot.Message{
+		Sender:       u.sender(),
+		Chat:         u.chat(),
+		Text:         text,
+		Unixtime:     time.Now().Unix(),
+		ThreadID:     u.ThreadID,
+		TopicMessage: u.ThreadID != 0,
+	}
+	u.h.api.push(telebot.Update{Message: msg})
+	if u.sent != nil {
+		u.sent[msg.ID] = true
+	}
+	return u
+}
+
+// uploads sends a document with the given name, caption and content.
+func (u *testUser) uploads(fileName string, caption string, content string) *testUser {
+	id := u.h.api.upload([]byte(content))
+	msg := &telebot.Message{
+		Sender:   u.sender(),
+		Chat:     u.chat(),
+		Document: &telebot.Document{File: telebot.File{FileID: id, FileSize: int64(len(content))}, FileName: fileName},
+		Caption:  caption,
+		Unixtime: time.Now().Unix(),
+	}
+	u.h.api.push(telebot.Update{Message: msg})
+	return u
+}
+
+// clicks presses the inline button with the given text in the latest message which has it.
+func (u *testUser) clicks(text string) *testUser {
+	u.h.t.Helper()
+	messages := u.messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		for _, button := range messages[i].Buttons {
+			if button.Text != text {
+				continue
+			}
+			u.h.api.push(telebot.Update{
+				Callback: &telebot.Callback{
+					ID:     fmt.Sprint(messages[i].ID),
+					Sender: u.sender(),
+					Message: &telebot.Message{
+						ID:   messages[i].ID,
+						Chat: u.chat(),
+					},
+					Data: button.Data,
+				},
+			})
+			return u
+		}
+	}
+	require.Failf(u.h.t, "button not found", "no message to %d has %q button: %v", u.ID, text, messages)
+	return u
+}
+
+// messages returns all messages sent to the user so far. In a group these are
+// replies to the user's messages and edits of them.
+func (u *testUser) messages() []fakeMessage {
+	messages := []fakeMessage{}
+	replies := map[int]bool{}
+	for _, msg := range u.h.api.list() {
+		switch {
+		case u.ChatID == 0 && msg.ChatID == u.ID:
+		case u.ChatID != 0 && msg.ChatID == u.ChatID && (u.sent[msg.ReplyTo] || msg.Edited && replies[msg.ID]):
+			replies[msg.ID] = true
+		default:
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// next waits for the next unread message to the user.
+func (u *testUser) next() fakeMessage {
+	u.h.t.Helper()
+	deadline := time.Now().Add(e2eWaitTimeout)
+	for {
+		messages := u.messages()
+		if len(messages) > u.read {
+			u.read++
+			return messages[u.read-1]
+		}
+		if time.Now().After(deadline) {
+			require.FailNowf(u.h.t, "no reply", "user %d didn't receive a message, all messages: %v", u.ID, messages)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// receives checks that the next message to the user has the given text.
+func (u *testUser) receives(text string) *testUser {
+	u.h.t.Helper()
+	require.Equal(u.h.t, text, u.next().Text)
+	return u
+}
+
+// receivesContaining checks that the next message to the user contains the given text.
+func (u *testUser) receivesContaining(text string) *testUser {
+	u.h.t.Helper()
+	require.Contains(u.h.t, u.next().Text, text)
+	return u
+}
+
+// receivesNothing checks that the bot doesn't reply to the user.
+func (u *testUser) receivesNothing() *testUser {
+	u.h.t.Helper()
+	time.Sleep(200 * time.Millisecond)
+	require.Len(u.h.t, u.messages(), u.read)
+	return u
+}
+
+func TestEndToEnd(t *testing.T) {
+	t.Run("add and remove peer", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+
+		admin.sends("/add_peer").receives("Enter public key for new peer")
+		admin.sends("not a key").receives("Public key is not valid, please try again")
+		admin.sends(e2ePublicKey).receives("Enter peer name")
+		admin.sends("Bob laptop").receives("```\n--- a/wg0.conf\n+++ b/wg0.conf\n@@ -2,3 +2,8 @@\n" +
+			" Address    = 192.168.3.1/24\n ListenPort = 11111\n PrivateKey = (hidden)\n+\n+# Bob laptop\n+[Peer]\n" +
+			"+PublicKey  = " + e2ePublicKey + "\n+AllowedIPs = 192.168.3.2/32\n```")
+		admin.receivesContaining("Are you sure that you want to add new peer?")
+		admin.sends("Yes").receives("Peer was added successfully! Config below.")
+		admin.receivesContaining("Address: `192.168.3.2/24`")
+		h.requireConfig(e2eServerConfig + `
+# Bob laptop
+[Peer]
+PublicKey  = ` + e2ePublicKey + `
+AllowedIPs = 192.168.3.2/32
+`)
+
+		admin.sends("/remove_peer").receives("Select peer to remove")
+		admin.clicks("Bob laptop - 192.168.3.2/32").receives("Select peer to remove\nSelected: Bob laptop - 192.168.3.2/32")
+		admin.receivesContaining("-[Peer]").receivesContaining("Are you sure that you want to remove peer?")
+		admin.sends("Yes").receives("Peer was removed successfully!")
+		h.requireConfig(e2eServerConfig)
+	})
+
+	t.Run("hostile peer name", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+
+		admin.sends("/add_peer").receives("Enter public key for new peer")
+		admin.sends(e2ePublicKey).receives("Enter peer name")
+		// Line breaks would break the configuration file or add metadata to the peer
+		admin.sends("a\n\nb").receives("Name should be a single line without special characters, please try again")
+		admin.sends("bob\nowner: mallory").receives("Name should be a single line without special characters, please try again")
+		admin.sends("bob_laptop (old)").receivesContaining("+[Peer]").receivesContaining("Are you sure that you want to add new peer?")
+		admin.sends("Yes").receives("Peer was added successfully! Config below.")
+		config := admin.next()
+		require.Equal(t, "MarkdownV2", config.ParseMode)
+		require.True(t, strings.HasPrefix(config.Text, "*bob\\_laptop \\(old\\)*\n"), config.Text)
+
+		h.bot.settingsMu.Lock()
+		h.bot.Format = render.HTML
+		h.bot.settingsMu.Unlock()
+		admin.sends("/client_config").receives("Select peer to display its client configuration")
+		admin.clicks("bob_laptop (old) - 192.168.3.2/32").receivesContaining("Selected: bob_laptop (old)")
+		config = admin.next()
+		require.Equal(t, "HTML", config.ParseMode)
+		require.True(t, strings.HasPrefix(config.Text, "<b>bob_laptop (old)</b>\n"), config.Text)
+		require.Contains(t, config.Text, "PrivateKey = &lt;put your private key here&gt;")
+	})
+
+	t.Run("back and cancel", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+
+		admin.sends("/add_peer").receives("Enter public key for new peer")
+		admin.sends(e2ePublicKey).receives("Enter peer name")
+		admin.sends(backText).receives("Enter public key for new peer")
+		admin.sends("/cancel").receives("Command was cancelled")
+		admin.sends("Bob laptop").receivesNothing()
+		h.requireConfig(e2eServerConfig)
+	})
+
+	t.Run("address pool", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		h.wgConfig.Reserved = []string{".2-.9"}
+		admin := h.user(e2eAdminID)
+
+		admin.sends("/add_peer").receives("Enter public key for new peer")
+		admin.sends("/ipam").receives("*Address pool*\n" +
+			"Subnet: `192.168.3.0/24`\n" +
+			"Used: `1` / `254`\n" +
+			"Reserved: `8` \\(192\\.168\\.3\\.2\\-192\\.168\\.3\\.9\\)\n" +
+			"Free: `245`\n" +
+			"Next address: `192.168.3.10`")
+		// The active command isn't interrupted
+		admin.sends(e2ePublicKey).receives("Enter peer name")
+		admin.sends("Bob laptop").receivesContaining("+[Peer]").receivesContaining("Are you sure that you want to add new peer?")
+		admin.sends("Yes").receives("Peer was added successfully! Config below.")
+		admin.receivesContaining("Address: `192.168.3.10/24`")
+	})
+
+	t.Run("unauthorized user", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		stranger := h.user(e2eStranger)
+
+		stranger.sends("/add_peer").receivesNothing()
+		stranger.sends(e2ePublicKey).receivesNothing()
+		h.requireConfig(e2eServerConfig)
+	})
+
+	t.Run("reconfigure", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		h.bot.Reconfigure([]int64{e2eStranger}, nil, "vpn.example.org", "1.1.1.1")
+
+		h.user(e2eAdminID).sends("/add_peer").receivesNothing()
+		stranger := h.user(e2eStranger)
+		stranger.sends("/add_peer").receives("Enter public key for new peer")
+		stranger.sends(e2ePublicKey).receives("Enter peer name")
+		stranger.sends("Bob laptop").receivesContaining("+[Peer]").receivesContaining("Are you sure that you want to add new peer?")
+		stranger.sends("Yes").receives("Peer was added successfully! Config below.")
+		config := stranger.next().Text
+		require.Contains(t, config, "DNS: `1.1.1.1`")
+		require.Contains(t, config, "Endpoint: `vpn.example.org:11111`")
+	})
+
+	t.Run("admin removed during a command", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+
+		admin.sends("/add_peer").receives("Enter public key for new peer")
+		admin.sends(e2ePublicKey).receives("Enter peer name")
+		h.bot.Reconfigure([]int64{e2eStranger}, nil, "example.com", "8.8.8.8")
+		admin.sends("Bob laptop").receives("Command was cancelled")
+		admin.sends("Yes").receivesNothing()
+		h.requireConfig(e2eServerConfig)
+	})
+
+	t.Run("reconfigure while an update is processed", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := &telebot.User{ID: e2eAdminID}
+		handler := h.bot.withSettings(func(ctx telebot.Context) error {
+			// Settings aren't locked while the handler runs, and it keeps seeing the old ones
+			h.bot.Reconfigure([]int64{e2eStranger}, nil, "vpn.example.org", "1.1.1.1")
+			require.True(t, h.bot.isAdmin(ctx, admin))
+			return nil
+		})
+		update := telebot.Update{Message: &telebot.Message{Chat: &telebot.Chat{ID: e2eAdminID}, Sender: admin}}
+		require.NoError(t, handler(h.bot.telebot.NewContext(update)))
+		require.False(t, h.bot.isAdmin(h.bot.telebot.NewContext(update), admin))
+	})
+
+	t.Run("group chat", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID, e2eAdminID+1)
+		first := h.member(e2eAdminID, e2eGroupID, 7)
+		second := h.member(e2eAdminID+1, e2eGroupID, 7)
+		stranger := h.member(e2eStranger, e2eGroupID, 7)
+
+		first.sends("/add_peer@test_bot").receives("Enter public key for new peer")
+		second.sends("/add_peer").receives("Enter public key for new peer")
+		first.sends(e2ePublicKey).receives("Enter peer name")
+		second.sends(e2eOtherKey).receives("Enter peer name")
+		second.sends("/back@test_bot").receives("Enter public key for new peer")
+		second.sends(e2eOtherKey).receives("Enter peer name")
+		first.sends("Bob laptop").receivesContaining("+[Peer]").receivesContaining("Are you sure that you want to add new peer?")
+		second.sends("Alice phone").receivesContaining("+[Peer]").receivesContaining("Are you sure that you want to add new peer?")
+		first.sends("Yes").receives("Peer was added successfully! Config below.")
+		first.receivesContaining("Address: `192.168.3.2/24`")
+		// Both previews had the same address, so the second one is shown again with the next address
+		second.sends("Yes").receives("The configuration file was changed since the preview, please review the change again")
+		second.receivesContaining("+AllowedIPs = 192.168.3.3/32").receivesContaining("Are you sure that you want to add new peer?")
+		second.sends("Yes").receives("Peer was added successfully! Config below.")
+		second.receivesContaining("Address: `192.168.3.3/24`")
+		for _, msg := range append(first.messages(), second.messages()...) {
+			require.Equal(t, 7, msg.ThreadID, msg.Text)
+		}
+
+		stranger.sends("/add_peer").receivesNothing()
+		first.sends("/remove_peer@other_bot").receivesNothing()
+	})
+
+	t.Run("import peers", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+
+		admin.sends("/import").receives("Send a CSV or JSON file with name,public_key[,address] rows to import peers\n" +
+			"Reply to this message with the file, or send it with /import caption")
+		// Documents which aren't sent for /import are ignored, and don't interrupt the active command
+		admin.sends("/add_peer").receives("Enter public key for new peer")
+		admin.uploads("peers.csv", "", "Dave,"+e2ePublicKey+"\n").receivesNothing()
+		admin.sends("/cancel").receives("Command was cancelled")
+		admin.uploads("photo.png", "/import", "not peers").receives("Only CSV and JSON files can be imported")
+
+		admin.uploads("peers.csv", "/import", "Bob laptop,"+e2ePublicKey+"\nAlice,not a key\nCarol,"+e2eOtherKey+",192.168.3.10\n").
+			receivesContaining("row 2: public key \"not a key\" is not valid").
+			receivesContaining("+AllowedIPs = 192.168.3.10/32").
+			receives("Add 2 peers?")
+		admin.sends("Yes").receives("Peers were added: 2. Client configs are below")
+
+		msg := admin.next()
+		require.Equal(t, "client_configs.zip", msg.FileName)
+		archive, err := zip.NewReader(bytes.NewReader(msg.File), int64(len(msg.File)))
+		require.NoError(t, err)
+		names := []string{}
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+		}
+		require.Equal(t, []string{"Bob_laptop.conf", "Carol.conf"}, names)
+		h.requireConfig(e2eServerConfig + "\n# Bob laptop\n[Peer]\nPublicKey  = " + e2ePublicKey + "\nAllowedIPs = 192.168.3.2/32\n" +
+			"\n# Carol\n[Peer]\nPublicKey  = " + e2eOtherKey + "\nAllowedIPs = 192.168.3.10/32\n")
+
+		h.user(e2eStranger).uploads("peers.csv", "/import", "Dave,"+e2ePublicKey+"\n").receivesNothing()
+	})
+
+	t.Run("export peers", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+
+		admin.sends("/add_peer").receives("Enter public key for new peer")
+		admin.sends(e2ePublicKey).receives("Enter peer name")
+		// Exporting doesn't interrupt the active command
+		admin.sends("/export")
+		msg := admin.next()
+		require.True(t, strings.HasPrefix(msg.FileName, "peers-"), msg.FileName)
+		require.True(t, strings.HasSuffix(msg.FileName, ".csv"), msg.FileName)
+		require.Equal(t, "name,public_key,allowed_ips,disabled\n", string(msg.File))
+		admin.sends("Bob laptop").receivesContaining("+[Peer]").receivesContaining("Are you sure")
+		admin.sends("Yes").receives("Peer was added successfully! Config below.")
+		admin.receivesContaining("Bob laptop")
+
+		admin.sends("/export json")
+		msg = admin.next()
+		require.Contains(t, msg.Text, "Peers as of ")
+		require.Contains(t, string(msg.File), `"public_key": "`+e2ePublicKey+`"`)
+		h.user(e2eStranger).sends("/export").receivesNothing()
+	})
+
+	t.Run("allowed groups", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		h.bot.Reconfigure([]int64{e2eAdminID}, []int64{e2eGroupID}, "example.com", "8.8.8.8")
+
+		h.member(e2eAdminID, e2eGroupID-1, 0).sends("/add_peer").receivesNothing()
+		h.member(e2eAdminID, e2eGroupID, 0).sends("/add_peer").receives("Enter public key for new peer")
+		h.user(e2eAdminID).sends("/add_peer").receives("Enter public key for new peer")
+	})
+
+	t.Run("bot commands are registered", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		require.Eventually(t, func() bool {
+			h.api.mu.Lock()
+			defer h.api.mu.Unlock()
+			return len(h.api.commands) == len(i18n.Languages())
+		}, e2eWaitTimeout, 10*time.Millisecond)
+		h.api.mu.Lock()
+		defer h.api.mu.Unlock()
+		commands := []string{}
+		for _, command := range h.api.commands[""] {
+			commands = append(commands, command.Text)
+		}
+		require.Contains(t, strings.Join(commands, " "), "add_peer")
+		require.Equal(t, "Neuen Peer zur Serverkonfiguration hinzufügen", h.api.commands["de"][0].Description)
+	})
+
+	t.Run("language", func(t *testing.T) {
+		h := newHarness(t, e2eServerConfig, e2eAdminID)
+		admin := h.user(e2eAdminID)
+		admin.LanguageCode = "de-AT"
+
+		admin.sends("/add_peer").receives("Gib den öffentlichen Schlüssel des neuen Peers ein")
+		admin.sends("not a key").receives("Der öffentliche Schlüssel ist ungültig, bitte versuche es erneut")
+		admin.sends(e2ePublicKey).receives("Gib den Namen des Peers ein")
+		admin.sends("« Zurück").receives("Gib den öffentlichen Schlüssel des neuen Peers ein")
+		admin.sends("/cancel").receives("Befehl wurde abgebrochen")
+
+		admin.sends("/language fr").receivesContaining("Unbekannte Sprache")
+		admin.sends("/language").receives("Wähle die Sprache des Bots")
+		admin.clicks("Русский").receives("Язык бота изменён на Русский")
+		admin.sends("/add_peer").receives("Введите публичный ключ нового пира")
+		admin.sends(e2ePublicKey).receives("Введите имя пира")
+		admin.sends("Bob laptop").receivesContaining("+[Peer]").receivesContaining("Вы уверены, что хотите добавить новый пир?")
+		admin.sends("да").receives("Пир успешно добавлен! Конфигурация ниже.")
+		admin.receivesContaining("Адрес: `192.168.3.2/24`")
+
+		admin.sends("/language en").receives("Bot language was set to English")
+		admin.sends("/cancel").receives("There is no active command to cancel")
+	})
+}
@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"gopkg.in/telebot.v3"
+)
+
+const (
+	eventQueueSize     = 100
+	eventMaxAttempts   = 5
+	eventRetryInterval = time.Second
+)
+
+// EventNotifier mirrors bot events to a Telegram channel, group or forum topic.
+// Events are delivered asynchronously, so slow Telegram API never blocks the caller.
+type EventNotifier struct {
+	ChatID   int64
+	ThreadID int
+	events   chan string
+	// sleep waits between delivery attempts, tests replace it to skip the wait
+	sleep func(time.Duration)
+}
+
+func NewEventNotifier(chatID int64, threadID int) *EventNotifier {
+	return &EventNotifier{
+		ChatID:   chatID,
+		ThreadID: threadID,
+		events:   make(chan string, eventQueueSize),
+		sleep:    time.Sleep,
+	}
+}
+
+// Post queues event for delivery. Event is dropped if the queue is full.
+func (n *EventNotifier) Post(text string) {
+	select {
+	case n.events <- text:
+	default:
+		log.Printf("Event queue is full, dropping event: %s\n", text)
+	}
+}
+
+// Record implements audit.Sink
+func (n *EventNotifier) Record(entry audit.Entry) {
+	n.Post(entry.String())
+}
+
+func (n *EventNotifier) deliver(b *telebot.Bot, text string) {
+	chat := &telebot.Chat{ID: n.ChatID}
+	opts := &telebot.SendOptions{
+		ThreadID:              n.ThreadID,
+		DisableWebPagePreview: true,
+	}
+	delay := eventRetryInterval
+	for attempt := 1; ; attempt++ {
+		_, err := b.Send(chat, text, opts)
+		if err == nil {
+			return
+		}
+		if attempt == eventMaxAttempts {
+			log.Printf("Can't deliver event after %d attempts: %s\n", attempt, err)
+			return
+		}
+		var floodErr telebot.FloodError
+		if errors.As(err, &floodErr) && time.Duration(floodErr.RetryAfter)*time.Second > delay {
+			delay = time.Duration(floodErr.RetryAfter) * time.Second
+		}
+		n.sleep(delay)
+		delay *= 2
+	}
+}
+
+// Run delivers queued events until the stop channel is closed.
+func (n *EventNotifier) Run(b *telebot.Bot, stop <-chan struct{}) {
+	for {
+		select {
+		case text := <-n.events:
+			n.deliver(b, text)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestNotifier returns notifier which records delays between attempts instead of waiting.
+func newTestNotifier() (*EventNotifier, *[]time.Duration) {
+	notifier := NewEventNotifier(-100123, 7)
+	delays := []time.Duration{}
+	notifier.sleep = func(d time.Duration) {
+		delays = append(delays, d)
+	}
+	return notifier, &delays
+}
+
+func TestEventNotifierRetries(t *testing.T) {
+	b, api := newTestBot(t)
+	notifier, delays := newTestNotifier()
+	serverError := apiError{Code: 500, Description: "Internal Server Error"}
+	api.failSends(serverError, serverError)
+
+	notifier.deliver(b, "Peer added")
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *delays)
+	messages := api.list()
+	require.Len(t, messages, 1)
+	require.Equal(t, "Peer added", messages[0].Text)
+	require.Equal(t, int64(-100123), messages[0].ChatID)
+	require.Equal(t, 7, messages[0].ThreadID)
+}
+
+func TestEventNotifierFloodWait(t *testing.T) {
+	b, api := newTestBot(t)
+	notifier, delays := newTestNotifier()
+	api.failSends(
+		apiError{Code: 429, Description: "Too Many Requests: retry after 10", RetryAfter: 10},
+		apiError{Code: 429, Description: "Too Many Requests: retry after 1", RetryAfter: 1},
+	)
+
+	// The bot waits as long as Telegram asks, and backs off from there
+	notifier.deliver(b, "Peer added")
+	require.Equal(t, []time.Duration{10 * time.Second, 20 * time.Second}, *delays)
+	require.Equal(t, []string{"Peer added"}, api.texts())
+}
+
+func TestEventNotifierGivesUp(t *testing.T) {
+	b, api := newTestBot(t)
+	notifier, delays := newTestNotifier()
+	for i := 0; i < eventMaxAttempts; i++ {
+		api.failSends(apiError{Code: 500, Description: "Internal Server Error"})
+	}
+
+	notifier.deliver(b, "Peer added")
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}, *delays)
+	require.Empty(t, api.list())
+
+	// The next event is delivered as usual
+	notifier.deliver(b, "Peer removed")
+	require.Equal(t, []string{"Peer removed"}, api.texts())
+}
+
+func TestEventNotifierDropsEventsWhenQueueIsFull(t *testing.T) {
+	b, api := newTestBot(t)
+	notifier, _ := newTestNotifier()
+	for i := 0; i < eventQueueSize+10; i++ {
+		notifier.Post(fmt.Sprintf("Event %d", i))
+	}
+	require.Len(t, notifier.events, eventQueueSize)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(b, stop)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		return len(api.list()) == eventQueueSize
+	}, 5*time.Second, 10*time.Millisecond)
+	close(stop)
+	<-done
+
+	texts := api.texts()
+	require.Equal(t, "Event 0", texts[0])
+	require.Equal(t, fmt.Sprintf("Event %d", eventQueueSize-1), texts[eventQueueSize-1])
+}
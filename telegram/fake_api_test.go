@@ -0,0 +1,327 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+// fakeMessage is a message sent or edited by the bot.
+type fakeMessage struct {
+	ID     int
+	ChatID int64
+	Text   string
+	// Formatting mode of the text, empty for plain text
+	ParseMode string
+	// Inline keyboard buttons of the message
+	Buttons []telebot.InlineButton
+	// File name and content if the message is a document
+	FileName string
+	File     []byte
+	Edited   bool
+	// Message the bot replied to and forum topic of the message, if any
+	ReplyTo  int
+	ThreadID int
+}
+
+// fakeAPI is a local Bot API server. It feeds queued updates to the bot via
+// getUpdates and records messages and callback answers the bot sends back.
+type fakeAPI struct {
+	*httptest.Server
+	mu            sync.Mutex
+	updates       []telebot.Update
+	notify        chan struct{}
+	lastUpdateID  int
+	lastMessageID int
+	// IDs of messages sent by users, they don't clash with IDs of messages sent by the bot
+	lastUserMessageID int
+	messages          []*fakeMessage
+	callbackAnswers   []string
+	// Commands registered for every language code, empty code is the default
+	commands map[string][]telebot.Command
+	// Content of files uploaded by users, by file ID
+	files map[string][]byte
+	// Errors returned instead of sending the next messages
+	sendErrors []apiError
+}
+
+// apiError is an error response of the Bot API.
+type apiError struct {
+	Code        int
+	Description string
+	// Seconds to wait before the next request, if the bot is rate limited
+	RetryAfter int
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{notify: make(chan struct{}, 1), commands: map[string][]telebot.Command{}, files: map[string][]byte{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.handle))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// newTestBot returns offline bot which talks to a fake API.
+func newTestBot(t *testing.T) (*telebot.Bot, *fakeAPI) {
+	api := newFakeAPI(t)
+	b, err := telebot.NewBot(telebot.Settings{
+		URL:     api.URL,
+		Offline: true,
+	})
+	require.NoError(t, err)
+	return b, api
+}
+
+// push queues update to be returned by getUpdates.
+func (api *fakeAPI) push(update telebot.Update) {
+	api.mu.Lock()
+	api.lastUpdateID++
+	update.ID = api.lastUpdateID
+	if update.Message != nil && update.Message.ID == 0 {
+		api.lastUserMessageID++
+		update.Message.ID = 1_000_000 + api.lastUserMessageID
+	}
+	api.updates = append(api.updates, update)
+	api.mu.Unlock()
+	select {
+	case api.notify <- struct{}{}:
+	default:
+	}
+}
+
+// upload stores a file sent by a user and returns its ID.
+func (api *fakeAPI) upload(content []byte) string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	id := fmt.Sprintf("file%d", len(api.files)+1)
+	api.files[id] = content
+	return id
+}
+
+// failSends makes the API reject the next messages with the given errors.
+func (api *fakeAPI) failSends(errs ...apiError) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.sendErrors = append(api.sendErrors, errs...)
+}
+
+// list returns copies of all recorded messages.
+func (api *fakeAPI) list() []fakeMessage {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	messages := make([]fakeMessage, len(api.messages))
+	for i, msg := range api.messages {
+		messages[i] = *msg
+	}
+	return messages
+}
+
+// texts returns texts of all recorded messages.
+func (api *fakeAPI) texts() []string {
+	texts := []string{}
+	for _, msg := range api.list() {
+		texts = append(texts, msg.Text)
+	}
+	return texts
+}
+
+// answers returns texts the bot answered button presses with.
+func (api *fakeAPI) answers() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return append([]string{}, api.callbackAnswers...)
+}
+
+func (api *fakeAPI) handle(w http.ResponseWriter, r *http.Request) {
+	method := path.Base(r.URL.Path)
+	if strings.HasPrefix(r.URL.Path, "/file/") {
+		api.mu.Lock()
+		content, ok := api.files[method]
+		api.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+		return
+	}
+	params, file, err := readParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result interface{} = true
+	switch method {
+	case "getMe":
+		result = telebot.User{ID: 1, IsBot: true, Username: "test_bot"}
+	case "getUpdates":
+		result = api.getUpdates(params)
+	case "sendMessage", "sendDocument":
+		api.mu.Lock()
+		if len(api.sendErrors) > 0 {
+			apiErr := api.sendErrors[0]
+			api.sendErrors = api.sendErrors[1:]
+			api.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok":          false,
+				"error_code":  apiErr.Code,
+				"description": apiErr.Description,
+				"parameters":  map[string]interface{}{"retry_after": apiErr.RetryAfter},
+			})
+			return
+		}
+		api.mu.Unlock()
+		msg := &fakeMessage{
+			Text:      params["text"],
+			ParseMode: params["parse_mode"],
+			Buttons:   inlineButtons(params["reply_markup"]),
+		}
+		msg.ChatID, _ = strconv.ParseInt(params["chat_id"], 10, 64)
+		msg.ReplyTo, _ = strconv.Atoi(params["reply_to_message_id"])
+		msg.ThreadID, _ = strconv.Atoi(params["message_thread_id"])
+		if file != nil {
+			msg.Text = params["caption"]
+			msg.FileName = file.name
+			msg.File = file.content
+		}
+		api.mu.Lock()
+		api.lastMessageID++
+		msg.ID = api.lastMessageID
+		api.messages = append(api.messages, msg)
+		api.mu.Unlock()
+		result = resultMessage(msg)
+	case "editMessageText", "editMessageReplyMarkup":
+		msg := &fakeMessage{
+			Text:    params["text"],
+			Buttons: inlineButtons(params["reply_markup"]),
+			Edited:  true,
+		}
+		msg.ChatID, _ = strconv.ParseInt(params["chat_id"], 10, 64)
+		msg.ID, _ = strconv.Atoi(params["message_id"])
+		api.mu.Lock()
+		api.messages = append(api.messages, msg)
+		api.mu.Unlock()
+		result = resultMessage(msg)
+	case "getFile":
+		// Files are downloaded by their IDs
+		result = telebot.File{FileID: params["file_id"], FilePath: params["file_id"]}
+	case "answerCallbackQuery":
+		api.mu.Lock()
+		api.callbackAnswers = append(api.callbackAnswers, params["text"])
+		api.mu.Unlock()
+	case "setMyCommands":
+		commands := []telebot.Command{}
+		json.Unmarshal([]byte(params["commands"]), &commands)
+		api.mu.Lock()
+		api.commands[params["language_code"]] = commands
+		api.mu.Unlock()
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+// getUpdates returns queued updates, waiting a bit if there are none yet.
+func (api *fakeAPI) getUpdates(params map[string]string) []telebot.Update {
+	offset, _ := strconv.Atoi(params["offset"])
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		api.mu.Lock()
+		updates := []telebot.Update{}
+		for _, update := range api.updates {
+			if update.ID >= offset {
+				updates = append(updates, update)
+			}
+		}
+		api.mu.Unlock()
+		if len(updates) > 0 {
+			return updates
+		}
+		select {
+		case <-api.notify:
+		case <-timeout:
+			return updates
+		}
+	}
+}
+
+type uploadedFile struct {
+	name    string
+	content []byte
+}
+
+// readParams reads request parameters, which are sent either as JSON or as multipart form with a file.
+func readParams(r *http.Request) (map[string]string, *uploadedFile, error) {
+	params := map[string]string{}
+	err := r.ParseMultipartForm(1 << 20)
+	if err == nil {
+		for key, values := range r.MultipartForm.Value {
+			params[key] = values[0]
+		}
+		for _, headers := range r.MultipartForm.File {
+			f, err := headers[0].Open()
+			if err != nil {
+				return nil, nil, err
+			}
+			defer f.Close()
+			content, err := io.ReadAll(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			return params, &uploadedFile{name: headers[0].Filename, content: content}, nil
+		}
+		return params, nil, nil
+	}
+
+	raw := map[string]interface{}{}
+	err = json.NewDecoder(r.Body).Decode(&raw)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	for key, value := range raw {
+		switch value := value.(type) {
+		case string:
+			params[key] = value
+		default:
+			data, _ := json.Marshal(value)
+			params[key] = string(data)
+		}
+	}
+	return params, nil, nil
+}
+
+func inlineButtons(replyMarkup string) []telebot.InlineButton {
+	if replyMarkup == "" {
+		return nil
+	}
+	markup := telebot.ReplyMarkup{}
+	json.Unmarshal([]byte(replyMarkup), &markup)
+	buttons := []telebot.InlineButton{}
+	for _, row := range markup.InlineKeyboard {
+		buttons = append(buttons, row...)
+	}
+	return buttons
+}
+
+func resultMessage(msg *fakeMessage) map[string]interface{} {
+	return map[string]interface{}{
+		"message_id": msg.ID,
+		"chat":       map[string]interface{}{"id": msg.ChatID},
+		"text":       msg.Text,
+		"date":       time.Now().Unix(),
+	}
+}
+
+func (msg fakeMessage) String() string {
+	return fmt.Sprintf("%d: %q", msg.ChatID, msg.Text)
+}
@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"log"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+)
+
+// groupContext sends messages in group chats so they are addressed to the user who
+// sent the update: they are replies to the user's message, in the same forum topic,
+// and reply keyboards are shown to that user only.
+type groupContext struct {
+	telebot.Context
+}
+
+func (c groupContext) Send(what interface{}, opts ...interface{}) error {
+	chat := c.Chat()
+	if chat == nil || chat.Type == telebot.ChatPrivate {
+		return c.Context.Send(what, opts...)
+	}
+
+	options := &telebot.SendOptions{}
+	rest := []interface{}{}
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case *telebot.SendOptions:
+			copied := *opt
+			options = &copied
+		case *telebot.ReplyMarkup:
+			markup := *opt
+			options.ReplyMarkup = &markup
+		case telebot.Option:
+			if opt == telebot.RemoveKeyboard {
+				options.ReplyMarkup = &telebot.ReplyMarkup{RemoveKeyboard: true}
+			} else {
+				rest = append(rest, opt)
+			}
+		default:
+			rest = append(rest, opt)
+		}
+	}
+
+	msg := c.Message()
+	if msg != nil && msg.TopicMessage && options.ThreadID == 0 {
+		options.ThreadID = msg.ThreadID
+	}
+	// Buttons are pressed on the bot's own messages, there is nothing to reply to
+	if msg != nil && msg.ID != 0 && c.Callback() == nil && options.ReplyTo == nil {
+		options.ReplyTo = msg
+		options.AllowWithoutReply = true
+	}
+	if options.ReplyTo != nil && options.ReplyMarkup != nil && options.ReplyMarkup.InlineKeyboard == nil {
+		// Reply keyboard is shown to (or removed for) the author of the replied message only
+		options.ReplyMarkup.Selective = true
+	}
+	return c.Context.Send(what, append([]interface{}{options}, rest...)...)
+}
+
+// stripBotName removes the bot username from a command addressed to the bot in a group, e.g. "/back@my_bot".
+func stripBotName(ctx telebot.Context, text string) string {
+	if !strings.HasPrefix(text, "/") {
+		return text
+	}
+	return strings.TrimSuffix(text, "@"+ctx.Bot().Me.Username)
+}
+
+// withGroups ignores group chats which aren't allowed, and makes replies in groups addressed to the sender.
+// Private chats are always allowed, users are authorized separately.
+func (bot *Bot) withGroups(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		chat := ctx.Chat()
+		if chat == nil || chat.Type == telebot.ChatPrivate {
+			return next(ctx)
+		}
+		groupIDs := bot.settings(ctx).GroupIDs
+		if len(groupIDs) > 0 && !containsID(groupIDs, chat.ID) {
+			log.Printf("Ignoring update from group %d which is not in GroupIDs\n", chat.ID)
+			return nil
+		}
+		return next(groupContext{ctx})
+	}
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, current := range ids {
+		if current == id {
+			return true
+		}
+	}
+	return false
+}
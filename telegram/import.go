@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"io"
+	"path"
+	"strings"
+
+	"github.com/rem11/simple-wg-telegram-bot/chat"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"gopkg.in/telebot.v3"
+)
+
+// importMIMETypes are types of files peers can be imported from, besides ones named *.csv or *.json.
+var importMIMETypes = map[string]bool{
+	"text/csv":                    true,
+	"text/comma-separated-values": true,
+	"application/json":            true,
+}
+
+// importHint returns the hint the bot sends for /import.
+func importHint(lang string) string {
+	return i18n.Translate(lang, i18n.ImportHint) + "\n" + i18n.Translate(lang, i18n.ImportReplyHint)
+}
+
+// isImportRequest reports whether the document is sent to import peers: with /import caption,
+// or as a reply to the hint the bot sends for /import. Other documents are ignored, so sending
+// a file doesn't interrupt the active command.
+func isImportRequest(msg *telebot.Message, me *telebot.User) bool {
+	if fields := strings.Fields(msg.Caption); len(fields) > 0 {
+		command := fields[0]
+		if me != nil {
+			command = strings.TrimSuffix(command, "@"+me.Username)
+		}
+		return command == "/import"
+	}
+	reply := msg.ReplyTo
+	if reply == nil || reply.Sender == nil || me == nil || reply.Sender.ID != me.ID {
+		return false
+	}
+	for _, lang := range i18n.Languages() {
+		if reply.Text == importHint(lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// isImportFile reports whether peers could be imported from the document, judging by its name or type.
+func isImportFile(doc *telebot.Document) bool {
+	switch strings.ToLower(path.Ext(doc.FileName)) {
+	case ".csv", ".json":
+		return true
+	}
+	return importMIMETypes[doc.MIME]
+}
+
+// importDocument starts importing peers from the document sent for /import.
+func (bot *Bot) importDocument(ctx telebot.Context) error {
+	msg := ctx.Message()
+	if !isImportRequest(msg, ctx.Bot().Me) {
+		return nil
+	}
+	doc := msg.Document
+	if !isImportFile(doc) {
+		return ctx.Send(tr(ctx, i18n.ImportUnsupportedFile))
+	}
+	if doc.FileSize > chat.MaxImportSize {
+		return ctx.Send(tr(ctx, i18n.ImportTooLarge, chat.MaxImportSize/1024))
+	}
+	bot.CommandController.Start(&chat.ImportCommand{
+		ConfigManager: bot.ConfigManager,
+		Open: func() (io.ReadCloser, error) {
+			return ctx.Bot().File(&doc.File)
+		},
+	}, ctx)
+	return nil
+}
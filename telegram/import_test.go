@@ -0,0 +1,29 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+func TestIsImportRequest(t *testing.T) {
+	me := &telebot.User{ID: 10, Username: "wg_bot"}
+	require.True(t, isImportRequest(&telebot.Message{Caption: "/import"}, me))
+	require.True(t, isImportRequest(&telebot.Message{Caption: "/import@wg_bot new hires"}, me))
+	require.False(t, isImportRequest(&telebot.Message{Caption: "/import@other_bot"}, me))
+	require.False(t, isImportRequest(&telebot.Message{Caption: "peers for /import"}, me))
+	require.False(t, isImportRequest(&telebot.Message{}, me))
+
+	hint := &telebot.Message{Sender: me, Text: importHint("de")}
+	require.True(t, isImportRequest(&telebot.Message{ReplyTo: hint}, me))
+	require.False(t, isImportRequest(&telebot.Message{ReplyTo: &telebot.Message{Sender: me, Text: "Enter peer name"}}, me))
+	require.False(t, isImportRequest(&telebot.Message{ReplyTo: &telebot.Message{Sender: &telebot.User{ID: 11}, Text: hint.Text}}, me))
+}
+
+func TestIsImportFile(t *testing.T) {
+	require.True(t, isImportFile(&telebot.Document{FileName: "peers.CSV"}))
+	require.True(t, isImportFile(&telebot.Document{FileName: "peers.json"}))
+	require.True(t, isImportFile(&telebot.Document{FileName: "peers", MIME: "text/csv"}))
+	require.False(t, isImportFile(&telebot.Document{FileName: "photo.png", MIME: "image/png"}))
+}
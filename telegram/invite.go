@@ -0,0 +1,296 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rem11/simple-wg-telegram-bot/audit"
+	"github.com/rem11/simple-wg-telegram-bot/chat"
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"github.com/rem11/simple-wg-telegram-bot/render"
+	"gopkg.in/telebot.v3"
+)
+
+const defaultInviteTTL = 24 * time.Hour
+
+var errInviteNotValid = errors.New("invite is not valid")
+
+type Invite struct {
+	Token     string
+	CreatedBy int64
+	Creator   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	MaxUses   int
+	Uses      int
+}
+
+func (inv *Invite) valid() bool {
+	return inv.Uses < inv.MaxUses && time.Now().Before(inv.ExpiresAt)
+}
+
+type InviteStore struct {
+	FilePath string
+	mu       sync.Mutex
+	invites  map[string]*Invite
+}
+
+func NewInviteStore(filePath string) (*InviteStore, error) {
+	store := &InviteStore{
+		FilePath: filePath,
+		invites:  map[string]*Invite{},
+	}
+	err := loadState(filePath, &store.invites)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *InviteStore) save() {
+	err := saveState(s.FilePath, s.invites)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *InviteStore) Create(creator *telebot.User, uses int, ttl time.Duration) (*Invite, error) {
+	token, err := randomToken(18)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	inv := &Invite{
+		Token:     token,
+		CreatedBy: creator.ID,
+		Creator:   formatUser(creator),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		MaxUses:   uses,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invites[token] = inv
+	s.save()
+	return inv, nil
+}
+
+// List returns all invites which can still be used, oldest first. Invites
+// that are exhausted or expired are dropped from the store.
+func (s *InviteStore) List() []Invite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []Invite{}
+	changed := false
+	for token, inv := range s.invites {
+		if !inv.valid() {
+			delete(s.invites, token)
+			changed = true
+			continue
+		}
+		result = append(result, *inv)
+	}
+	if changed {
+		s.save()
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Revoke removes the invite. Token may be shortened to any unique prefix.
+func (s *InviteStore) Revoke(tokenPrefix string) (*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found *Invite
+	for token, inv := range s.invites {
+		if strings.HasPrefix(token, tokenPrefix) {
+			if found != nil {
+				return nil, fmt.Errorf("token prefix %s is ambiguous", tokenPrefix)
+			}
+			found = inv
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("can't find invite with token %s", tokenPrefix)
+	}
+	delete(s.invites, found.Token)
+	s.save()
+	return found, nil
+}
+
+func (s *InviteStore) Get(token string) (*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv := s.invites[token]
+	if inv == nil || !inv.valid() {
+		return nil, errInviteNotValid
+	}
+	result := *inv
+	return &result, nil
+}
+
+// Use consumes single use of the invite. Use should be given back with Release
+// if it didn't result in a new peer.
+func (s *InviteStore) Use(token string) (*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv := s.invites[token]
+	if inv == nil || !inv.valid() {
+		return nil, errInviteNotValid
+	}
+	inv.Uses++
+	s.save()
+	result := *inv
+	return &result, nil
+}
+
+func (s *InviteStore) Release(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv := s.invites[token]
+	if inv != nil && inv.Uses > 0 {
+		inv.Uses--
+		s.save()
+	}
+}
+
+// loggedTokenLength is how much of the token is logged. It is enough to tell invites apart
+// and to revoke one, but not to use it.
+const loggedTokenLength = 6
+
+// shortToken returns the beginning of the token, so it could be logged without granting access.
+func shortToken(token string) string {
+	if len(token) <= loggedTokenLength {
+		return token
+	}
+	return token[:loggedTokenLength] + "…"
+}
+
+func inviteLink(b *telebot.Bot, token string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", b.Me.Username, token)
+}
+
+func (bot *Bot) createInvite(ctx telebot.Context) error {
+	args := ctx.Args()
+	if len(args) > 2 {
+		return ctx.Send(tr(ctx, i18n.InviteUsage))
+	}
+	uses := 1
+	ttl := defaultInviteTTL
+	var err error
+	if len(args) > 0 {
+		uses, err = strconv.Atoi(args[0])
+		if err != nil || uses < 1 {
+			return ctx.Send(tr(ctx, i18n.InviteUsage))
+		}
+	}
+	if len(args) > 1 {
+		ttl, err = time.ParseDuration(args[1])
+		if err != nil || ttl <= 0 {
+			return ctx.Send(tr(ctx, i18n.InviteUsage))
+		}
+	}
+
+	inv, err := bot.Invites.Create(ctx.Sender(), uses, ttl)
+	if err != nil {
+		log.Println(err)
+		return ctx.Send(tr(ctx, i18n.InviteError))
+	}
+	log.Printf("User %s created invite %s for %d use(s)\n", inv.Creator, shortToken(inv.Token), inv.MaxUses)
+	return ctx.Send(tr(ctx, i18n.InviteCreated, inv.MaxUses, inv.ExpiresAt.Format(time.RFC1123), inviteLink(ctx.Bot(), inv.Token)))
+}
+
+func (bot *Bot) listInvites(ctx telebot.Context) error {
+	invites := bot.Invites.List()
+	if len(invites) == 0 {
+		return ctx.Send(tr(ctx, i18n.NoInvites))
+	}
+	msg := &render.Message{}
+	for _, inv := range invites {
+		msg.Text(tr(ctx, i18n.InviteLine,
+			inv.Token,
+			inv.MaxUses-inv.Uses,
+			inv.MaxUses,
+			inv.ExpiresAt.Format(time.RFC1123),
+			inv.Creator,
+		))
+	}
+	return reply(ctx, render.Plain, msg)
+}
+
+func (bot *Bot) revokeInvite(ctx telebot.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return ctx.Send(tr(ctx, i18n.RevokeInviteUsage))
+	}
+	inv, err := bot.Invites.Revoke(args[0])
+	if err != nil {
+		return ctx.Send(tr(ctx, i18n.RevokeInviteError, err))
+	}
+	log.Printf("User %s revoked invite %s\n", formatUser(ctx.Sender()), shortToken(inv.Token))
+	return ctx.Send(tr(ctx, i18n.InviteRevoked))
+}
+
+func (bot *Bot) start(ctx telebot.Context) error {
+	token := ctx.Message().Payload
+	if token == "" {
+		return ctx.Send(tr(ctx, i18n.Greeting, tr(ctx, i18n.AccessRequestUsage)))
+	}
+	_, err := bot.Invites.Get(token)
+	if err != nil {
+		return ctx.Send(tr(ctx, i18n.InviteNotValid))
+	}
+	bot.CommandController.Start(NewInviteCommand(bot.ConfigManager, bot.Invites, bot.Languages, token), ctx)
+	return nil
+}
+
+// inviteCommandName is the name of the invite conversation, the only one users outside of the whitelist may have.
+const inviteCommandName = "invite"
+
+// isInviteCommand reports whether the command is the invite conversation.
+func isInviteCommand(cmd chat.Command) bool {
+	w, ok := cmd.(*chat.Wizard)
+	return ok && w.Name == inviteCommandName
+}
+
+func NewInviteCommand(configManager *audit.ConfigManager, invites *InviteStore, languages *LanguageStore, token string) *chat.Wizard {
+	return &chat.Wizard{
+		Name: inviteCommandName,
+		Steps: []chat.WizardStep{
+			&chat.TextStep{Name: "publicKey", Text: i18n.InviteWelcome, Parse: chat.ParsePublicKey},
+			&chat.TextStep{Name: "name", Text: i18n.EnterDeviceName, Parse: chat.ParsePeerName},
+		},
+		Confirmation: func(conv chat.Conversation, values map[string]string) string {
+			return i18n.Translate(conv.Language(), i18n.InviteConfirmation, values["publicKey"], values["name"])
+		},
+		Values: map[string]string{"token": token},
+		Finish: func(conv chat.Conversation, values map[string]string) {
+			lang := conv.Language()
+			token := values["token"]
+			inv, err := invites.Use(token)
+			if err != nil {
+				conv.Send(i18n.Translate(lang, i18n.InviteNotValid), chat.RemoveKeyboard)
+				return
+			}
+			err = configManager.AddPeer(chat.Actor(conv), values["publicKey"], values["name"])
+			if err != nil {
+				invites.Release(token)
+				log.Println(err)
+				conv.Send(i18n.Translate(lang, i18n.AddPeerError), chat.RemoveKeyboard)
+				return
+			}
+			log.Printf("Invite %s was used by %s\n", shortToken(token), conv.Sender())
+			conv.Notify(inv.CreatedBy, i18n.Translate(languages.Get(inv.CreatedBy), i18n.InviteUsed, conv.Sender(), values["name"]))
+			conv.Send(i18n.Translate(lang, i18n.DeviceAdded), chat.RemoveKeyboard)
+			chat.SendClientConfig(conv, configManager, values["publicKey"])
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+func TestInviteStore(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "invites.json")
+	store, err := NewInviteStore(filePath)
+	require.NoError(t, err)
+	creator := &telebot.User{ID: 1, Username: "admin"}
+
+	t.Run("uses are counted", func(t *testing.T) {
+		inv, err := store.Create(creator, 2, time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), inv.CreatedBy)
+
+		_, err = store.Use(inv.Token)
+		require.NoError(t, err)
+		used, err := store.Use(inv.Token)
+		require.NoError(t, err)
+		require.Equal(t, 2, used.Uses)
+		_, err = store.Use(inv.Token)
+		require.ErrorIs(t, err, errInviteNotValid)
+		_, err = store.Get(inv.Token)
+		require.ErrorIs(t, err, errInviteNotValid)
+
+		// A use which didn't result in a peer is given back
+		store.Release(inv.Token)
+		_, err = store.Get(inv.Token)
+		require.NoError(t, err)
+		_, err = store.Use(inv.Token)
+		require.NoError(t, err)
+	})
+
+	t.Run("expired invites are not valid", func(t *testing.T) {
+		inv, err := store.Create(creator, 1, time.Hour)
+		require.NoError(t, err)
+		store.mu.Lock()
+		store.invites[inv.Token].ExpiresAt = time.Now().Add(-time.Minute)
+		store.mu.Unlock()
+
+		_, err = store.Get(inv.Token)
+		require.ErrorIs(t, err, errInviteNotValid)
+		_, err = store.Use(inv.Token)
+		require.ErrorIs(t, err, errInviteNotValid)
+	})
+
+	t.Run("list drops exhausted and expired invites", func(t *testing.T) {
+		inv, err := store.Create(creator, 1, time.Hour)
+		require.NoError(t, err)
+		invites := store.List()
+		require.Len(t, invites, 1)
+		require.Equal(t, inv.Token, invites[0].Token)
+		require.Len(t, store.invites, 1)
+	})
+
+	t.Run("revoke by prefix", func(t *testing.T) {
+		other, err := store.Create(creator, 1, time.Hour)
+		require.NoError(t, err)
+		_, err = store.Revoke("")
+		require.Error(t, err)
+		_, err = store.Revoke("not a token")
+		require.Error(t, err)
+
+		revoked, err := store.Revoke(other.Token[:10])
+		require.NoError(t, err)
+		require.Equal(t, other.Token, revoked.Token)
+		_, err = store.Get(other.Token)
+		require.ErrorIs(t, err, errInviteNotValid)
+	})
+
+	t.Run("invites are persisted", func(t *testing.T) {
+		invites := store.List()
+		require.Len(t, invites, 1)
+		inv, err := store.Use(invites[0].Token)
+		require.NoError(t, err)
+
+		reloaded, err := NewInviteStore(filePath)
+		require.NoError(t, err)
+		_, err = reloaded.Get(inv.Token)
+		require.ErrorIs(t, err, errInviteNotValid)
+		reloaded.Release(inv.Token)
+		saved, err := reloaded.Get(inv.Token)
+		require.NoError(t, err)
+		require.Equal(t, inv.CreatedAt.Unix(), saved.CreatedAt.Unix())
+		require.Contains(t, saved.Creator, "admin")
+	})
+}
+
+func TestShortToken(t *testing.T) {
+	require.Equal(t, "abcdef…", shortToken("abcdefghijklmnopqrstuvwx"))
+	require.Equal(t, "abc", shortToken("abc"))
+}
@@ -0,0 +1,153 @@
+package telegram
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/rem11/simple-wg-telegram-bot/i18n"
+	"gopkg.in/telebot.v3"
+)
+
+// languageKey is the context key the language of the current user is stored under.
+const languageKey = "language"
+
+var languageButton = telebot.InlineButton{Unique: "language"}
+
+type userLanguage struct {
+	Language string
+	// Language was chosen with /language command rather than taken from the Telegram client
+	Chosen bool
+}
+
+// LanguageStore keeps languages of bot users. Language chosen with /language command
+// takes precedence over the language of user's Telegram client.
+type LanguageStore struct {
+	FilePath string
+	mu       sync.Mutex
+	users    map[int64]*userLanguage
+}
+
+func NewLanguageStore(filePath string) (*LanguageStore, error) {
+	store := &LanguageStore{
+		FilePath: filePath,
+		users:    map[int64]*userLanguage{},
+	}
+	err := loadState(filePath, &store.users)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *LanguageStore) save() {
+	err := saveState(s.FilePath, s.users)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Detect returns language of the user, remembering language of the user's Telegram
+// client, so messages which aren't replies could be sent in the same language.
+func (s *LanguageStore) Detect(user *telebot.User) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.users[user.ID]
+	if current != nil && (current.Chosen || user.LanguageCode == "") {
+		return current.Language
+	}
+	lang := i18n.Match(user.LanguageCode)
+	if lang == "" {
+		lang = i18n.DefaultLanguage
+	}
+	if current == nil || current.Language != lang {
+		s.users[user.ID] = &userLanguage{Language: lang}
+		s.save()
+	}
+	return lang
+}
+
+// Get returns language of the user with the given ID.
+func (s *LanguageStore) Get(userID int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current := s.users[userID]; current != nil {
+		return current.Language
+	}
+	return i18n.DefaultLanguage
+}
+
+// Set stores language chosen by the user.
+func (s *LanguageStore) Set(userID int64, lang string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID] = &userLanguage{Language: lang, Chosen: true}
+	s.save()
+}
+
+// language returns language messages to the current user should be sent in.
+func language(ctx telebot.Context) string {
+	if lang, ok := ctx.Get(languageKey).(string); ok && lang != "" {
+		return lang
+	}
+	if sender := ctx.Sender(); sender != nil {
+		if lang := i18n.Match(sender.LanguageCode); lang != "" {
+			return lang
+		}
+	}
+	return i18n.DefaultLanguage
+}
+
+// tr translates the message to the language of the current user.
+func tr(ctx telebot.Context, key i18n.Key, args ...interface{}) string {
+	return i18n.Translate(language(ctx), key, args...)
+}
+
+// withLanguage determines language of the user the update came from.
+func (bot *Bot) withLanguage(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		if sender := ctx.Sender(); sender != nil {
+			ctx.Set(languageKey, bot.Languages.Detect(sender))
+		}
+		return next(ctx)
+	}
+}
+
+func languageList() string {
+	languages := []string{}
+	for _, lang := range i18n.Languages() {
+		languages = append(languages, lang+" ("+i18n.Name(lang)+")")
+	}
+	return strings.Join(languages, ", ")
+}
+
+func (bot *Bot) changeLanguage(ctx telebot.Context) error {
+	args := ctx.Args()
+	if len(args) == 0 {
+		markup := ctx.Bot().NewMarkup()
+		buttons := []telebot.Btn{}
+		for _, lang := range i18n.Languages() {
+			buttons = append(buttons, markup.Data(i18n.Name(lang), languageButton.Unique, lang))
+		}
+		markup.Inline(markup.Row(buttons...))
+		return ctx.Send(tr(ctx, i18n.ChooseLanguage), markup)
+	}
+	lang := i18n.Match(args[0])
+	if lang == "" {
+		return ctx.Send(tr(ctx, i18n.UnknownLanguage, languageList()))
+	}
+	bot.Languages.Set(ctx.Sender().ID, lang)
+	ctx.Set(languageKey, lang)
+	return ctx.Send(tr(ctx, i18n.LanguageChanged, i18n.Name(lang)))
+}
+
+func (bot *Bot) selectLanguage(ctx telebot.Context) error {
+	lang := i18n.Match(ctx.Data())
+	if lang == "" {
+		return ctx.Respond(&telebot.CallbackResponse{Text: tr(ctx, i18n.ListOutdated)})
+	}
+	bot.Languages.Set(ctx.Sender().ID, lang)
+	ctx.Set(languageKey, lang)
+	ctx.Respond()
+	return ctx.Edit(tr(ctx, i18n.LanguageChanged, i18n.Name(lang)))
+}
@@ -1,50 +1,56 @@
 package telegram
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
-	"strings"
 
-	"github.com/rem11/simple-wg-telegram-bot/wireguard"
+	"github.com/rem11/simple-wg-telegram-bot/render"
 	"gopkg.in/telebot.v3"
 )
 
-const clientConfig = "*Interface*\n" +
-	"Address: `%s`\n" +
-	"DNS: `%s`\n" +
-	"\n" +
-	"*Peer*\n" +
-	"Public key: `%s`\n" +
-	"Allowed IPs: `%s`\n" +
-	"Endpoint: `%s`\n" +
-	"\n" +
-	"*Config template*\n" +
-	"```\n%s\n```"
-
-const peerLine = "%d - %s %s\n"
-
-func formatClientConfig(cfg *wireguard.ClientConfig, cfgStr string) string {
-	return fmt.Sprintf(
-		clientConfig,
-		cfg.Interface.Address,
-		cfg.Interface.DNS,
-		cfg.Peer.PublicKey,
-		cfg.Peer.AllowedIPs,
-		cfg.Peer.Endpoint,
-		cfgStr,
-	)
+// formatKey is the context key the formatting mode of messages is stored under.
+const formatKey = "format"
+
+// format returns formatting mode of messages with client configs.
+func format(ctx telebot.Context) render.Mode {
+	if mode, ok := ctx.Get(formatKey).(render.Mode); ok && mode != render.Plain {
+		return mode
+	}
+	return render.MarkdownV2
+}
+
+// reply sends the message to the current chat, split into several messages if it is too long.
+func reply(ctx telebot.Context, mode render.Mode, msg *render.Message, opts ...interface{}) error {
+	for _, text := range msg.Render(mode, render.MaxLength) {
+		err := ctx.Send(text, append(opts, string(mode))...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func formatPeerList(peers []wireguard.Peer) string {
-	builder := strings.Builder{}
-	for i, peer := range peers {
-		builder.WriteString(fmt.Sprintf(peerLine, i, peer.PublicKey, peer.Name))
+// send sends the message to the recipient, split into several messages if it is too long.
+func send(b *telebot.Bot, to telebot.Recipient, mode render.Mode, msg *render.Message, opts ...interface{}) error {
+	for _, text := range msg.Render(mode, render.MaxLength) {
+		_, err := b.Send(to, text, append(opts, string(mode))...)
+		if err != nil {
+			return err
+		}
 	}
-	return builder.String()
+	return nil
+}
+
+func formatUser(user *telebot.User) string {
+	return chatUser(user).String()
 }
 
-func sendConfirmation(str string, ctx telebot.Context) {
-	reply := ctx.Bot().NewMarkup()
-	reply.Reply(reply.Row(reply.Text("Yes"), reply.Text("No")))
-	reply.OneTimeKeyboard = true
-	ctx.Send(str, reply)
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("error generating random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
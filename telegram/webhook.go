@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// Timeouts of the webhook server, so clients which send requests slowly or keep idle connections
+// can't exhaust it. Updates are small, Telegram sends them at once.
+const (
+	webhookReadHeaderTimeout = 10 * time.Second
+	webhookReadTimeout       = 30 * time.Second
+	webhookIdleTimeout       = 2 * time.Minute
+)
+
+// WebhookPoller receives updates from Telegram via webhook, as an alternative to long polling.
+type WebhookPoller struct {
+	// Address to listen on, e.g. ":8443"
+	Listen string
+	// URL Telegram sends updates to, e.g. address of a reverse proxy in front of the bot
+	PublicURL string
+	// Secret token Telegram sends with every update, requests without it are rejected
+	SecretToken string
+	// Optional TLS certificate and key, plain HTTP is served if they are not set
+	TLSCert string
+	TLSKey  string
+}
+
+// RandomSecretToken generates a secret token in the format accepted by Telegram.
+func RandomSecretToken() (string, error) {
+	return randomToken(32)
+}
+
+// Handler returns HTTP handler which accepts updates and passes them to the dest channel.
+func (p *WebhookPoller) Handler(dest chan<- telebot.Update) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := r.Header.Get(secretTokenHeader)
+		if p.SecretToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(p.SecretToken)) != 1 {
+			log.Printf("Rejected webhook request from %s with invalid secret token\n", r.RemoteAddr)
+			http.Error(w, "invalid secret token", http.StatusUnauthorized)
+			return
+		}
+		var update telebot.Update
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, "can't decode update", http.StatusBadRequest)
+			return
+		}
+		select {
+		case dest <- update:
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// server returns HTTP server which listens on the configured address and serves Handler.
+func (p *WebhookPoller) server(dest chan<- telebot.Update) *http.Server {
+	return &http.Server{
+		Addr:              p.Listen,
+		Handler:           p.Handler(dest),
+		ReadHeaderTimeout: webhookReadHeaderTimeout,
+		ReadTimeout:       webhookReadTimeout,
+		IdleTimeout:       webhookIdleTimeout,
+	}
+}
+
+// Poll implements telebot.Poller
+func (p *WebhookPoller) Poll(b *telebot.Bot, dest chan telebot.Update, stop chan struct{}) {
+	err := b.SetWebhook(&telebot.Webhook{
+		SecretToken: p.SecretToken,
+		Endpoint: &telebot.WebhookEndpoint{
+			PublicURL: p.PublicURL,
+		},
+	})
+	if err != nil {
+		b.OnError(err, nil)
+		<-stop
+		return
+	}
+
+	server := p.server(dest)
+	go func() {
+		<-stop
+		server.Shutdown(context.Background())
+	}()
+
+	if p.TLSCert != "" || p.TLSKey != "" {
+		err = server.ListenAndServeTLS(p.TLSCert, p.TLSKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		b.OnError(err, nil)
+		<-stop
+	}
+}
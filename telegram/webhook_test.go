@@ -0,0 +1,101 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+const recordedUpdate = `{
+	"update_id": 100,
+	"message": {
+		"message_id": 5,
+		"from": {"id": 42, "is_bot": false, "first_name": "Bob", "username": "bob"},
+		"chat": {"id": 42, "type": "private", "first_name": "Bob", "username": "bob"},
+		"date": 1700000000,
+		"text": "/ping"
+	}
+}`
+
+func postUpdate(t *testing.T, url, secret, body string) int {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(secretTokenHeader, secret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestWebhookHandler(t *testing.T) {
+	poller := &WebhookPoller{SecretToken: "secret"}
+	updates := make(chan telebot.Update, 1)
+	server := httptest.NewServer(poller.Handler(updates))
+	t.Cleanup(server.Close)
+
+	t.Run("update is accepted", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, postUpdate(t, server.URL, "secret", recordedUpdate))
+		update := <-updates
+		require.Equal(t, 100, update.ID)
+		require.Equal(t, "/ping", update.Message.Text)
+		require.Equal(t, int64(42), update.Message.Sender.ID)
+	})
+
+	t.Run("invalid secret token is rejected", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, postUpdate(t, server.URL, "wrong", recordedUpdate))
+		require.Equal(t, http.StatusUnauthorized, postUpdate(t, server.URL, "", recordedUpdate))
+		require.Empty(t, updates)
+	})
+
+	t.Run("invalid update is rejected", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, postUpdate(t, server.URL, "secret", "{"))
+		require.Empty(t, updates)
+	})
+
+	t.Run("only POST is allowed", func(t *testing.T) {
+		resp, err := http.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+func TestWebhookUpdateIsHandled(t *testing.T) {
+	b, sent := newTestBot(t)
+	b.Handle("/ping", func(ctx telebot.Context) error {
+		return ctx.Send("pong")
+	})
+
+	poller := &WebhookPoller{SecretToken: "secret"}
+	updates := make(chan telebot.Update)
+	server := httptest.NewServer(poller.Handler(updates))
+	t.Cleanup(server.Close)
+	go func() {
+		for update := range updates {
+			b.ProcessUpdate(update)
+		}
+	}()
+	t.Cleanup(func() { close(updates) })
+
+	require.Equal(t, http.StatusOK, postUpdate(t, server.URL, "secret", recordedUpdate))
+	require.Eventually(t, func() bool {
+		return len(sent.texts()) == 1 && sent.texts()[0] == "pong"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWebhookServerTimeouts(t *testing.T) {
+	poller := &WebhookPoller{Listen: ":8443"}
+	server := poller.server(make(chan telebot.Update))
+	require.Equal(t, ":8443", server.Addr)
+	require.NotZero(t, server.ReadHeaderTimeout)
+	require.NotZero(t, server.ReadTimeout)
+	require.NotZero(t, server.IdleTimeout)
+}
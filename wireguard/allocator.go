@@ -0,0 +1,353 @@
+package wireguard
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"sort"
+)
+
+// ErrPoolExhausted is returned when there are no free addresses left to allocate.
+var ErrPoolExhausted = errors.New("no free addresses left")
+
+// Strategy defines which of the free addresses Allocator gives out.
+type Strategy int
+
+const (
+	// LowestFree allocates the lowest free address, filling gaps left by removed peers.
+	LowestFree Strategy = iota
+	// Sequential allocates the first free address after the last allocated one,
+	// wrapping around at the end of the prefix, so addresses of removed peers aren't reused soon.
+	Sequential
+	// Random allocates a random free address, so addresses don't reveal the number or order of peers.
+	Random
+)
+
+var strategyNames = map[Strategy]string{
+	LowestFree: "lowest",
+	Sequential: "sequential",
+	Random:     "random",
+}
+
+func (s Strategy) String() string {
+	if name, ok := strategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// ParseStrategy returns allocation strategy by its name, empty name is LowestFree.
+func ParseStrategy(name string) (Strategy, error) {
+	if name == "" {
+		return LowestFree, nil
+	}
+	for strategy, strategyName := range strategyNames {
+		if strategyName == name {
+			return strategy, nil
+		}
+	}
+	return LowestFree, fmt.Errorf("unknown allocation strategy %q, it should be lowest, sequential or random", name)
+}
+
+// uint128 is an address as a number, IPv4 addresses are IPv4-mapped IPv6 ones.
+type uint128 struct {
+	hi, lo uint64
+}
+
+func addrToUint128(addr netip.Addr) uint128 {
+	b := addr.As16()
+	return uint128{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
+
+func (u uint128) addr(is4 bool) netip.Addr {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.hi)
+	binary.BigEndian.PutUint64(b[8:], u.lo)
+	addr := netip.AddrFrom16(b)
+	if is4 {
+		return addr.Unmap()
+	}
+	return addr
+}
+
+func (u uint128) add(v uint128) uint128 {
+	lo, carry := bits.Add64(u.lo, v.lo, 0)
+	hi, _ := bits.Add64(u.hi, v.hi, carry)
+	return uint128{hi, lo}
+}
+
+func (u uint128) sub(v uint128) uint128 {
+	lo, borrow := bits.Sub64(u.lo, v.lo, 0)
+	hi, _ := bits.Sub64(u.hi, v.hi, borrow)
+	return uint128{hi, lo}
+}
+
+func (u uint128) less(v uint128) bool {
+	return u.hi < v.hi || (u.hi == v.hi && u.lo < v.lo)
+}
+
+func (u uint128) big() *big.Int {
+	n := new(big.Int).SetUint64(u.hi)
+	n.Lsh(n, 64)
+	return n.Or(n, new(big.Int).SetUint64(u.lo))
+}
+
+func uint128FromBig(n *big.Int) uint128 {
+	lo := new(big.Int).And(n, new(big.Int).SetUint64(^uint64(0)))
+	return uint128{new(big.Int).Rsh(n, 64).Uint64(), lo.Uint64()}
+}
+
+// addrRange is an inclusive range of addresses.
+type addrRange struct {
+	start, end netip.Addr
+}
+
+// size returns the number of addresses in the range.
+func (r addrRange) size() uint128 {
+	return addrToUint128(r.end).sub(addrToUint128(r.start)).add(uint128{0, 1})
+}
+
+func (r addrRange) contains(addr netip.Addr) bool {
+	return r.start.Compare(addr) <= 0 && addr.Compare(r.end) <= 0
+}
+
+// Allocator gives out addresses of a prefix. Free addresses are kept as a sorted set of
+// disjoint ranges, so allocating, releasing and reserving addresses takes time depending
+// on how fragmented the pool is rather than on the size of the prefix. The first and the last
+// address of the prefix are never allocated. Allocator is not safe for concurrent use.
+type Allocator struct {
+	// Source of randomness for Random strategy, crypto/rand is used if it is nil
+	Rand io.Reader
+
+	prefix   netip.Prefix
+	strategy Strategy
+	// Addresses which could be allocated, there are none if the range isn't valid
+	hosts    addrRange
+	free     []addrRange
+	reserved []addrRange
+	last     netip.Addr
+}
+
+// NewAllocator returns allocator which has all addresses of the prefix free.
+func NewAllocator(prefix netip.Prefix, strategy Strategy) *Allocator {
+	prefix = prefix.Masked()
+	a := &Allocator{prefix: prefix, strategy: strategy}
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits < 2 {
+		// There are only network and broadcast addresses in /31 and /32
+		return a
+	}
+	hostMask := uint128{0, 0}
+	if hostBits >= 64 {
+		hostMask = uint128{1<<(hostBits-64) - 1, ^uint64(0)}
+	} else {
+		hostMask.lo = 1<<hostBits - 1
+	}
+	first := addrToUint128(prefix.Addr())
+	last := first.add(hostMask)
+	a.hosts = addrRange{
+		start: prefix.Addr().Next(),
+		end:   last.addr(prefix.Addr().Is4()).Prev(),
+	}
+	a.free = []addrRange{a.hosts}
+	return a
+}
+
+// check returns error if the address can't be allocated from the prefix.
+func (a *Allocator) check(addr netip.Addr) error {
+	if !a.prefix.Contains(addr) {
+		return fmt.Errorf("address %s doesn't belong to network %s", addr, a.prefix)
+	}
+	if addr == a.prefix.Addr() {
+		return fmt.Errorf("address %s is a network address for network %s", addr, a.prefix)
+	}
+	if !a.hosts.start.IsValid() || a.hosts.end.Less(addr) {
+		return fmt.Errorf("address %s is a broadcast address for network %s", addr, a.prefix)
+	}
+	return nil
+}
+
+// search returns index of the first free range which ends at or after the address.
+func (a *Allocator) search(addr netip.Addr) int {
+	return sort.Search(len(a.free), func(i int) bool {
+		return a.free[i].end.Compare(addr) >= 0
+	})
+}
+
+// take removes the range from free addresses.
+func (a *Allocator) take(start, end netip.Addr) {
+	i := a.search(start)
+	j := i
+	var keep [2]addrRange
+	kept := 0
+	for ; j < len(a.free) && a.free[j].start.Compare(end) <= 0; j++ {
+		r := a.free[j]
+		if r.start.Compare(start) < 0 {
+			keep[kept] = addrRange{start: r.start, end: start.Prev()}
+			kept++
+		}
+		if r.end.Compare(end) > 0 {
+			keep[kept] = addrRange{start: end.Next(), end: r.end}
+			kept++
+		}
+	}
+	a.splice(i, j, keep[:kept])
+}
+
+// splice replaces free ranges from i to j with the given ones.
+func (a *Allocator) splice(i, j int, ranges []addrRange) {
+	tail := len(a.free) - j
+	if grow := len(ranges) - (j - i); grow > 0 {
+		a.free = append(a.free, ranges[:grow]...)
+	}
+	copy(a.free[i+len(ranges):], a.free[j:j+tail])
+	a.free = a.free[:i+len(ranges)+tail]
+	copy(a.free[i:], ranges)
+}
+
+// isFree reports whether the address can be allocated.
+func (a *Allocator) isFree(addr netip.Addr) bool {
+	i := a.search(addr)
+	return i < len(a.free) && a.free[i].start.Compare(addr) <= 0
+}
+
+// Use marks addresses as allocated, e.g. ones of existing peers. Addresses which are already
+// taken are ignored.
+func (a *Allocator) Use(addrs ...netip.Addr) error {
+	sorted := make([]netip.Addr, len(addrs))
+	for i, addr := range addrs {
+		addr = addr.Unmap()
+		err := a.check(addr)
+		if err != nil {
+			return err
+		}
+		sorted[i] = addr
+	}
+	// Taking addresses in ascending order only changes the end of the free set
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Less(sorted[j])
+	})
+	for _, addr := range sorted {
+		a.take(addr, addr)
+	}
+	return nil
+}
+
+// Reserve excludes the range from allocation. Its part outside of the pool is ignored.
+func (a *Allocator) Reserve(start, end netip.Addr) error {
+	start, end = start.Unmap(), end.Unmap()
+	if end.Less(start) {
+		return fmt.Errorf("range %s-%s ends before it starts", start, end)
+	}
+	if !a.prefix.Contains(start) || !a.prefix.Contains(end) {
+		return fmt.Errorf("range %s-%s doesn't belong to network %s", start, end, a.prefix)
+	}
+	a.take(start, end)
+	a.reserved = append(a.reserved, addrRange{start: start, end: end})
+	return nil
+}
+
+// Seek makes Sequential strategy continue after the address, e.g. the one of the latest added peer.
+func (a *Allocator) Seek(addr netip.Addr) {
+	a.last = addr.Unmap()
+}
+
+// Allocate takes a free address according to the strategy.
+func (a *Allocator) Allocate() (netip.Addr, error) {
+	if len(a.free) == 0 {
+		return netip.Addr{}, fmt.Errorf("%w in network %s", ErrPoolExhausted, a.prefix)
+	}
+	addr := a.free[0].start
+	switch a.strategy {
+	case Sequential:
+		if !a.last.IsValid() {
+			break
+		}
+		i := sort.Search(len(a.free), func(i int) bool {
+			return a.free[i].end.Compare(a.last) > 0
+		})
+		if i < len(a.free) {
+			addr = a.free[i].start
+			if addr.Compare(a.last) <= 0 {
+				addr = a.last.Next()
+			}
+		}
+	case Random:
+		var err error
+		addr, err = a.random()
+		if err != nil {
+			return netip.Addr{}, err
+		}
+	}
+	a.take(addr, addr)
+	a.last = addr
+	return addr, nil
+}
+
+// random returns a free address chosen uniformly.
+func (a *Allocator) random() (netip.Addr, error) {
+	reader := a.Rand
+	if reader == nil {
+		reader = rand.Reader
+	}
+	n, err := rand.Int(reader, a.Free())
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("error choosing random address: %w", err)
+	}
+	offset := uint128FromBig(n)
+	for _, r := range a.free {
+		size := r.size()
+		if offset.less(size) {
+			return addrToUint128(r.start).add(offset).addr(r.start.Is4()), nil
+		}
+		offset = offset.sub(size)
+	}
+	return a.free[len(a.free)-1].end, nil
+}
+
+// Release returns allocated address to the pool.
+func (a *Allocator) Release(addr netip.Addr) error {
+	addr = addr.Unmap()
+	err := a.check(addr)
+	if err != nil {
+		return err
+	}
+	for _, r := range a.reserved {
+		if r.contains(addr) {
+			return fmt.Errorf("address %s is reserved", addr)
+		}
+	}
+	i := a.search(addr)
+	if i < len(a.free) && a.free[i].start.Compare(addr) <= 0 {
+		return fmt.Errorf("address %s is not allocated", addr)
+	}
+	// Released address may join the free ranges before and after it
+	joinPrev := i > 0 && a.free[i-1].end.Next() == addr
+	joinNext := i < len(a.free) && addr.Next() == a.free[i].start
+	switch {
+	case joinPrev && joinNext:
+		a.free[i-1].end = a.free[i].end
+		a.splice(i, i+1, nil)
+	case joinPrev:
+		a.free[i-1].end = addr
+	case joinNext:
+		a.free[i].start = addr
+	default:
+		a.splice(i, i, []addrRange{{start: addr, end: addr}})
+	}
+	return nil
+}
+
+// Free returns the number of addresses which can be allocated.
+func (a *Allocator) Free() *big.Int {
+	total := uint128{}
+	for _, r := range a.free {
+		total = total.add(r.size())
+	}
+	return total.big()
+}
@@ -0,0 +1,178 @@
+package wireguard
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func allocateAll(t *testing.T, allocator *Allocator) []string {
+	addrs := []string{}
+	for {
+		addr, err := allocator.Allocate()
+		if err != nil {
+			require.ErrorIs(t, err, ErrPoolExhausted)
+			return addrs
+		}
+		addrs = append(addrs, addr.String())
+	}
+}
+
+func TestAllocator(t *testing.T) {
+	t.Run("lowest free", func(t *testing.T) {
+		allocator := NewAllocator(netip.MustParsePrefix("10.0.0.0/29"), LowestFree)
+		require.Equal(t, "6", allocator.Free().String())
+		require.NoError(t, allocator.Use(netip.MustParseAddr("10.0.0.2")))
+		require.Equal(t, []string{"10.0.0.1", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}, allocateAll(t, allocator))
+
+		require.NoError(t, allocator.Release(netip.MustParseAddr("10.0.0.4")))
+		require.NoError(t, allocator.Release(netip.MustParseAddr("10.0.0.2")))
+		require.Equal(t, "2", allocator.Free().String())
+		require.Equal(t, []string{"10.0.0.2", "10.0.0.4"}, allocateAll(t, allocator))
+	})
+
+	t.Run("sequential", func(t *testing.T) {
+		allocator := NewAllocator(netip.MustParsePrefix("10.0.0.0/29"), Sequential)
+		require.NoError(t, allocator.Use(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.4")))
+		allocator.Seek(netip.MustParseAddr("10.0.0.4"))
+		require.Equal(t, []string{"10.0.0.5", "10.0.0.6", "10.0.0.2", "10.0.0.3"}, allocateAll(t, allocator))
+
+		// Released address isn't reused until the end of the prefix is reached
+		require.NoError(t, allocator.Release(netip.MustParseAddr("10.0.0.1")))
+		require.NoError(t, allocator.Release(netip.MustParseAddr("10.0.0.5")))
+		addr, err := allocator.Allocate()
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.5", addr.String())
+	})
+
+	t.Run("random", func(t *testing.T) {
+		allocator := NewAllocator(netip.MustParsePrefix("fd00::/125"), Random)
+		allocator.Rand = rand.New(rand.NewSource(1))
+		require.NoError(t, allocator.Reserve(netip.MustParseAddr("fd00::2"), netip.MustParseAddr("fd00::3")))
+		addrs := allocateAll(t, allocator)
+		require.ElementsMatch(t, []string{"fd00::1", "fd00::4", "fd00::5", "fd00::6"}, addrs)
+		require.NotEqual(t, []string{"fd00::1", "fd00::4", "fd00::5", "fd00::6"}, addrs)
+	})
+
+	t.Run("large prefix", func(t *testing.T) {
+		allocator := NewAllocator(netip.MustParsePrefix("fd00:1:2:3:4::/48"), LowestFree)
+		free := new(big.Int).Lsh(big.NewInt(1), 80)
+		require.Equal(t, free.Sub(free, big.NewInt(2)), allocator.Free())
+		require.NoError(t, allocator.Reserve(netip.MustParseAddr("fd00:1:2::"), netip.MustParseAddr("fd00:1:2::ffff")))
+		addr, err := allocator.Allocate()
+		require.NoError(t, err)
+		require.Equal(t, "fd00:1:2::1:0", addr.String())
+
+		allocator = NewAllocator(netip.MustParsePrefix("::/0"), Random)
+		addr, err = allocator.Allocate()
+		require.NoError(t, err)
+		require.True(t, addr.Is6())
+	})
+
+	t.Run("invalid addresses", func(t *testing.T) {
+		allocator := NewAllocator(netip.MustParsePrefix("10.0.0.0/29"), LowestFree)
+		require.NoError(t, allocator.Reserve(netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.0.0.2")))
+		require.ErrorContains(t, allocator.Use(netip.MustParseAddr("10.0.0.7")), "broadcast address")
+		require.ErrorContains(t, allocator.Use(netip.MustParseAddr("10.0.0.0")), "network address")
+		require.ErrorContains(t, allocator.Use(netip.MustParseAddr("10.0.1.1")), "doesn't belong")
+		require.ErrorContains(t, allocator.Release(netip.MustParseAddr("10.0.0.2")), "reserved")
+		require.ErrorContains(t, allocator.Release(netip.MustParseAddr("10.0.0.3")), "not allocated")
+		require.ErrorContains(t, allocator.Reserve(netip.MustParseAddr("10.0.0.5"), netip.MustParseAddr("10.0.0.4")), "ends before")
+
+		_, err := NewAllocator(netip.MustParsePrefix("10.0.0.0/31"), LowestFree).Allocate()
+		require.ErrorIs(t, err, ErrPoolExhausted)
+	})
+
+	t.Run("free ranges are merged", func(t *testing.T) {
+		allocator := NewAllocator(netip.MustParsePrefix("10.0.0.0/28"), LowestFree)
+		allocateAll(t, allocator)
+		for _, addr := range []string{"10.0.0.3", "10.0.0.7", "10.0.0.5", "10.0.0.4", "10.0.0.6"} {
+			require.NoError(t, allocator.Release(netip.MustParseAddr(addr)))
+		}
+		require.Equal(t, []addrRange{{netip.MustParseAddr("10.0.0.3"), netip.MustParseAddr("10.0.0.7")}}, allocator.free)
+	})
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, strategy := range []Strategy{LowestFree, Sequential, Random} {
+		parsed, err := ParseStrategy(strategy.String())
+		require.NoError(t, err)
+		require.Equal(t, strategy, parsed)
+	}
+	strategy, err := ParseStrategy("")
+	require.NoError(t, err)
+	require.Equal(t, LowestFree, strategy)
+	_, err = ParseStrategy("highest")
+	require.Error(t, err)
+}
+
+// benchmarkPeers returns n sequential addresses starting at the second address of the network.
+func benchmarkPeers(network *net.IPNet, n int) []net.IP {
+	addrs := make([]net.IP, n)
+	addr := toAddr(network.IP)
+	for i := range addrs {
+		addr = addr.Next()
+		addrs[i] = net.IP(addr.AsSlice())
+	}
+	return addrs
+}
+
+var benchmarkPools = []struct {
+	cidr  string
+	peers int
+}{
+	{"10.0.0.0/24", 200},
+	{"fd00::/64", 5000},
+	{"fd00::/48", 20000},
+}
+
+// BenchmarkGetNextIPAddress measures what adding a peer takes: the allocator is built
+// from addresses of all peers on every call.
+func BenchmarkGetNextIPAddress(b *testing.B) {
+	for _, pool := range benchmarkPools {
+		_, network, _ := net.ParseCIDR(pool.cidr)
+		addrs := benchmarkPeers(network, pool.peers)
+		b.Run(fmt.Sprintf("%s/%d", pool.cidr, pool.peers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := getNextIPAddress(addrs, nil, *network, LowestFree)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAllocator measures allocating and releasing addresses with a long-lived allocator.
+func BenchmarkAllocator(b *testing.B) {
+	for _, strategy := range []Strategy{LowestFree, Sequential, Random} {
+		for _, pool := range benchmarkPools {
+			_, network, _ := net.ParseCIDR(pool.cidr)
+			addrs := []netip.Addr{}
+			for _, addr := range benchmarkPeers(network, pool.peers) {
+				addrs = append(addrs, toAddr(addr))
+			}
+			allocator := NewAllocator(toPrefix(*network), strategy)
+			allocator.Rand = rand.New(rand.NewSource(1))
+			if err := allocator.Use(addrs...); err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("%s/%s/%d", strategy, pool.cidr, pool.peers), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					addr, err := allocator.Allocate()
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := allocator.Release(addr); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package wireguard
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"gopkg.in/ini.v1"
+)
+
+// OpKind is a kind of change staged in a Batch.
+type OpKind string
+
+const (
+	OpAddPeer       OpKind = "add_peer"
+	OpRemovePeer    OpKind = "remove_peer"
+	OpReaddressPeer OpKind = "readdress_peer"
+	OpDisablePeer   OpKind = "disable_peer"
+	OpEnablePeer    OpKind = "enable_peer"
+)
+
+// Op is a change staged in a Batch.
+type Op struct {
+	Kind      OpKind
+	PublicKey string
+	// Peer as it is after the change, or before it if the peer is removed.
+	// It is known once the batch is previewed or committed.
+	Peer  Peer
+	saved SavedOp
+	edit  func(op *Op, cfgFile *ini.File, config *Config) error
+	// pin is called once the change is staged, to keep what was chosen for it, e.g. the allocated address
+	pin func(op *Op)
+}
+
+// SavedOp describes a staged change, so the batch could be restored after restart with RestoreBatch.
+type SavedOp struct {
+	Kind      OpKind
+	PublicKey string
+	Name      string `json:",omitempty"`
+	// Address given to the peer when the change was staged
+	Address net.IP `json:",omitempty"`
+	// Index of the peer, -1 if the peer is looked up by the public key
+	Index     int
+	AllowedIP string `json:",omitempty"`
+}
+
+// Batch stages changes, so they could be reviewed together and applied with a single save
+// and reload. Changes are applied in the order they were staged, and none of them are
+// applied if any fails. Batch is safe for concurrent use.
+type Batch struct {
+	// Staged is called after changes are staged, e.g. to save the batch. It is optional.
+	Staged func()
+
+	c   *ConfigManager
+	mu  sync.Mutex
+	ops []*Op
+}
+
+// NewBatch returns an empty batch of changes of the configuration.
+func (c *ConfigManager) NewBatch() *Batch {
+	return &Batch{c: c}
+}
+
+// edit applies staged changes to the configuration in memory.
+func (b *Batch) edit(cfgFile *ini.File, config *Config) error {
+	var err error
+	for i, op := range b.ops {
+		if i > 0 {
+			// Changes are made to the file, so it is parsed again to see the earlier ones
+			config, err = parseConfig(cfgFile)
+			if err != nil {
+				return err
+			}
+		}
+		err = op.edit(op, cfgFile, config)
+		if err != nil {
+			return fmt.Errorf("error applying change %d of the batch: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// stage adds the change to the batch if the batch still applies with it.
+func (b *Batch) stage(op *Op) error {
+	b.mu.Lock()
+	b.ops = append(b.ops, op)
+	_, err := b.c.modify(true, b.edit)
+	if err != nil {
+		b.ops = b.ops[:len(b.ops)-1]
+		b.mu.Unlock()
+		return err
+	}
+	if op.pin != nil {
+		op.pin(op)
+		op.pin = nil
+	}
+	b.mu.Unlock()
+	b.staged()
+	return nil
+}
+
+func (b *Batch) staged() {
+	if b.Staged != nil {
+		b.Staged()
+	}
+}
+
+// peerAfter sets the peer of the change to the one with the given index in the changed file.
+func (op *Op) peerAfter(cfgFile *ini.File, index int) error {
+	config, err := parseConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		index, err = getPeerIndex(config, op.PublicKey)
+		if err != nil {
+			return err
+		}
+	}
+	op.Peer = config.Peer[index]
+	return nil
+}
+
+func (b *Batch) addPeerOp(publicKey string, name string, address net.IP) *Op {
+	edit := b.c.addPeer(publicKey, name, address)
+	saved := SavedOp{Kind: OpAddPeer, PublicKey: publicKey, Name: name, Address: address, Index: -1}
+	return &Op{Kind: OpAddPeer, PublicKey: publicKey, saved: saved, edit: func(op *Op, cfgFile *ini.File, config *Config) error {
+		err := edit(cfgFile, config)
+		if err != nil {
+			return err
+		}
+		return op.peerAfter(cfgFile, len(config.Peer))
+	}}
+}
+
+// AddPeer stages AddPeer. The address is allocated once, so the peer gets the one shown in previews
+// when the batch is committed, or the commit fails if the address was taken since.
+func (b *Batch) AddPeer(publicKey string, name string) error {
+	op := b.addPeerOp(publicKey, name, nil)
+	op.pin = func(op *Op) {
+		// The address was formatted by addPeer, so it is valid
+		addr, _, _ := net.ParseCIDR(op.Peer.AllowedIPs)
+		pinned := b.addPeerOp(publicKey, name, addr)
+		op.edit, op.saved = pinned.edit, pinned.saved
+	}
+	return b.stage(op)
+}
+
+// RemovePeer stages RemovePeer.
+func (b *Batch) RemovePeer(publicKey string) error {
+	edit := removePeer(publicKey)
+	saved := SavedOp{Kind: OpRemovePeer, PublicKey: publicKey, Index: -1}
+	return b.stage(&Op{Kind: OpRemovePeer, PublicKey: publicKey, saved: saved, edit: func(op *Op, cfgFile *ini.File, config *Config) error {
+		if index, err := getPeerIndex(config, publicKey); err == nil {
+			op.Peer = config.Peer[index]
+		}
+		return edit(cfgFile, config)
+	}})
+}
+
+// ReaddressPeer stages ReaddressPeer. The index is the one in the configuration with the earlier changes applied.
+// The new address is allocated once, the same way it is for AddPeer.
+func (b *Batch) ReaddressPeer(index int, publicKey string, allowedIP string) error {
+	return b.readdressPeer(index, publicKey, allowedIP, nil)
+}
+
+func (b *Batch) readdressPeer(index int, publicKey string, allowedIP string, address net.IP) error {
+	nextIP := address
+	edit := b.c.readdressPeer(index, publicKey, allowedIP, &nextIP)
+	saved := SavedOp{Kind: OpReaddressPeer, PublicKey: publicKey, Index: index, AllowedIP: allowedIP}
+	return b.stage(&Op{Kind: OpReaddressPeer, PublicKey: publicKey, saved: saved, edit: func(op *Op, cfgFile *ini.File, config *Config) error {
+		err := edit(cfgFile, config)
+		if err != nil {
+			return err
+		}
+		return op.peerAfter(cfgFile, index)
+	}, pin: func(op *Op) {
+		// readdressPeer reuses the address found on the first run
+		op.saved.Address = nextIP
+	}})
+}
+
+// RemovePeerAt stages RemovePeerAt. The index is the one in the configuration with the earlier changes applied.
+func (b *Batch) RemovePeerAt(index int, publicKey string) error {
+	edit := removePeerAt(index, publicKey)
+	saved := SavedOp{Kind: OpRemovePeer, PublicKey: publicKey, Index: index}
+	return b.stage(&Op{Kind: OpRemovePeer, PublicKey: publicKey, saved: saved, edit: func(op *Op, cfgFile *ini.File, config *Config) error {
+		if index >= 0 && index < len(config.Peer) {
+			op.Peer = config.Peer[index]
+		}
+		return edit(cfgFile, config)
+	}})
+}
+
+// SetPeerEnabled stages DisablePeer, or EnablePeer if enabled is true.
+func (b *Batch) SetPeerEnabled(publicKey string, enabled bool) error {
+	kind := OpDisablePeer
+	if enabled {
+		kind = OpEnablePeer
+	}
+	edit := setPeerEnabled(publicKey, enabled)
+	saved := SavedOp{Kind: kind, PublicKey: publicKey, Index: -1}
+	return b.stage(&Op{Kind: kind, PublicKey: publicKey, saved: saved, edit: func(op *Op, cfgFile *ini.File, config *Config) error {
+		err := edit(cfgFile, config)
+		if err != nil {
+			return err
+		}
+		return op.peerAfter(cfgFile, -1)
+	}})
+}
+
+// Ops returns staged changes.
+func (b *Batch) Ops() []Op {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ops := make([]Op, len(b.ops))
+	for i, op := range b.ops {
+		ops[i] = *op
+	}
+	return ops
+}
+
+// Saved returns descriptions of staged changes, so the batch could be restored with RestoreBatch.
+func (b *Batch) Saved() []SavedOp {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	saved := make([]SavedOp, len(b.ops))
+	for i, op := range b.ops {
+		saved[i] = op.saved
+	}
+	return saved
+}
+
+// RestoreBatch stages the saved changes again. It fails if any of them doesn't apply anymore,
+// e.g. if the configuration file was changed in the meantime.
+func (c *ConfigManager) RestoreBatch(saved []SavedOp) (*Batch, error) {
+	b := c.NewBatch()
+	for i, op := range saved {
+		var err error
+		switch {
+		case op.Kind == OpAddPeer && op.Address != nil:
+			err = b.stage(b.addPeerOp(op.PublicKey, op.Name, op.Address))
+		case op.Kind == OpRemovePeer && op.Index < 0:
+			err = b.RemovePeer(op.PublicKey)
+		case op.Kind == OpRemovePeer:
+			err = b.RemovePeerAt(op.Index, op.PublicKey)
+		case op.Kind == OpDisablePeer || op.Kind == OpEnablePeer:
+			err = b.SetPeerEnabled(op.PublicKey, op.Kind == OpEnablePeer)
+		case op.Kind == OpReaddressPeer && op.Address != nil:
+			err = b.readdressPeer(op.Index, op.PublicKey, op.AllowedIP, op.Address)
+		default:
+			err = fmt.Errorf("unknown change %q", op.Kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error restoring change %d of the batch: %w", i+1, err)
+		}
+	}
+	return b, nil
+}
+
+// Len returns the number of staged changes.
+func (b *Batch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.ops)
+}
+
+// Preview returns the change staged changes would make together, without saving or reloading the configuration.
+func (b *Batch) Preview() (*Change, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.c.modify(true, b.edit)
+}
+
+// Commit applies staged changes with a single save and reload. If any of them fails, or Wireguard
+// fails to reload the configuration, the configuration file is left as it was.
+func (b *Batch) Commit() (*Change, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.c.modify(false, b.edit)
+}
+
+// Apply saves the change returned by Preview, so exactly the reviewed changes are applied. It returns
+// ErrChangeOutdated if the configuration file or staged changes were changed since the preview.
+func (b *Batch) Apply(change *Change) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Staged changes are applied again, so changed peers are known
+	current, err := b.c.modify(true, b.edit)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current.Before, change.Before) || !bytes.Equal(current.After, change.After) {
+		return ErrChangeOutdated
+	}
+	return b.c.ApplyChange(change)
+}
@@ -0,0 +1,155 @@
+package wireguard
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	content := testConfig + `
+# Alice
+[Peer]
+PublicKey  = ` + validatePeerKey1 + `
+AllowedIPs = 192.168.3.2/32
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	processManager := &countingProcessManager{}
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: processManager}
+	privateKey, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+	carolKey := privateKey.PublicKey().String()
+
+	batch := configManager.NewBatch()
+	require.NoError(t, batch.RemovePeer(validatePeerKey1))
+	require.NoError(t, batch.AddPeer(validatePeerKey2, "Bob"))
+	require.NoError(t, batch.AddPeer(carolKey, "Carol"))
+	// Changes which don't apply after the staged ones are refused
+	require.Error(t, batch.AddPeer(validatePeerKey2, "Bob again"))
+	require.Error(t, batch.RemovePeer(validatePeerKey1))
+	require.ErrorIs(t, batch.RemovePeerAt(1, validatePeerKey2), ErrPeerChanged)
+	require.NoError(t, batch.ReaddressPeer(0, validatePeerKey2, ""))
+	require.Equal(t, 4, batch.Len())
+
+	change, err := batch.Preview()
+	require.NoError(t, err)
+	require.Equal(t, `--- a/wg0.conf
++++ b/wg0.conf
+@@ -3,7 +3,12 @@
+ ListenPort = 11111
+ PrivateKey = (hidden)
+ 
+-# Alice
++# Bob
+ [Peer]
+-PublicKey  = `+validatePeerKey1+`
+-AllowedIPs = 192.168.3.2/32
++PublicKey  = `+validatePeerKey2+`
++AllowedIPs = 192.168.3.4/32, 192.168.3.2/32
++
++# Carol
++[Peer]
++PublicKey  = `+carolKey+`
++AllowedIPs = 192.168.3.3/32
+`, change.Diff())
+	ops := batch.Ops()
+	require.Equal(t, OpRemovePeer, ops[0].Kind)
+	require.Equal(t, "Alice", ops[0].Peer.Name)
+	require.Equal(t, "192.168.3.2/32", ops[1].Peer.AllowedIPs)
+	require.Equal(t, "192.168.3.3/32", ops[2].Peer.AllowedIPs)
+	require.Equal(t, "Bob", ops[3].Peer.Name)
+	require.Equal(t, "192.168.3.4/32, 192.168.3.2/32", ops[3].Peer.AllowedIPs)
+
+	// Nothing is applied if the configuration fails to reload
+	processManager.err = errors.New("reload failed")
+	_, err = batch.Commit()
+	var reloadErr *ReloadError
+	require.ErrorAs(t, err, &reloadErr)
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, content, string(saved))
+
+	processManager.err = nil
+	processManager.reloads = 0
+	committed, err := batch.Commit()
+	require.NoError(t, err)
+	require.Equal(t, 1, processManager.reloads)
+	saved, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(change.After), string(saved))
+	require.Equal(t, change.After, committed.After)
+}
+
+func TestBatchKeepsStagedAddresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	content := testConfig + `
+# Alice
+[Peer]
+PublicKey  = ` + validatePeerKey1 + `
+AllowedIPs = 10.0.0.1/32
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &countingProcessManager{}}
+	privateKey, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+	carolKey := privateKey.PublicKey().String()
+
+	batch := configManager.NewBatch()
+	staged := 0
+	batch.Staged = func() { staged++ }
+	require.NoError(t, batch.AddPeer(validatePeerKey2, "Bob"))
+	require.NoError(t, batch.ReaddressPeer(0, validatePeerKey1, "10.0.0.1/32"))
+	require.Equal(t, 2, staged)
+	require.Equal(t, []SavedOp{
+		{Kind: OpAddPeer, PublicKey: validatePeerKey2, Name: "Bob", Address: net.ParseIP("192.168.3.2"), Index: -1},
+		{Kind: OpReaddressPeer, PublicKey: validatePeerKey1, Index: 0, AllowedIP: "10.0.0.1/32", Address: net.ParseIP("192.168.3.3")},
+	}, batch.Saved())
+
+	// Restored batch makes the same change
+	change, err := batch.Preview()
+	require.NoError(t, err)
+	restored, err := configManager.RestoreBatch(batch.Saved())
+	require.NoError(t, err)
+	restoredChange, err := restored.Preview()
+	require.NoError(t, err)
+	require.Equal(t, string(change.After), string(restoredChange.After))
+
+	// The staged address isn't given to the peer if it was taken since, the batch fails instead
+	require.NoError(t, configManager.AddPeer(carolKey, "Carol"))
+	_, err = batch.Preview()
+	require.ErrorContains(t, err, "address 192.168.3.2 is already used")
+	require.Error(t, batch.Apply(change))
+	_, err = configManager.RestoreBatch(batch.Saved())
+	require.Error(t, err)
+	_, err = configManager.RestoreBatch([]SavedOp{{Kind: "unknown"}})
+	require.Error(t, err)
+}
+
+func TestBatchApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &countingProcessManager{}}
+
+	batch := configManager.NewBatch()
+	require.NoError(t, batch.AddPeer(validatePeerKey1, "Alice"))
+	change, err := batch.Preview()
+	require.NoError(t, err)
+
+	// Changes staged after the preview aren't applied without being reviewed
+	require.NoError(t, batch.AddPeer(validatePeerKey2, "Bob"))
+	require.ErrorIs(t, batch.Apply(change), ErrChangeOutdated)
+
+	change, err = batch.Preview()
+	require.NoError(t, err)
+	require.NoError(t, batch.Apply(change))
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(change.After), string(saved))
+	require.Equal(t, "192.168.3.3/32", batch.Ops()[1].Peer.AllowedIPs)
+}
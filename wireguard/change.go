@@ -0,0 +1,248 @@
+package wireguard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// diffContext is the number of unchanged lines shown around changed ones.
+const diffContext = 3
+
+// ErrChangeOutdated is returned when a previewed change is applied after the configuration file was changed.
+var ErrChangeOutdated = errors.New("configuration file was changed since the change was previewed")
+
+// Change is a modification of the configuration file.
+type Change struct {
+	// Base name of the configuration file
+	Name   string
+	Before []byte
+	After  []byte
+}
+
+// Diff returns unified diff of the change, it is empty if the file isn't changed.
+// Values of private and preshared keys are hidden, so the diff could be shown in chats.
+func (ch *Change) Diff() string {
+	return unifiedDiff(ch.Name, redactSecrets(ch.Before), redactSecrets(ch.After))
+}
+
+// redactSecrets replaces values of private and preshared keys.
+func redactSecrets(content []byte) []byte {
+	lines := splitLines(content)
+	for i, line := range lines {
+		key, _, found := strings.Cut(line, "=")
+		name := strings.TrimSpace(key)
+		if found && (strings.EqualFold(name, "PrivateKey") || strings.EqualFold(name, "PresharedKey")) {
+			lines[i] = key + "= (hidden)"
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// editFunc changes the loaded configuration in memory.
+type editFunc func(cfgFile *ini.File, config *Config) error
+
+// modify loads the configuration and applies the edit to it. Unless it is a dry run, the result is
+// saved and reloaded. It returns the change made, or the one which would be made in a dry run.
+func (c *ConfigManager) modify(dryRun bool, edit editFunc) (*Change, error) {
+	if !dryRun {
+		c.configMu.Lock()
+		defer c.configMu.Unlock()
+	}
+	cfgFile, config, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Backup original config, so we could restore it in case something fails after we save it.
+	cfgBackup, err := os.ReadFile(c.ConfigFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error creating config backup: %w", err)
+	}
+
+	err = edit(cfgFile, config)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.Buffer{}
+	_, err = cfgFile.WriteTo(&buffer)
+	if err != nil {
+		return nil, fmt.Errorf("error writing configuration: %w", err)
+	}
+	change := &Change{Name: filepath.Base(c.ConfigFilePath), Before: cfgBackup, After: buffer.Bytes()}
+	if dryRun {
+		return change, nil
+	}
+	return change, c.saveConfig(change.After, cfgBackup)
+}
+
+// ChangeOutdated reports whether the configuration file was changed since the change was made in a dry run,
+// so the change can't be applied as it is.
+func (c *ConfigManager) ChangeOutdated(change *Change) bool {
+	content, err := os.ReadFile(c.ConfigFilePath)
+	return err != nil || !bytes.Equal(content, change.Before)
+}
+
+// ApplyChange saves the change made in a dry run and reloads the configuration, so exactly the previewed
+// change is applied, e.g. with the same allocated address. It returns ErrChangeOutdated if the file was
+// changed since the dry run.
+func (c *ConfigManager) ApplyChange(change *Change) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	cfgBackup, err := os.ReadFile(c.ConfigFilePath)
+	if err != nil {
+		return fmt.Errorf("error creating config backup: %w", err)
+	}
+	if !bytes.Equal(cfgBackup, change.Before) {
+		return ErrChangeOutdated
+	}
+	return c.saveConfig(change.After, cfgBackup)
+}
+
+// diffLine is a line of the diff, op is ' ' for unchanged lines, '-' for removed and '+' for added ones.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+func splitLines(content []byte) []string {
+	s := strings.TrimSuffix(string(content), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffLines returns the shortest edit script turning a into b, found with Myers' algorithm.
+// Memory it takes grows with the square of the number of changed lines, not with the size of the file.
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	// Furthest x reached on every diagonal k = x - y, indexed by offset+k
+	v := make([]int, 2*offset+1)
+	// Diagonals -d..d of v before every step d, to find the path back
+	trace := [][]int{}
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	reversed := []diffLine{}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffLine{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, diffLine{'+', b[y-1]})
+		} else {
+			reversed = append(reversed, diffLine{'-', a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, diffLine{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	lines := make([]diffLine, len(reversed))
+	for i, line := range reversed {
+		lines[len(reversed)-1-i] = line
+	}
+	return lines
+}
+
+// hunkRange formats start and length of a hunk, start is the line before the hunk if it is empty.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// unifiedDiff returns diff of the file contents in unified format, it is empty if they are the same.
+func unifiedDiff(name string, before, after []byte) string {
+	lines := diffLines(splitLines(before), splitLines(after))
+	builder := strings.Builder{}
+	// Lines of both files before the current position
+	oldLine, newLine := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		// Changes are shown in the same hunk if their context would overlap
+		last := i
+		for j := i; j < len(lines) && j-last <= 2*diffContext; j++ {
+			if lines[j].op != ' ' {
+				last = j
+			}
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := last + diffContext + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		oldCount, newCount := 0, 0
+		for _, line := range lines[start:end] {
+			if line.op != '+' {
+				oldCount++
+			}
+			if line.op != '-' {
+				newCount++
+			}
+		}
+		if builder.Len() == 0 {
+			builder.WriteString("--- a/" + name + "\n+++ b/" + name + "\n")
+		}
+		builder.WriteString("@@ -" + hunkRange(oldStart, oldCount) + " +" + hunkRange(newStart, newCount) + " @@\n")
+		for _, line := range lines[start:end] {
+			builder.WriteByte(line.op)
+			builder.WriteString(line.text + "\n")
+		}
+		oldLine, newLine = oldStart+oldCount, newStart+newCount
+		i = end
+	}
+	return builder.String()
+}
@@ -0,0 +1,211 @@
+package wireguard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(s ...string) []byte {
+		return []byte(strings.Join(s, "\n") + "\n")
+	}
+	before := lines("1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15", "16")
+
+	require.Empty(t, unifiedDiff("wg0.conf", before, before))
+	require.Equal(t, `--- a/wg0.conf
++++ b/wg0.conf
+@@ -0,0 +1,2 @@
++a
++b
+`, unifiedDiff("wg0.conf", nil, lines("a", "b")))
+
+	// Changes which contexts overlap share a hunk, distant ones don't
+	after := lines("1", "2", "x", "4", "5", "6", "7", "8", "y", "9", "10", "11", "12", "13", "14", "15")
+	require.Equal(t, `--- a/wg0.conf
++++ b/wg0.conf
+@@ -1,11 +1,12 @@
+ 1
+ 2
+-3
++x
+ 4
+ 5
+ 6
+ 7
+ 8
++y
+ 9
+ 10
+ 11
+@@ -13,4 +14,3 @@
+ 13
+ 14
+ 15
+-16
+`, unifiedDiff("wg0.conf", before, after))
+}
+
+type countingProcessManager struct {
+	reloads int
+	// Error reloading returns
+	err error
+}
+
+func (pm *countingProcessManager) ReloadConfig() error {
+	pm.reloads++
+	return pm.err
+}
+
+func TestPreview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	content := testConfig + `
+# Alice
+[Peer]
+PublicKey  = ` + validatePeerKey1 + `
+AllowedIPs = 192.168.3.2/32
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	processManager := &countingProcessManager{}
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: processManager}
+
+	change, err := configManager.PreviewAddPeer(validatePeerKey2, "Bob")
+	require.NoError(t, err)
+	require.Equal(t, `--- a/wg0.conf
++++ b/wg0.conf
+@@ -7,3 +7,8 @@
+ [Peer]
+ PublicKey  = `+validatePeerKey1+`
+ AllowedIPs = 192.168.3.2/32
++
++# Bob
++[Peer]
++PublicKey  = `+validatePeerKey2+`
++AllowedIPs = 192.168.3.3/32
+`, change.Diff())
+
+	change, err = configManager.PreviewRemovePeer(validatePeerKey1)
+	require.NoError(t, err)
+	require.Equal(t, `--- a/wg0.conf
++++ b/wg0.conf
+@@ -2,8 +2,3 @@
+ Address    = 192.168.3.1/24
+ ListenPort = 11111
+ PrivateKey = (hidden)
+-
+-# Alice
+-[Peer]
+-PublicKey  = `+validatePeerKey1+`
+-AllowedIPs = 192.168.3.2/32
+`, change.Diff())
+
+	change, err = configManager.PreviewReaddressPeer(0, validatePeerKey1, "")
+	require.NoError(t, err)
+	require.Contains(t, change.Diff(), "\n-AllowedIPs = 192.168.3.2/32\n+AllowedIPs = 192.168.3.3/32, 192.168.3.2/32\n")
+
+	_, err = configManager.PreviewRemovePeerAt(1, validatePeerKey1)
+	require.ErrorIs(t, err, ErrPeerChanged)
+
+	// Dry runs neither save nor reload the configuration
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, content, string(saved))
+	require.Zero(t, processManager.reloads)
+
+	// The change previewed is the one made
+	change, err = configManager.PreviewAddPeer(validatePeerKey2, "Bob")
+	require.NoError(t, err)
+	require.NoError(t, configManager.AddPeer(validatePeerKey2, "Bob"))
+	saved, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(change.After), string(saved))
+}
+
+func TestApplyChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig), 0600))
+	processManager := &countingProcessManager{}
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: processManager, Allocation: Random}
+
+	// Random address of the preview is the one saved
+	change, err := configManager.PreviewAddPeer(validatePeerKey1, "Alice")
+	require.NoError(t, err)
+	require.False(t, configManager.ChangeOutdated(change))
+	require.NoError(t, configManager.ApplyChange(change))
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(change.After), string(saved))
+	require.Equal(t, 1, processManager.reloads)
+
+	// The change can't be applied once the file is changed
+	change, err = configManager.PreviewAddPeer(validatePeerKey2, "Bob")
+	require.NoError(t, err)
+	require.NoError(t, configManager.RemovePeer(validatePeerKey1))
+	require.True(t, configManager.ChangeOutdated(change))
+	require.ErrorIs(t, configManager.ApplyChange(change), ErrChangeOutdated)
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Empty(t, peers)
+}
+
+func TestApplyChangeConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &countingProcessManager{}}
+
+	// Changes previewed against the same file can't both be applied
+	first, err := configManager.PreviewAddPeer(validatePeerKey1, "Alice")
+	require.NoError(t, err)
+	second, err := configManager.PreviewAddPeer(validatePeerKey2, "Bob")
+	require.NoError(t, err)
+	errs := make([]error, 2)
+	wg := sync.WaitGroup{}
+	for i, change := range []*Change{first, second} {
+		wg.Add(1)
+		go func(i int, change *Change) {
+			defer wg.Done()
+			errs[i] = configManager.ApplyChange(change)
+		}(i, change)
+	}
+	wg.Wait()
+	outdated := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrChangeOutdated) {
+			outdated++
+		} else {
+			require.NoError(t, err)
+		}
+	}
+	require.Equal(t, 1, outdated)
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Len(t, peers, 1)
+}
+
+// brokenProcessManager fails to reload and breaks the configuration file, so it can't be rolled back.
+type brokenProcessManager struct {
+	path string
+}
+
+func (pm *brokenProcessManager) ReloadConfig() error {
+	os.Remove(pm.path)
+	os.Mkdir(pm.path, 0700)
+	return errors.New("reload failed")
+}
+
+func TestSaveConfigReportsRollbackError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &brokenProcessManager{path: path}}
+
+	err := configManager.AddPeer(validatePeerKey1, "Alice")
+	var reloadErr *ReloadError
+	require.ErrorAs(t, err, &reloadErr)
+	require.Error(t, reloadErr.RollbackErr)
+	require.Contains(t, err.Error(), "error restoring the previous one")
+}
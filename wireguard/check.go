@@ -0,0 +1,294 @@
+package wireguard
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"gopkg.in/ini.v1"
+)
+
+// ErrInconsistentConfig is returned when a problem which Check reports prevents an operation.
+var ErrInconsistentConfig = errors.New("configuration has problems")
+
+// ErrPeerChanged is returned by fixes when the peer has changed since the configuration was checked.
+var ErrPeerChanged = errors.New("peer has changed since the configuration was checked")
+
+// Fix is a way to repair a problem found by Check.
+type Fix string
+
+const (
+	// FixReaddress gives the peer a free address of the interface subnet instead of the AllowedIPs entry
+	FixReaddress Fix = "readdress"
+	// FixRemove removes the peer section
+	FixRemove Fix = "remove"
+)
+
+// Problem is an inconsistency of the server configuration.
+type Problem struct {
+	Err error
+	// Line of the section header the problem is in, 0 if the problem is not about a section
+	Line int
+	// Index of the peer among [Peer] sections, -1 if the problem is not about a peer
+	Peer      int
+	PublicKey string
+	// AllowedIPs entry the problem is about, if any
+	AllowedIP string
+	// Fixes which can be applied to the problem
+	Fixes []Fix
+}
+
+func (p Problem) Error() string {
+	return p.Err.Error()
+}
+
+func (p Problem) Unwrap() error {
+	return p.Err
+}
+
+// String returns description of the problem along with its position.
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return p.Error()
+	}
+	return fmt.Sprintf("line %d: %s", p.Line, p.Error())
+}
+
+// sectionLines returns lines of section headers by section name.
+func sectionLines(content []byte) map[string][]int {
+	lines := map[string][]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			lines[name] = append(lines[name], line)
+		}
+	}
+	return lines
+}
+
+func lineAt(lines []int, index int) int {
+	if index < len(lines) {
+		return lines[index]
+	}
+	return 0
+}
+
+func isHost(network *net.IPNet) bool {
+	ones, bits := network.Mask.Size()
+	return ones == bits
+}
+
+// Check finds inconsistencies of the server configuration: invalid keys and interface address,
+// duplicate public keys, AllowedIPs which overlap, include the interface address or reserved
+// addresses, and peers without an address in the interface subnet. It returns all problems found.
+func (c *ConfigManager) Check() ([]Problem, error) {
+	content, err := os.ReadFile(c.ConfigFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	_, config, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	lines := sectionLines(content)
+
+	problems := []Problem{}
+	report := func(problem Problem, err error) {
+		problem.Err = err
+		problems = append(problems, problem)
+	}
+
+	iface := Problem{Line: lineAt(lines["Interface"], 0), Peer: -1}
+	ifaceAddr, ifaceNetwork, err := net.ParseCIDR(config.Interface.Address)
+	if err != nil {
+		report(iface, fmt.Errorf("interface address %q is not valid: %w", config.Interface.Address, err))
+	}
+	_, err = wgtypes.ParseKey(config.Interface.PrivateKey)
+	if err != nil {
+		report(iface, fmt.Errorf("interface private key is not valid: %w", err))
+	}
+	reserved := []IPRange{}
+	if ifaceNetwork != nil {
+		for _, s := range c.Reserved {
+			r, err := ParseIPRange(s, *ifaceNetwork)
+			if err != nil {
+				report(Problem{Peer: -1}, fmt.Errorf("reserved %w", err))
+				continue
+			}
+			reserved = append(reserved, r)
+		}
+	}
+
+	type allowedIP struct {
+		peer    *Peer
+		network *net.IPNet
+	}
+	allowedIPs := []allowedIP{}
+	keys := map[string]*Peer{}
+	for i := range config.Peer {
+		peer := &config.Peer[i]
+		section := Problem{Line: lineAt(lines["Peer"], i), Peer: i, PublicKey: peer.PublicKey, Fixes: []Fix{FixRemove}}
+		_, err = wgtypes.ParseKey(peer.PublicKey)
+		if err != nil {
+			report(section, fmt.Errorf("%s has invalid public key: %w", peerLabel(peer), err))
+		}
+		if other, ok := keys[peer.PublicKey]; ok {
+			report(section, fmt.Errorf("%s has the same public key as %s", peerLabel(peer), peerLabel(other)))
+		}
+		keys[peer.PublicKey] = peer
+
+		inSubnet := false
+		// Host address outside of the subnet, which is likely a mistyped address of the peer
+		outside := ""
+		for _, addr := range strings.Split(peer.AllowedIPs, ",") {
+			addr = strings.TrimSpace(addr)
+			if addr == "" {
+				continue
+			}
+			entry := section
+			entry.AllowedIP = addr
+			entry.Fixes = []Fix{FixReaddress}
+			_, network, err := net.ParseCIDR(addr)
+			if err != nil {
+				report(entry, fmt.Errorf("%s has invalid AllowedIPs %q: %w", peerLabel(peer), addr, err))
+				continue
+			}
+			for _, other := range allowedIPs {
+				if overlaps(network, other.network) {
+					overlap := entry
+					overlap.Fixes = []Fix{FixReaddress, FixRemove}
+					report(overlap, fmt.Errorf("AllowedIPs %s of %s overlap with %s of %s",
+						network, peerLabel(peer), other.network, peerLabel(other.peer)))
+				}
+			}
+			if ifaceAddr != nil && network.Contains(ifaceAddr) {
+				report(entry, fmt.Errorf("AllowedIPs %s of %s include interface address %s",
+					network, peerLabel(peer), config.Interface.Address))
+			}
+			if ifaceNetwork != nil && ifaceNetwork.Contains(network.IP) {
+				inSubnet = true
+				if err := validate(network.IP, *ifaceNetwork); err != nil {
+					report(entry, fmt.Errorf("AllowedIPs %s of %s can't be used: %w", network, peerLabel(peer), err))
+				}
+				for _, r := range reserved {
+					if r.Contains(network.IP) {
+						report(entry, fmt.Errorf("AllowedIPs %s of %s are in reserved range %s",
+							network, peerLabel(peer), r))
+					}
+				}
+			} else if outside == "" && isHost(network) {
+				outside = addr
+			}
+			allowedIPs = append(allowedIPs, allowedIP{peer: peer, network: network})
+		}
+		if ifaceNetwork != nil && !inSubnet {
+			entry := section
+			entry.AllowedIP = outside
+			entry.Fixes = []Fix{FixReaddress}
+			report(entry, fmt.Errorf("%s has no address in interface subnet %s", peerLabel(peer), ifaceNetwork))
+		}
+	}
+
+	return problems, nil
+}
+
+// peerAt returns the peer with the given index along with its section, checking that it still has the public key.
+func peerAt(cfgFile *ini.File, config *Config, index int, publicKey string) (*ini.Section, *Peer, error) {
+	if index < 0 || index >= len(config.Peer) || config.Peer[index].PublicKey != publicKey {
+		return nil, nil, ErrPeerChanged
+	}
+	// Peers are parsed from the same sections, so the section exists
+	return cfgFile.SectionWithIndex("Peer", index), &config.Peer[index], nil
+}
+
+func (c *ConfigManager) readdressPeer(index int, publicKey string, allowedIP string, nextIP *net.IP) editFunc {
+	return func(cfgFile *ini.File, config *Config) error {
+		section, peer, err := peerAt(cfgFile, config, index, publicKey)
+		if err != nil {
+			return err
+		}
+
+		entries := []string{}
+		found := allowedIP == ""
+		for _, entry := range strings.Split(peer.AllowedIPs, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == allowedIP && !found {
+				found = true
+				continue
+			}
+			if entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+		if !found {
+			return ErrPeerChanged
+		}
+
+		// The replaced entry doesn't take an address anymore
+		peer.AllowedIPs = strings.Join(entries, ", ")
+		if *nextIP != nil {
+			// Batches apply the edit again, the address found the first time is kept
+			err = checkFree(config, *nextIP)
+			if err != nil {
+				return err
+			}
+		} else {
+			*nextIP, err = c.calculateNextIP(config)
+			if err != nil {
+				return fmt.Errorf("error calculating next IP address for peer: %w", err)
+			}
+		}
+		section.Key("AllowedIPs").SetValue(strings.Join(append([]string{hostAddress(*nextIP)}, entries...), ", "))
+		return nil
+	}
+}
+
+// ReaddressPeer gives the peer with the given index a free address of the interface subnet instead of
+// its AllowedIPs entry, or in addition to its AllowedIPs if the entry is empty. It returns the new address.
+func (c *ConfigManager) ReaddressPeer(index int, publicKey string, allowedIP string) (net.IP, error) {
+	var nextIP net.IP
+	_, err := c.modify(false, c.readdressPeer(index, publicKey, allowedIP, &nextIP))
+	if err != nil {
+		return nil, err
+	}
+	return nextIP, nil
+}
+
+// PreviewReaddressPeer returns the change ReaddressPeer would make, without saving or reloading the configuration.
+func (c *ConfigManager) PreviewReaddressPeer(index int, publicKey string, allowedIP string) (*Change, error) {
+	var nextIP net.IP
+	return c.modify(true, c.readdressPeer(index, publicKey, allowedIP, &nextIP))
+}
+
+func removePeerAt(index int, publicKey string) editFunc {
+	return func(cfgFile *ini.File, config *Config) error {
+		_, _, err := peerAt(cfgFile, config, index, publicKey)
+		if err != nil {
+			return err
+		}
+
+		err = cfgFile.DeleteSectionWithIndex("Peer", index)
+		if err != nil {
+			return fmt.Errorf("error removing peer section: %w", err)
+		}
+		return nil
+	}
+}
+
+// RemovePeerAt removes the peer section with the given index, e.g. one of peers with the same public key.
+func (c *ConfigManager) RemovePeerAt(index int, publicKey string) error {
+	_, err := c.modify(false, removePeerAt(index, publicKey))
+	return err
+}
+
+// PreviewRemovePeerAt returns the change RemovePeerAt would make, without saving or reloading the configuration.
+func (c *ConfigManager) PreviewRemovePeerAt(index int, publicKey string) (*Change, error) {
+	return c.modify(true, removePeerAt(index, publicKey))
+}
@@ -0,0 +1,108 @@
+package wireguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+# Alice
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.2/32
+
+# Alice again
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.2/32, 10.10.0.0/24
+
+# Bob
+[Peer]
+PublicKey  = `+validatePeerKey2+`
+AllowedIPs = 10.0.0.3/32
+`), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &ProcessManagerStub{}}
+
+	problems, err := configManager.Check()
+	require.NoError(t, err)
+	require.Len(t, problems, 3)
+	require.Equal(t, `line 12: peer "Alice again" has the same public key as peer "Alice"`, problems[0].String())
+	require.Equal(t, 1, problems[0].Peer)
+	require.Equal(t, []Fix{FixRemove}, problems[0].Fixes)
+	require.Equal(t, "192.168.3.2/32", problems[1].AllowedIP)
+	require.Equal(t, []Fix{FixReaddress, FixRemove}, problems[1].Fixes)
+	require.Equal(t, `line 17: peer "Bob" has no address in interface subnet 192.168.3.0/24`, problems[2].String())
+	require.Equal(t, "10.0.0.3/32", problems[2].AllowedIP)
+
+	// Fixes are refused if the peer has changed since the check
+	_, err = configManager.ReaddressPeer(2, validatePeerKey2, "10.0.0.4/32")
+	require.ErrorIs(t, err, ErrPeerChanged)
+	require.ErrorIs(t, configManager.RemovePeerAt(2, validatePeerKey1), ErrPeerChanged)
+
+	addr, err := configManager.ReaddressPeer(2, validatePeerKey2, "10.0.0.3/32")
+	require.NoError(t, err)
+	require.Equal(t, "192.168.3.3", addr.String())
+	require.NoError(t, configManager.RemovePeerAt(1, validatePeerKey1))
+
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Len(t, peers, 2)
+	require.Equal(t, "Alice", peers[0].Name)
+	require.Equal(t, "192.168.3.3/32", peers[1].AllowedIPs)
+	problems, err = configManager.Check()
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
+
+func TestAddPeerWithInconsistentConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.255/32
+`), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &ProcessManagerStub{}}
+
+	err := configManager.AddPeer(validatePeerKey2, "Bob")
+	require.ErrorIs(t, err, ErrInconsistentConfig)
+	require.ErrorContains(t, err, "192.168.3.255/32 of peer "+validatePeerKey1+" can't be used")
+}
+
+func TestClientConfigAfterReaddress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+# Office
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.99.0/24
+
+# Bob
+[Peer]
+PublicKey  = `+validatePeerKey2+`
+AllowedIPs = 10.10.0.0/24, 192.168.3.5/32
+`), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, Hostname: "example.com", ProcessManager: &ProcessManagerStub{}}
+
+	_, _, err := configManager.GetClientConfig(validatePeerKey1)
+	require.EqualError(t, err, "peer has no address in interface subnet 192.168.3.0/24")
+
+	// The address is added in front of the routed network
+	addr, err := configManager.ReaddressPeer(0, validatePeerKey1, "")
+	require.NoError(t, err)
+	require.Equal(t, "192.168.3.2", addr.String())
+	peer, err := configManager.GetPeer(validatePeerKey1)
+	require.NoError(t, err)
+	require.Equal(t, "192.168.3.2/32, 192.168.99.0/24", peer.AllowedIPs)
+	clientConfig, _, err := configManager.GetClientConfig(validatePeerKey1)
+	require.NoError(t, err)
+	require.Equal(t, "192.168.3.2/24", clientConfig.Interface.Address)
+
+	clientConfig, _, err = configManager.GetClientConfig(validatePeerKey2)
+	require.NoError(t, err)
+	require.Equal(t, "192.168.3.5/24", clientConfig.Interface.Address)
+}
@@ -13,6 +13,8 @@ type ClientPeer struct {
 }
 
 type ClientConfig struct {
+	// Name of the peer the config is for
+	Name      string `ini:"-"`
 	Interface ClientInterface
 	Peer      ClientPeer
 }
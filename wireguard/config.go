@@ -1,5 +1,10 @@
 package wireguard
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
 type Config struct {
 	Interface
 	Peer []Peer
@@ -14,5 +19,15 @@ type Interface struct {
 type Peer struct {
 	AllowedIPs string
 	PublicKey  string
-	Name       string
+	Name       string            `ini:"-"`
+	Metadata   map[string]string `ini:"-"`
+	// Disabled peers keep AllowedIPs in the comment, see DisablePeer
+	Disabled bool `ini:"-"`
+}
+
+// ID returns short identifier of the peer, which is derived from its public key
+// and stays the same while the peer exists.
+func (p *Peer) ID() string {
+	hash := sha256.Sum256([]byte(p.PublicKey))
+	return hex.EncodeToString(hash[:6])
 }
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	"gopkg.in/ini.v1"
@@ -18,30 +20,70 @@ type ConfigManager struct {
 	DNS            string
 	InterfaceName  string
 	ProcessManager ProcessManagerInterface
+	// Ranges of the server subnet which aren't given to new peers, see ParseIPRange
+	Reserved []string
+	// Which of the free addresses new peers get
+	Allocation Strategy
+	// Guards Hostname and DNS, which could be changed with SetEndpoint while configs are generated
+	endpointMu sync.RWMutex
+	// Serializes changes of the configuration file, so a change can't be saved between the check
+	// that the file is as expected and saving another one
+	configMu sync.Mutex
 }
 
-func calculateNextIP(config *Config) (net.IP, error) {
-	ifaceAddr, network, err := net.ParseCIDR(config.Interface.Address)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing interface address: %w", err)
+// SetEndpoint changes the hostname and DNS put into client configs. It is safe to call
+// while other methods are running.
+func (c *ConfigManager) SetEndpoint(hostname string, dns string) {
+	c.endpointMu.Lock()
+	defer c.endpointMu.Unlock()
+	c.Hostname = hostname
+	c.DNS = dns
+}
+
+func (c *ConfigManager) endpoint() (string, string) {
+	c.endpointMu.RLock()
+	defer c.endpointMu.RUnlock()
+	return c.Hostname, c.DNS
+}
+
+// ReloadError is returned when configuration was saved, but Wireguard failed to
+// reload it, so the configuration file was rolled back to its previous state.
+type ReloadError struct {
+	Err error
+	// Set if the previous configuration couldn't be restored, so the file keeps the new one
+	RollbackErr error
+}
+
+func (e *ReloadError) Error() string {
+	if e.RollbackErr != nil {
+		return "error reloading configration: " + e.Err.Error() + ", and error restoring the previous one: " + e.RollbackErr.Error()
 	}
+	return "error reloading configration: " + e.Err.Error()
+}
 
-	addrList := make([]net.IP, len(config.Peer)+1)
-	addrList[0] = ifaceAddr
-	for i, peer := range config.Peer {
-		addr, _, err := net.ParseCIDR(peer.AllowedIPs)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing peer AllowedIPs: %w", err)
-		}
-		addrList[i+1] = addr
+func (e *ReloadError) Unwrap() error {
+	return e.Err
+}
+
+// saveConfig saves configuration and reloads it, restoring the backup if Wireguard fails to reload it.
+// The caller should hold configMu.
+func (c *ConfigManager) saveConfig(content []byte, cfgBackup []byte) error {
+	err := os.WriteFile(c.ConfigFilePath, content, 0600)
+	if err != nil {
+		return fmt.Errorf("error saving configuration: %w", err)
 	}
 
-	nextIP, err := getNextIPAddress(addrList, *network)
+	err = c.ProcessManager.ReloadConfig()
 	if err != nil {
-		return nil, fmt.Errorf("error getting next ip address: %w", err)
+		reloadErr := &ReloadError{Err: err}
+		err = os.WriteFile(c.ConfigFilePath, cfgBackup, 0600)
+		if err != nil {
+			reloadErr.RollbackErr = err
+		}
+		return reloadErr
 	}
 
-	return nextIP, nil
+	return nil
 }
 
 func (c *ConfigManager) loadConfig() (*ini.File, *Config, error) {
@@ -50,11 +92,19 @@ func (c *ConfigManager) loadConfig() (*ini.File, *Config, error) {
 		return nil, nil, fmt.Errorf("error loading config: %w", err)
 	}
 
+	config, err := parseConfig(cfgFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfgFile, config, nil
+}
+
+func parseConfig(cfgFile *ini.File) (*Config, error) {
 	config := &Config{}
 
-	err = cfgFile.MapTo(config)
+	err := cfgFile.MapTo(config)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error parsing config: %w", err)
+		return nil, fmt.Errorf("error parsing config: %w", err)
 	}
 
 	sections, err := cfgFile.SectionsByName("Peer")
@@ -63,62 +113,66 @@ func (c *ConfigManager) loadConfig() (*ini.File, *Config, error) {
 		for i, section := range sections {
 			err = section.MapTo(&config.Peer[i])
 			if err != nil {
-				return nil, nil, fmt.Errorf("error parsing peer: %w", err)
+				return nil, fmt.Errorf("error parsing peer: %w", err)
 			}
-			config.Peer[i].Name = strings.Trim(section.Comment, "# ")
+			config.Peer[i].Name, config.Peer[i].Metadata = parsePeerComment(section.Comment)
+			restoreDisabled(&config.Peer[i])
 		}
 	}
 
-	return cfgFile, config, nil
+	return config, nil
 }
 
-func (c *ConfigManager) AddPeer(publicKey string, name string) error {
-	cfgFile, config, err := c.loadConfig()
-	if err != nil {
-		return err
-	}
-
-	for _, peer := range config.Peer {
-		if peer.PublicKey == publicKey {
-			return fmt.Errorf("peer with public key %s already exists: %s", publicKey, peer.Name)
+// addPeer adds the peer with the given address, a free one is allocated if it is nil.
+func (c *ConfigManager) addPeer(publicKey string, name string, address net.IP) editFunc {
+	return func(cfgFile *ini.File, config *Config) error {
+		err := ValidatePeerName(name)
+		if err != nil {
+			return err
+		}
+		for _, peer := range config.Peer {
+			if peer.PublicKey == publicKey {
+				return fmt.Errorf("peer with public key %s already exists: %s", publicKey, peer.Name)
+			}
 		}
-	}
-
-	// Backup original config, so we could restore it in case something fails after we save it.
-	cfgBackup := *cfgFile
-
-	sec, err := cfgFile.NewSection("Peer")
-	if err != nil {
-		return fmt.Errorf("error creating section: %w", err)
-	}
 
-	_, err = sec.NewKey("PublicKey", publicKey)
-	if err != nil {
-		return fmt.Errorf("error adding PublicKey: %w", err)
-	}
+		sec, err := cfgFile.NewSection("Peer")
+		if err != nil {
+			return fmt.Errorf("error creating section: %w", err)
+		}
 
-	sec.NewKey("PublicKey", publicKey)
-	sec.Comment = "# " + name
+		_, err = sec.NewKey("PublicKey", publicKey)
+		if err != nil {
+			return fmt.Errorf("error adding PublicKey: %w", err)
+		}
 
-	nextIP, err := calculateNextIP(config)
-	if err != nil {
-		return fmt.Errorf("error calculating next IP address for peer: %w", err)
-	}
+		sec.NewKey("PublicKey", publicKey)
+		sec.Comment = "# " + name
 
-	sec.NewKey("AllowedIPs", nextIP.String()+"/32")
+		// Batches apply the edit again on every preview, so the given address may have been taken since
+		addr := address
+		if addr == nil {
+			addr, err = c.calculateNextIP(config)
+			if err != nil {
+				return fmt.Errorf("error calculating next IP address for peer: %w", err)
+			}
+		} else if err = checkFree(config, addr); err != nil {
+			return err
+		}
 
-	err = cfgFile.SaveTo(c.ConfigFilePath)
-	if err != nil {
-		return fmt.Errorf("error saving configuration: %w", err)
+		sec.NewKey("AllowedIPs", hostAddress(addr))
+		return nil
 	}
+}
 
-	err = c.ProcessManager.ReloadConfig()
-	if err != nil {
-		cfgBackup.SaveTo(c.ConfigFilePath)
-		return fmt.Errorf("error reloading configration: %w", err)
-	}
+func (c *ConfigManager) AddPeer(publicKey string, name string) error {
+	_, err := c.modify(false, c.addPeer(publicKey, name, nil))
+	return err
+}
 
-	return nil
+// PreviewAddPeer returns the change AddPeer would make, without saving or reloading the configuration.
+func (c *ConfigManager) PreviewAddPeer(publicKey string, name string) (*Change, error) {
+	return c.modify(true, c.addPeer(publicKey, name, nil))
 }
 
 func getPeerIndex(config *Config, publicKey string) (int, error) {
@@ -130,37 +184,29 @@ func getPeerIndex(config *Config, publicKey string) (int, error) {
 	return -1, errors.New("can't find peer with specified public key")
 }
 
-func (c *ConfigManager) RemovePeer(publicKey string) error {
-	cfgFile, config, err := c.loadConfig()
-	if err != nil {
-		return err
-	}
-
-	// Backup original config, so we could restore it in case something fails after we save it.
-	cfgBackup := *cfgFile
-
-	index, err := getPeerIndex(config, publicKey)
-	if err != nil {
-		return err
-	}
-
-	err = cfgFile.DeleteSectionWithIndex("Peer", index)
-	if err != nil {
-		return fmt.Errorf("error removing peer section: %w", err)
-	}
+func removePeer(publicKey string) editFunc {
+	return func(cfgFile *ini.File, config *Config) error {
+		index, err := getPeerIndex(config, publicKey)
+		if err != nil {
+			return err
+		}
 
-	err = cfgFile.SaveTo(c.ConfigFilePath)
-	if err != nil {
-		return fmt.Errorf("error saving configuration: %w", err)
+		err = cfgFile.DeleteSectionWithIndex("Peer", index)
+		if err != nil {
+			return fmt.Errorf("error removing peer section: %w", err)
+		}
+		return nil
 	}
+}
 
-	err = c.ProcessManager.ReloadConfig()
-	if err != nil {
-		cfgBackup.SaveTo(c.ConfigFilePath)
-		return fmt.Errorf("error reloading configration: %w", err)
-	}
+func (c *ConfigManager) RemovePeer(publicKey string) error {
+	_, err := c.modify(false, removePeer(publicKey))
+	return err
+}
 
-	return nil
+// PreviewRemovePeer returns the change RemovePeer would make, without saving or reloading the configuration.
+func (c *ConfigManager) PreviewRemovePeer(publicKey string) (*Change, error) {
+	return c.modify(true, removePeer(publicKey))
 }
 
 func (c *ConfigManager) ListPeers() ([]Peer, error) {
@@ -172,7 +218,7 @@ func (c *ConfigManager) ListPeers() ([]Peer, error) {
 	return config.Peer, nil
 }
 
-func (c *ConfigManager) getClientConfigStruct(publicKey string) (*ClientConfig, error) {
+func (c *ConfigManager) GetPeer(publicKey string) (*Peer, error) {
 	_, config, err := c.loadConfig()
 	if err != nil {
 		return nil, err
@@ -183,9 +229,18 @@ func (c *ConfigManager) getClientConfigStruct(publicKey string) (*ClientConfig,
 		return nil, err
 	}
 
-	addr, _, err := net.ParseCIDR(config.Peer[index].AllowedIPs)
+	return &config.Peer[index], nil
+}
+
+func (c *ConfigManager) getClientConfigStruct(publicKey string) (*ClientConfig, error) {
+	_, config, err := c.loadConfig()
 	if err != nil {
-		return nil, fmt.Errorf("error peer AllowdIPs: %w", err)
+		return nil, err
+	}
+
+	index, err := getPeerIndex(config, publicKey)
+	if err != nil {
+		return nil, err
 	}
 
 	_, network, err := net.ParseCIDR(config.Interface.Address)
@@ -193,21 +248,39 @@ func (c *ConfigManager) getClientConfigStruct(publicKey string) (*ClientConfig,
 		return nil, fmt.Errorf("error parsing interface address: %w", err)
 	}
 
+	// AllowedIPs could also list networks routed through the peer, the address is the one in the subnet
+	var addr net.IP
+	for _, allowedIP := range strings.Split(config.Peer[index].AllowedIPs, ",") {
+		ip, _, err := net.ParseCIDR(strings.TrimSpace(allowedIP))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing peer AllowedIPs: %w", err)
+		}
+		if network.Contains(ip) {
+			addr = ip
+			break
+		}
+	}
+	if addr == nil {
+		return nil, fmt.Errorf("peer has no address in interface subnet %s", network)
+	}
+
 	privateKey, err := wgtypes.ParseKey(config.PrivateKey)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing private key: %w", err)
 	}
 
 	maskSize, _ := network.Mask.Size()
+	hostname, dns := c.endpoint()
 
 	return &ClientConfig{
+		Name: config.Peer[index].Name,
 		Interface: ClientInterface{
 			PrivateKey: "<put your private key here>",
 			Address:    addr.String() + "/" + strconv.Itoa(maskSize),
-			DNS:        c.DNS,
+			DNS:        dns,
 		},
 		Peer: ClientPeer{
-			Endpoint:   c.Hostname + ":" + config.Interface.ListenPort,
+			Endpoint:   hostname + ":" + config.Interface.ListenPort,
 			AllowedIPs: "0.0.0.0/0, ::/0",
 			PublicKey:  privateKey.PublicKey().String(),
 		},
@@ -3,6 +3,7 @@ package wireguard
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -61,6 +62,7 @@ func TestConfigManager(t *testing.T) {
 
 	clientConfig, configStr, err := configManager.GetClientConfig("yyy")
 	require.NoError(t, err)
+	require.Equal(t, clientConfig.Name, "Test Peer")
 	require.Equal(t, clientConfig.Interface.Address, "192.168.3.2/24")
 	require.Equal(t, clientConfig.Interface.DNS, "8.8.8.8")
 	require.Equal(t, clientConfig.Peer.Endpoint, "example.com:11111")
@@ -73,3 +75,36 @@ func TestConfigManager(t *testing.T) {
 	peers, _ = configManager.ListPeers()
 	require.Empty(t, peers)
 }
+
+func TestAddPeerRejectsUnsafeName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &ProcessManagerStub{}}
+
+	for _, name := range []string{"a\n\nb", "Bob\nowner: mallory", "Bob\r", "Bob\x00"} {
+		require.ErrorIs(t, configManager.AddPeer(validatePeerKey1, name), ErrInvalidPeerName, name)
+	}
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, testConfig, string(content))
+
+	require.NoError(t, configManager.AddPeer(validatePeerKey1, "Bob's laptop (old) #2"))
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Equal(t, "Bob's laptop (old) #2", peers[0].Name)
+}
+
+func TestAddPeerIPv6(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(`[Interface]
+Address    = fd00::1/64
+ListenPort = 11111
+PrivateKey = sLsJoF6gLXYWfRcpRkA7ugzvkYX15Lpvif5oBeZeaHA=
+`), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &ProcessManagerStub{}}
+
+	require.NoError(t, configManager.AddPeer("yyy", "Test Peer"))
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Equal(t, "fd00::2/128", peers[0].AllowedIPs)
+}
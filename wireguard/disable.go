@@ -0,0 +1,79 @@
+package wireguard
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// disabledKey is the metadata key which keeps AllowedIPs of a disabled peer. Wireguard doesn't route
+// any traffic to or from a peer without AllowedIPs, while its key and address stay in the configuration.
+const disabledKey = "disabled"
+
+// restoreDisabled marks the peer as disabled if its AllowedIPs are kept in the comment.
+func restoreDisabled(peer *Peer) {
+	if peer.AllowedIPs != "" || peer.Metadata[disabledKey] == "" {
+		return
+	}
+	peer.Disabled = true
+	peer.AllowedIPs = peer.Metadata[disabledKey]
+}
+
+func setPeerEnabled(publicKey string, enabled bool) editFunc {
+	return func(cfgFile *ini.File, config *Config) error {
+		index, err := getPeerIndex(config, publicKey)
+		if err != nil {
+			return err
+		}
+		peer := &config.Peer[index]
+		if peer.Disabled != enabled {
+			if enabled {
+				return errors.New("peer is not disabled")
+			}
+			return errors.New("peer is already disabled")
+		}
+
+		// Peers are parsed from the same sections, so the section exists
+		section := cfgFile.SectionWithIndex("Peer", index)
+		if !enabled {
+			if section.Comment == "" {
+				section.Comment = "#"
+			}
+			section.Comment += "\n# " + disabledKey + ": " + peer.AllowedIPs
+			section.DeleteKey("AllowedIPs")
+			return nil
+		}
+
+		lines := strings.Split(section.Comment, "\n")
+		comment := []string{lines[0]}
+		for _, line := range lines[1:] {
+			key, _, _ := strings.Cut(strings.TrimLeft(line, "#;"), ":")
+			if strings.TrimSpace(key) != disabledKey {
+				comment = append(comment, line)
+			}
+		}
+		section.Comment = strings.Join(comment, "\n")
+		_, err = section.NewKey("AllowedIPs", peer.AllowedIPs)
+		return err
+	}
+}
+
+// DisablePeer cuts the peer off without removing it: its AllowedIPs are moved into the comment,
+// so the address isn't given to other peers and the peer could be enabled again.
+func (c *ConfigManager) DisablePeer(publicKey string) error {
+	_, err := c.modify(false, setPeerEnabled(publicKey, false))
+	return err
+}
+
+// EnablePeer restores AllowedIPs of the disabled peer.
+func (c *ConfigManager) EnablePeer(publicKey string) error {
+	_, err := c.modify(false, setPeerEnabled(publicKey, true))
+	return err
+}
+
+// PreviewSetPeerEnabled returns the change DisablePeer, or EnablePeer if enabled is true, would make,
+// without saving or reloading the configuration.
+func (c *ConfigManager) PreviewSetPeerEnabled(publicKey string, enabled bool) (*Change, error) {
+	return c.modify(true, setPeerEnabled(publicKey, enabled))
+}
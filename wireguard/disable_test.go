@@ -0,0 +1,93 @@
+package wireguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisablePeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+# Alice
+# owner: alice@example.com
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.2/32
+`), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &ProcessManagerStub{}}
+
+	require.NoError(t, configManager.DisablePeer(validatePeerKey1))
+	require.Error(t, configManager.DisablePeer(validatePeerKey1))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `# Alice
+# owner: alice@example.com
+# disabled: 192.168.3.2/32
+[Peer]
+PublicKey = `+validatePeerKey1+"\n")
+	require.NotContains(t, string(content), "AllowedIPs")
+
+	// Disabled peers keep their addresses
+	peer, err := configManager.GetPeer(validatePeerKey1)
+	require.NoError(t, err)
+	require.True(t, peer.Disabled)
+	require.Equal(t, "192.168.3.2/32", peer.AllowedIPs)
+	require.NoError(t, configManager.AddPeer(validatePeerKey2, "Bob"))
+	peer, err = configManager.GetPeer(validatePeerKey2)
+	require.NoError(t, err)
+	require.Equal(t, "192.168.3.3/32", peer.AllowedIPs)
+
+	require.NoError(t, configManager.EnablePeer(validatePeerKey1))
+	require.Error(t, configManager.EnablePeer(validatePeerKey1))
+	peer, err = configManager.GetPeer(validatePeerKey1)
+	require.NoError(t, err)
+	require.False(t, peer.Disabled)
+	require.Equal(t, "192.168.3.2/32", peer.AllowedIPs)
+	require.Equal(t, map[string]string{"owner": "alice@example.com"}, peer.Metadata)
+
+	// Peers without a name get an empty first line, so the marker isn't taken for the name
+	require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+[Peer]
+PublicKey  = `+validatePeerKey2+`
+AllowedIPs = 192.168.3.3/32
+`), 0600))
+	require.NoError(t, configManager.DisablePeer(validatePeerKey2))
+	peer, err = configManager.GetPeer(validatePeerKey2)
+	require.NoError(t, err)
+	require.True(t, peer.Disabled)
+	require.Equal(t, "", peer.Name)
+}
+
+func TestBatchSetPeerEnabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+# Alice
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.2/32
+`), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &ProcessManagerStub{}}
+
+	preview, err := configManager.PreviewSetPeerEnabled(validatePeerKey1, false)
+	require.NoError(t, err)
+	batch := configManager.NewBatch()
+	require.NoError(t, batch.SetPeerEnabled(validatePeerKey1, false))
+	require.Error(t, batch.SetPeerEnabled(validatePeerKey1, false))
+	ops := batch.Ops()
+	require.Equal(t, OpDisablePeer, ops[0].Kind)
+	require.True(t, ops[0].Peer.Disabled)
+	require.Equal(t, "192.168.3.2/32", ops[0].Peer.AllowedIPs)
+
+	restored, err := configManager.RestoreBatch(batch.Saved())
+	require.NoError(t, err)
+	change, err := restored.Preview()
+	require.NoError(t, err)
+	require.Equal(t, string(preview.After), string(change.After))
+	require.NoError(t, restored.Apply(change))
+	peer, err := configManager.GetPeer(validatePeerKey1)
+	require.NoError(t, err)
+	require.True(t, peer.Disabled)
+}
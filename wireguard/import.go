@@ -0,0 +1,241 @@
+package wireguard
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"gopkg.in/ini.v1"
+)
+
+// MaxImportRows limits the number of peers imported at once.
+const MaxImportRows = 1000
+
+// ImportRow is a peer to import.
+type ImportRow struct {
+	// Line of the row in CSV file, or position of the element in JSON array
+	Row       int    `json:"-"`
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+	// Address of the peer, a free one is allocated if it is empty
+	Address string `json:"address"`
+}
+
+// ImportError is a problem of a row which prevents importing it.
+type ImportError struct {
+	Row int
+	Err error
+}
+
+func (e ImportError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ParseImport parses peers to import. Content is either CSV with name,public_key[,address]
+// columns and an optional header, or JSON array of objects with name, public_key and address
+// fields. Rows which can't be parsed are returned as errors, error is only returned if the
+// content can't be parsed at all.
+func ParseImport(content []byte) ([]ImportRow, []ImportError, error) {
+	var rows []ImportRow
+	var problems []ImportError
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing JSON: %w", err)
+		}
+		for i := range rows {
+			rows[i].Row = i + 1
+		}
+	} else {
+		reader := csv.NewReader(bytes.NewReader(content))
+		reader.Comment = '#'
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		for first := true; ; first = false {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("error parsing CSV: %w", err)
+			}
+			line, _ := reader.FieldPos(0)
+			if first && strings.EqualFold(strings.TrimSpace(record[0]), "name") {
+				continue
+			}
+			if len(record) < 2 || len(record) > 3 {
+				problems = append(problems, ImportError{Row: line, Err: errors.New("row should have name,public_key[,address] columns")})
+				continue
+			}
+			// Names in exported files are escaped, so spreadsheets don't take them for formulas
+			row := ImportRow{Row: line, Name: unescapeCSVCell(record[0]), PublicKey: record[1]}
+			if len(record) == 3 {
+				row.Address = record[2]
+			}
+			rows = append(rows, row)
+		}
+	}
+	if len(rows)+len(problems) > MaxImportRows {
+		return nil, nil, fmt.Errorf("there are more than %d rows", MaxImportRows)
+	}
+	for i := range rows {
+		rows[i].Name = strings.TrimSpace(rows[i].Name)
+		rows[i].PublicKey = strings.TrimSpace(rows[i].PublicKey)
+		rows[i].Address = strings.TrimSpace(rows[i].Address)
+	}
+	return rows, problems, nil
+}
+
+// parseImportAddress parses address of an imported peer, with or without a host prefix length.
+func parseImportAddress(s string) (net.IP, error) {
+	if !strings.Contains(s, "/") {
+		addr := net.ParseIP(s)
+		if addr == nil {
+			return nil, fmt.Errorf("address %q is not valid", s)
+		}
+		return addr, nil
+	}
+	addr, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("address %q is not valid: %w", s, err)
+	}
+	if !isHost(network) {
+		return nil, fmt.Errorf("address %q is a network rather than a single address", s)
+	}
+	return addr, nil
+}
+
+// Import validates rows as peers added after the changes already staged in the batch, and
+// stages adding the valid ones. Peers without an address get free ones, after the addresses
+// of other rows are taken. It returns problems of rows which aren't staged.
+func (b *Batch) Import(rows []ImportRow) ([]ImportError, error) {
+	staged := b.Len()
+	problems, err := b.importRows(rows)
+	if err == nil && b.Len() > staged {
+		b.staged()
+	}
+	return problems, err
+}
+
+func (b *Batch) importRows(rows []ImportRow) ([]ImportError, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	change, err := b.c.modify(true, b.edit)
+	if err != nil {
+		return nil, err
+	}
+	cfgFile, err := ini.LoadSources(ini.LoadOptions{AllowNonUniqueSections: true}, change.After)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	config, err := parseConfig(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	network, addrList, err := usedAddresses(config)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := b.c.reservedRanges(*network)
+	if err != nil {
+		return nil, err
+	}
+	allocator, err := newAllocator(addrList, reserved, *network, b.c.Allocation)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, peer := range config.Peer {
+		keys[peer.PublicKey] = true
+	}
+	problems := []ImportError{}
+	addresses := make([]net.IP, len(rows))
+	valid := make([]bool, len(rows))
+	check := func(i int) error {
+		row := rows[i]
+		if row.Name == "" {
+			return errors.New("name is empty")
+		}
+		err := ValidatePeerName(row.Name)
+		if err != nil {
+			return err
+		}
+		_, err = wgtypes.ParseKey(row.PublicKey)
+		if err != nil {
+			return fmt.Errorf("public key %q is not valid: %w", row.PublicKey, err)
+		}
+		if keys[row.PublicKey] {
+			return fmt.Errorf("peer with public key %s already exists", row.PublicKey)
+		}
+		if row.Address == "" {
+			return nil
+		}
+		addr, err := parseImportAddress(row.Address)
+		if err != nil {
+			return err
+		}
+		err = allocator.check(toAddr(addr))
+		if err != nil {
+			return err
+		}
+		for _, r := range reserved {
+			if r.Contains(addr) {
+				return fmt.Errorf("address %s is in reserved range %s", addr, r)
+			}
+		}
+		if !allocator.isFree(toAddr(addr)) {
+			return fmt.Errorf("address %s is already used", addr)
+		}
+		allocator.take(toAddr(addr), toAddr(addr))
+		addresses[i] = addr
+		return nil
+	}
+	for i, row := range rows {
+		err := check(i)
+		if err != nil {
+			problems = append(problems, ImportError{Row: row.Row, Err: err})
+			continue
+		}
+		keys[row.PublicKey] = true
+		valid[i] = true
+	}
+	ops := []*Op{}
+	for i, row := range rows {
+		if !valid[i] {
+			continue
+		}
+		if addresses[i] == nil {
+			next, err := allocator.Allocate()
+			if err != nil {
+				problems = append(problems, ImportError{Row: row.Row, Err: err})
+				continue
+			}
+			// Addresses are kept in 16-byte form, the same way net.ParseIP does
+			result := next.As16()
+			addresses[i] = net.IP(result[:])
+		}
+		ops = append(ops, b.addPeerOp(row.PublicKey, row.Name, addresses[i]))
+	}
+
+	staged := len(b.ops)
+	b.ops = append(b.ops, ops...)
+	_, err = b.c.modify(true, b.edit)
+	if err != nil {
+		b.ops = b.ops[:staged]
+		return nil, err
+	}
+	return problems, nil
+}
@@ -0,0 +1,99 @@
+package wireguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestParseImport(t *testing.T) {
+	rows, problems, err := ParseImport([]byte(`name,public_key,address
+# Comments are skipped
+Alice, ` + validatePeerKey1 + `
+"Bob, laptop",` + validatePeerKey2 + `,192.168.3.10
+Carol
+`))
+	require.NoError(t, err)
+	require.Equal(t, []ImportRow{
+		{Row: 3, Name: "Alice", PublicKey: validatePeerKey1},
+		{Row: 4, Name: "Bob, laptop", PublicKey: validatePeerKey2, Address: "192.168.3.10"},
+	}, rows)
+	require.Len(t, problems, 1)
+	require.Equal(t, 5, problems[0].Row)
+
+	rows, problems, err = ParseImport([]byte(`
+[
+	{"name": "Alice", "public_key": "` + validatePeerKey1 + `"},
+	{"name": " Bob ", "public_key": "` + validatePeerKey2 + `", "address": "192.168.3.10/32"}
+]`))
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	require.Equal(t, []ImportRow{
+		{Row: 1, Name: "Alice", PublicKey: validatePeerKey1},
+		{Row: 2, Name: "Bob", PublicKey: validatePeerKey2, Address: "192.168.3.10/32"},
+	}, rows)
+
+	_, _, err = ParseImport([]byte(`[{"name": "Alice", "key": "` + validatePeerKey1 + `"}]`))
+	require.Error(t, err)
+	_, _, err = ParseImport([]byte("Alice,\"" + validatePeerKey1))
+	require.Error(t, err)
+}
+
+func TestBatchImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	content := testConfig + `
+# Alice
+[Peer]
+PublicKey  = ` + validatePeerKey1 + `
+AllowedIPs = 192.168.3.2/32
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	processManager := &countingProcessManager{}
+	configManager := ConfigManager{ConfigFilePath: path, ProcessManager: processManager, Reserved: []string{"192.168.3.3"}}
+	keys := []string{}
+	for i := 0; i < 3; i++ {
+		privateKey, err := wgtypes.GeneratePrivateKey()
+		require.NoError(t, err)
+		keys = append(keys, privateKey.PublicKey().String())
+	}
+
+	batch := configManager.NewBatch()
+	require.NoError(t, batch.AddPeer(validatePeerKey2, "Bob"))
+	problems, err := batch.Import([]ImportRow{
+		{Row: 1, Name: "Alice again", PublicKey: validatePeerKey1},
+		{Row: 2, Name: "Bob again", PublicKey: validatePeerKey2},
+		{Row: 3, Name: "Carol", PublicKey: keys[0]},
+		{Row: 4, Name: "Carol again", PublicKey: keys[0]},
+		{Row: 5, Name: "", PublicKey: keys[1]},
+		{Row: 6, Name: "Dave", PublicKey: "not a key"},
+		{Row: 7, Name: "Erin", PublicKey: keys[1], Address: "192.168.3.2"},
+		{Row: 8, Name: "Erin", PublicKey: keys[1], Address: "192.168.3.3"},
+		{Row: 9, Name: "Erin", PublicKey: keys[1], Address: "10.0.0.1"},
+		{Row: 10, Name: "Erin", PublicKey: keys[1], Address: "192.168.3.0/28"},
+		{Row: 11, Name: "Erin", PublicKey: keys[1], Address: "192.168.3.5/32"},
+		{Row: 12, Name: "Frank", PublicKey: keys[2], Address: "192.168.3.5"},
+		{Row: 13, Name: "Mallory\n\n# owner: admin", PublicKey: keys[2]},
+	})
+	require.NoError(t, err)
+	rows := []int{}
+	for _, problem := range problems {
+		rows = append(rows, problem.Row)
+	}
+	require.Equal(t, []int{1, 2, 4, 5, 6, 7, 8, 9, 10, 12, 13}, rows)
+	require.ErrorIs(t, problems[len(problems)-1], ErrInvalidPeerName)
+
+	// Addresses of the rows are taken before free ones are allocated
+	ops := batch.Ops()
+	require.Len(t, ops, 3)
+	change, err := batch.Commit()
+	require.NoError(t, err)
+	require.Equal(t, 1, processManager.reloads)
+	require.Contains(t, string(change.After), "# Carol\n[Peer]\nPublicKey  = "+keys[0]+"\nAllowedIPs = 192.168.3.6/32\n")
+	require.Contains(t, string(change.After), "# Erin\n[Peer]\nPublicKey  = "+keys[1]+"\nAllowedIPs = 192.168.3.5/32\n")
+	peers, err := configManager.ListPeers()
+	require.NoError(t, err)
+	require.Len(t, peers, 4)
+}
@@ -0,0 +1,284 @@
+package wireguard
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IPRange is an inclusive range of addresses.
+type IPRange struct {
+	Start net.IP
+	End   net.IP
+}
+
+func (r IPRange) String() string {
+	if r.Start.Equal(r.End) {
+		return r.Start.String()
+	}
+	return r.Start.String() + "-" + r.End.String()
+}
+
+// Contains reports whether the address is in the range.
+func (r IPRange) Contains(addr net.IP) bool {
+	addrInt := ipToInt(addr)
+	return ipToInt(r.Start).Cmp(addrInt) <= 0 && addrInt.Cmp(ipToInt(r.End)) <= 0
+}
+
+// parseRangeAddress parses an address of the network. IPv4 address may be shortened to its
+// last octets with a leading dot, the rest of them is taken from the network address.
+func parseRangeAddress(s string, network net.IPNet) (net.IP, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, ".") {
+		netAddr := network.IP.To4()
+		suffix := strings.Split(s[1:], ".")
+		if netAddr == nil || len(suffix) > 3 {
+			return nil, fmt.Errorf("%q can't be expanded to an address of network %s", s, network.String())
+		}
+		octets := []string{}
+		for _, octet := range netAddr[:net.IPv4len-len(suffix)] {
+			octets = append(octets, strconv.Itoa(int(octet)))
+		}
+		s = strings.Join(append(octets, suffix...), ".")
+	}
+	addr := net.ParseIP(s)
+	if addr == nil {
+		return nil, fmt.Errorf("%q is not a valid address", s)
+	}
+	return addr, nil
+}
+
+// ParseIPRange parses a range of addresses of the network. The range is either a single address,
+// a CIDR, or two addresses separated by "-", e.g. "10.0.0.1-10.0.0.20". IPv4 addresses may be
+// shortened to their last octets, e.g. ".1-.20" is the same range in 10.0.0.0/24.
+func ParseIPRange(s string, network net.IPNet) (IPRange, error) {
+	var r IPRange
+	if strings.Contains(s, "/") {
+		_, cidr, err := net.ParseCIDR(strings.TrimSpace(s))
+		if err != nil {
+			return r, fmt.Errorf("range %q is not valid: %w", s, err)
+		}
+		r.Start = cidr.IP
+		r.End = make(net.IP, len(cidr.IP))
+		for i := range cidr.IP {
+			r.End[i] = cidr.IP[i] | ^cidr.Mask[i]
+		}
+	} else {
+		start, end, isRange := strings.Cut(s, "-")
+		var err error
+		r.Start, err = parseRangeAddress(start, network)
+		if err != nil {
+			return r, fmt.Errorf("range %q is not valid: %w", s, err)
+		}
+		r.End = r.Start
+		if isRange {
+			r.End, err = parseRangeAddress(end, network)
+			if err != nil {
+				return r, fmt.Errorf("range %q is not valid: %w", s, err)
+			}
+		}
+	}
+	if ipToInt(r.Start).Cmp(ipToInt(r.End)) > 0 {
+		return r, fmt.Errorf("range %q ends before it starts", s)
+	}
+	if !network.Contains(r.Start) || !network.Contains(r.End) {
+		return r, fmt.Errorf("range %q doesn't belong to network %s", s, network.String())
+	}
+	return r, nil
+}
+
+// reservedRanges parses Reserved ranges of the network.
+func (c *ConfigManager) reservedRanges(network net.IPNet) ([]IPRange, error) {
+	ranges := make([]IPRange, 0, len(c.Reserved))
+	for _, s := range c.Reserved {
+		r, err := ParseIPRange(s, network)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing reserved range: %w", err)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// usedAddresses returns the server subnet along with addresses of the interface and peers in it.
+// Peer AllowedIPs outside of the subnet, e.g. routed networks, don't take addresses from it.
+func usedAddresses(config *Config) (*net.IPNet, []net.IP, error) {
+	ifaceAddr, network, err := net.ParseCIDR(config.Interface.Address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing interface address: %w", err)
+	}
+
+	addrList := []net.IP{ifaceAddr}
+	for i := range config.Peer {
+		peer := &config.Peer[i]
+		for _, allowedIP := range strings.Split(peer.AllowedIPs, ",") {
+			allowedIP = strings.TrimSpace(allowedIP)
+			if allowedIP == "" {
+				continue
+			}
+			addr, _, err := net.ParseCIDR(allowedIP)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %s has invalid AllowedIPs %q", ErrInconsistentConfig, peerLabel(peer), allowedIP)
+			}
+			if !network.Contains(addr) {
+				continue
+			}
+			if err := validate(addr, *network); err != nil {
+				return nil, nil, fmt.Errorf("%w: AllowedIPs %s of %s can't be used: %v", ErrInconsistentConfig, allowedIP, peerLabel(peer), err)
+			}
+			addrList = append(addrList, addr)
+		}
+	}
+	return network, addrList, nil
+}
+
+// checkFree returns an error if the address chosen earlier was taken since, e.g. by a peer added in the meantime.
+func checkFree(config *Config, addr net.IP) error {
+	_, addrList, err := usedAddresses(config)
+	if err != nil {
+		return err
+	}
+	for _, used := range addrList {
+		if used.Equal(addr) {
+			return fmt.Errorf("address %s is already used", addr)
+		}
+	}
+	return nil
+}
+
+func (c *ConfigManager) calculateNextIP(config *Config) (net.IP, error) {
+	network, addrList, err := usedAddresses(config)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := c.reservedRanges(*network)
+	if err != nil {
+		return nil, err
+	}
+
+	nextIP, err := getNextIPAddress(addrList, reserved, *network, c.Allocation)
+	if err != nil {
+		return nil, fmt.Errorf("error getting next ip address: %w", err)
+	}
+
+	return nextIP, nil
+}
+
+// AddressPool describes how addresses of the server subnet are used.
+type AddressPool struct {
+	Network net.IPNet
+	// Addresses which can be given to peers or the interface, without network and broadcast ones
+	Size *big.Int
+	// Number of distinct addresses taken by the interface and peers
+	Used int
+	// Addresses in reserved ranges which aren't used
+	Reserved *big.Int
+	// Addresses which can be given to new peers
+	Free *big.Int
+	// Reserved ranges as configured
+	Ranges []IPRange
+	// Strategy new peers get their addresses with
+	Strategy Strategy
+	// Address the next added peer gets, nil if there are no free addresses. It is
+	// only an example of a free address if addresses are chosen randomly.
+	Next net.IP
+}
+
+// AddressPool returns usage of the server subnet.
+func (c *ConfigManager) AddressPool() (*AddressPool, error) {
+	_, config, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	network, addrList, err := usedAddresses(config)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := c.reservedRanges(*network)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &AddressPool{Network: *network, Ranges: ranges, Strategy: c.Allocation}
+
+	// Network and broadcast addresses can't be used
+	first := ipToInt(network.IP)
+	first.Add(first, big.NewInt(1))
+	ones, bits := network.Mask.Size()
+	last := big.NewInt(0).Lsh(big.NewInt(1), uint(bits-ones))
+	last.Add(last, ipToInt(network.IP))
+	last.Sub(last, big.NewInt(2))
+	pool.Size = big.NewInt(0)
+	if last.Cmp(first) >= 0 {
+		pool.Size.Sub(last, first).Add(pool.Size, big.NewInt(1))
+	}
+
+	// Reserved ranges may overlap or include network and broadcast addresses,
+	// so they are merged and clipped before addresses are counted
+	pool.Reserved = big.NewInt(0)
+	merged := []IPRange{}
+	for _, r := range mergeRanges(ranges) {
+		start, end := ipToInt(r.Start), ipToInt(r.End)
+		if start.Cmp(first) < 0 {
+			start = first
+		}
+		if end.Cmp(last) > 0 {
+			end = last
+		}
+		if start.Cmp(end) > 0 {
+			continue
+		}
+		merged = append(merged, IPRange{Start: intToIp(start), End: intToIp(end)})
+		size := big.NewInt(1)
+		pool.Reserved.Add(pool.Reserved, size.Add(size, big.NewInt(0).Sub(end, start)))
+	}
+	used := map[string]bool{}
+	for _, addr := range addrList {
+		if used[addr.String()] || validate(addr, *network) != nil {
+			continue
+		}
+		used[addr.String()] = true
+		pool.Used++
+		for _, r := range merged {
+			if r.Contains(addr) {
+				pool.Reserved.Sub(pool.Reserved, big.NewInt(1))
+				break
+			}
+		}
+	}
+
+	pool.Free = big.NewInt(0).Sub(pool.Size, big.NewInt(int64(pool.Used)))
+	pool.Free.Sub(pool.Free, pool.Reserved)
+
+	next, err := getNextIPAddress(addrList, ranges, *network, c.Allocation)
+	if err == nil {
+		pool.Next = next
+	}
+	return pool, nil
+}
+
+// mergeRanges returns sorted ranges which cover the same addresses and don't overlap.
+func mergeRanges(ranges []IPRange) []IPRange {
+	sorted := append([]IPRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return ipToInt(sorted[i].Start).Cmp(ipToInt(sorted[j].Start)) < 0
+	})
+	merged := []IPRange{}
+	for _, r := range sorted {
+		if len(merged) > 0 {
+			prev := &merged[len(merged)-1]
+			prevEnd := ipToInt(prev.End)
+			if ipToInt(r.Start).Cmp(prevEnd.Add(prevEnd, big.NewInt(1))) <= 0 {
+				if ipToInt(r.End).Cmp(ipToInt(prev.End)) > 0 {
+					prev.End = r.End
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
@@ -0,0 +1,87 @@
+package wireguard
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPRange(t *testing.T) {
+	_, network, _ := net.ParseCIDR("192.168.1.0/24")
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"192.168.1.1-192.168.1.20", "192.168.1.1-192.168.1.20"},
+		{".1-.20", "192.168.1.1-192.168.1.20"},
+		{" .100 - .200 ", "192.168.1.100-192.168.1.200"},
+		{"192.168.1.5", "192.168.1.5"},
+		{"192.168.1.64/26", "192.168.1.64-192.168.1.127"},
+	}
+	for _, test := range tests {
+		r, err := ParseIPRange(test.s, *network)
+		require.NoError(t, err, test.s)
+		require.Equal(t, test.expected, r.String())
+	}
+
+	for _, s := range []string{".20-.1", "192.168.2.1", ".1-.300", "192.168.0.0/16", "", ".1.1.1.1"} {
+		_, err := ParseIPRange(s, *network)
+		require.Error(t, err, s)
+	}
+
+	_, network6, _ := net.ParseCIDR("fd00::/64")
+	r, err := ParseIPRange("fd00::1-fd00::ff", *network6)
+	require.NoError(t, err)
+	require.True(t, r.Contains(net.ParseIP("fd00::10")))
+	_, err = ParseIPRange(".1-.20", *network6)
+	require.Error(t, err)
+}
+
+func TestAddressPool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.10/32, 10.10.0.0/24
+
+[Peer]
+PublicKey  = `+validatePeerKey2+`
+AllowedIPs = 192.168.3.21/32
+`), 0600))
+	configManager := ConfigManager{
+		ConfigFilePath: path,
+		Reserved:       []string{".1-.20", ".15-.30", ".255"},
+	}
+
+	pool, err := configManager.AddressPool()
+	require.NoError(t, err)
+	require.Equal(t, "192.168.3.0/24", pool.Network.String())
+	require.Equal(t, "254", pool.Size.String())
+	require.Equal(t, 3, pool.Used)
+	// .1-.30 without .1, .10 and .21 which are used, broadcast address isn't counted
+	require.Equal(t, "27", pool.Reserved.String())
+	require.Equal(t, "224", pool.Free.String())
+	require.Len(t, pool.Ranges, 3)
+	require.Equal(t, "192.168.3.31", pool.Next.String())
+
+	configManager.Reserved = []string{"192.168.3.0/24"}
+	pool, err = configManager.AddressPool()
+	require.NoError(t, err)
+	require.Equal(t, "0", pool.Free.String())
+	require.Nil(t, pool.Next)
+
+	configManager.Reserved = []string{"10.0.0.1"}
+	_, err = configManager.AddressPool()
+	require.Error(t, err)
+
+	// Sequential allocation continues after the address of the latest added peer
+	configManager.Reserved = nil
+	configManager.Allocation = Sequential
+	pool, err = configManager.AddressPool()
+	require.NoError(t, err)
+	require.Equal(t, "192.168.3.22", pool.Next.String())
+	require.Equal(t, Sequential, pool.Strategy)
+}
@@ -4,12 +4,29 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ProcessManagerInterface interface {
 	ReloadConfig() error
 }
 
+// PeerStats is runtime state of a peer reported by Wireguard.
+type PeerStats struct {
+	// Zero if there was no handshake since the interface was brought up
+	LastHandshakeTime time.Time
+	ReceiveBytes      int64
+	TransmitBytes     int64
+}
+
+// PeerStatsReader is implemented by process managers which can report runtime state of peers.
+type PeerStatsReader interface {
+	// PeerStats returns stats of peers known to Wireguard, by public key.
+	PeerStats() (map[string]PeerStats, error)
+}
+
 type ProcessManagerStub struct{}
 
 func (pm *ProcessManagerStub) ReloadConfig() error {
@@ -30,3 +47,46 @@ func (pm *ProcessManager) ReloadConfig() error {
 	}
 	return nil
 }
+
+func (pm *ProcessManager) PeerStats() (map[string]PeerStats, error) {
+	out, err := exec.Command("wg", "show", pm.InterfaceName, "dump").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading peer stats: %w", err)
+	}
+	return parseDump(out)
+}
+
+// parseDump parses output of "wg show <interface> dump". The first line describes the interface,
+// every following one is a peer: public key, preshared key, endpoint, allowed IPs, latest handshake,
+// received and sent bytes and persistent keepalive, separated by tabs.
+func parseDump(out []byte) (map[string]PeerStats, error) {
+	stats := map[string]PeerStats{}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	for i, line := range lines {
+		if i == 0 {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 8 {
+			return nil, fmt.Errorf("unexpected peer stats on line %d: %q", i+1, line)
+		}
+		handshake, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing latest handshake on line %d: %w", i+1, err)
+		}
+		received, err := strconv.ParseInt(fields[5], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing received bytes on line %d: %w", i+1, err)
+		}
+		sent, err := strconv.ParseInt(fields[6], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing sent bytes on line %d: %w", i+1, err)
+		}
+		peer := PeerStats{ReceiveBytes: received, TransmitBytes: sent}
+		if handshake != 0 {
+			peer.LastHandshakeTime = time.Unix(handshake, 0)
+		}
+		stats[fields[0]] = peer
+	}
+	return stats, nil
+}
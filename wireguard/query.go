@@ -0,0 +1,81 @@
+package wireguard
+
+import (
+	"net"
+	"strings"
+)
+
+// parsePeerComment parses comment preceding peer section. First line of the comment
+// is peer name, following lines in the form of "key: value" are peer metadata.
+func parsePeerComment(comment string) (string, map[string]string) {
+	var name string
+	var metadata map[string]string
+	for i, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "#;"))
+		if i == 0 {
+			name = line
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		metadata[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return name, metadata
+}
+
+// Matches checks if peer matches the query. Query matches a peer if it is
+// a case-insensitive substring of its name or metadata value, a prefix of its
+// public key, or an IP address which belongs to its AllowedIPs.
+func (p *Peer) Matches(query string) bool {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return true
+	}
+	if strings.HasPrefix(p.PublicKey, query) {
+		return true
+	}
+	lowerQuery := strings.ToLower(query)
+	if strings.Contains(strings.ToLower(p.Name), lowerQuery) {
+		return true
+	}
+	for key, value := range p.Metadata {
+		if strings.Contains(strings.ToLower(value), lowerQuery) || strings.EqualFold(key, query) {
+			return true
+		}
+	}
+	addr := net.ParseIP(query)
+	for _, allowedIP := range strings.Split(p.AllowedIPs, ",") {
+		allowedIP = strings.TrimSpace(allowedIP)
+		if addr == nil {
+			if strings.HasPrefix(allowedIP, query) {
+				return true
+			}
+			continue
+		}
+		_, network, err := net.ParseCIDR(allowedIP)
+		if err == nil && network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindPeers returns all peers matching the query, see Peer.Matches.
+func (c *ConfigManager) FindPeers(query string) ([]Peer, error) {
+	peers, err := c.ListPeers()
+	if err != nil {
+		return nil, err
+	}
+	result := []Peer{}
+	for _, peer := range peers {
+		if peer.Matches(query) {
+			result = append(result, peer)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,76 @@
+package wireguard
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testQueryConfig = `[Interface]
+Address    = 192.168.3.1/24
+ListenPort = 11111
+PrivateKey = sLsJoF6gLXYWfRcpRkA7ugzvkYX15Lpvif5oBeZeaHA=
+
+# Bob laptop
+# owner: bob@example.com
+# department: Sales
+[Peer]
+PublicKey  = V5CyX8fiyVYu9R4qZelHaJl915y6jsUDwlbT/abgOVY=
+AllowedIPs = 192.168.3.2/32
+
+# Alice phone
+[Peer]
+PublicKey  = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+AllowedIPs = 192.168.3.3/32
+`
+
+func TestParsePeerComment(t *testing.T) {
+	name, metadata := parsePeerComment("# Bob laptop\n# owner: bob@example.com\n# just a note")
+	require.Equal(t, "Bob laptop", name)
+	require.Equal(t, map[string]string{"owner": "bob@example.com"}, metadata)
+
+	name, metadata = parsePeerComment("")
+	require.Equal(t, "", name)
+	require.Nil(t, metadata)
+}
+
+func TestFindPeers(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "test-config")
+	require.NoError(t, err)
+	_, err = file.WriteString(testQueryConfig)
+	require.NoError(t, err)
+	file.Close()
+
+	configManager := ConfigManager{
+		ConfigFilePath: file.Name(),
+		ProcessManager: &ProcessManagerStub{},
+	}
+
+	cases := []struct {
+		name    string
+		query   string
+		matches []string
+	}{
+		{"empty query", "", []string{"Bob laptop", "Alice phone"}},
+		{"name substring", "LAPTOP", []string{"Bob laptop"}},
+		{"public key prefix", "xTIBA5", []string{"Alice phone"}},
+		{"public key substring", "IBA5", []string{}},
+		{"ip address", "192.168.3.3", []string{"Alice phone"}},
+		{"ip address prefix", "192.168.3.", []string{"Bob laptop", "Alice phone"}},
+		{"metadata value", "sales", []string{"Bob laptop"}},
+		{"metadata key", "owner", []string{"Bob laptop"}},
+		{"no match", "Carol", []string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			peers, err := configManager.FindPeers(c.query)
+			require.NoError(t, err)
+			names := []string{}
+			for _, peer := range peers {
+				names = append(names, peer.Name)
+			}
+			require.Equal(t, c.matches, names)
+		})
+	}
+}
@@ -1,11 +1,10 @@
 package wireguard
 
 import (
-	"bytes"
 	"fmt"
 	"math/big"
 	"net"
-	"sort"
+	"net/netip"
 )
 
 func ipToInt(ipAddr net.IP) *big.Int {
@@ -42,60 +41,75 @@ func isBroadcast(addr net.IP, network net.IPNet) bool {
 
 func validate(addr net.IP, network net.IPNet) error {
 	if !network.Contains(addr) {
-		return fmt.Errorf("address %s doesn't belong to network %s", addr, network)
+		return fmt.Errorf("address %s doesn't belong to network %s", addr, network.String())
 	}
 	if network.IP.Equal(addr) {
-		return fmt.Errorf("address %s is a network address for network %s", addr, network)
+		return fmt.Errorf("address %s is a network address for network %s", addr, network.String())
 	}
 	if isBroadcast(addr, network) {
-		return fmt.Errorf("address %s is a broadcast address for network %s", addr, network)
+		return fmt.Errorf("address %s is a broadcast address for network %s", addr, network.String())
 	}
 	return nil
 }
 
-func getNextIPAddress(addrList []net.IP, network net.IPNet) (net.IP, error) {
-	// Validate all addresses
-	for _, addr := range addrList {
-		err := validate(addr, network)
+// toPrefix converts network to netip.Prefix, IPv4 networks are unmapped.
+func toPrefix(network net.IPNet) netip.Prefix {
+	addr, _ := netip.AddrFromSlice(network.IP)
+	ones, _ := network.Mask.Size()
+	return netip.PrefixFrom(addr.Unmap(), ones)
+}
+
+func toAddr(ip net.IP) netip.Addr {
+	addr, _ := netip.AddrFromSlice(ip)
+	return addr.Unmap()
+}
+
+// hostAddress returns AllowedIPs entry of the single address.
+func hostAddress(addr net.IP) string {
+	if addr.To4() != nil {
+		return addr.String() + "/32"
+	}
+	return addr.String() + "/128"
+}
+
+// newAllocator returns allocator of the network which has addresses of addrList and reserved ranges taken.
+// Sequential strategy continues after the last address of addrList.
+func newAllocator(addrList []net.IP, reserved []IPRange, network net.IPNet, strategy Strategy) (*Allocator, error) {
+	allocator := NewAllocator(toPrefix(network), strategy)
+	addrs := make([]netip.Addr, len(addrList))
+	for i, addr := range addrList {
+		addrs[i] = toAddr(addr)
+	}
+	err := allocator.Use(addrs...)
+	if err != nil {
+		return nil, fmt.Errorf("invlid address list: %w", err)
+	}
+	for _, r := range reserved {
+		err = allocator.Reserve(toAddr(r.Start), toAddr(r.End))
 		if err != nil {
-			return nil, fmt.Errorf("invlid address list: %w", err)
+			return nil, fmt.Errorf("invalid reserved range: %w", err)
 		}
 	}
+	if len(addrs) > 0 {
+		allocator.Seek(addrs[len(addrs)-1])
+	}
+	return allocator, nil
+}
 
-	// Sort addresses in ascending order
-	sort.Slice(addrList, func(i, j int) bool {
-		return bytes.Compare(addrList[i], addrList[j]) < 0
-	})
-
-	// Add network address to address list
-	completeAddrList := make([]net.IP, len(addrList)+1)
-	completeAddrList[0] = network.IP
-	copy(completeAddrList[1:], addrList)
+// getNextIPAddress returns an address of the network which is neither in addrList nor in one
+// of reserved ranges, chosen according to the strategy. Sequential strategy continues after
+// the last address of addrList.
+func getNextIPAddress(addrList []net.IP, reserved []IPRange, network net.IPNet, strategy Strategy) (net.IP, error) {
+	allocator, err := newAllocator(addrList, reserved, network, strategy)
+	if err != nil {
+		return nil, err
+	}
 
-	// Iterate over resulting list and try finding a gaps in it
-	var intPrev big.Int
-	for i, addr := range completeAddrList {
-		addrInt := ipToInt(addr)
-		if i >= 1 {
-			diff := big.NewInt(0)
-			diff.Sub(addrInt, &intPrev)
-			if diff.Cmp(big.NewInt(1)) == 1 {
-				intNext := big.NewInt(0)
-				intNext.Add(&intPrev, big.NewInt(1))
-				return intToIp(intNext), nil
-			}
-		}
-		if i == len(completeAddrList)-1 {
-			intNext := big.NewInt(0)
-			intNext.Add(addrInt, big.NewInt(1))
-			result := intToIp(intNext)
-			err := validate(result, network)
-			if err != nil {
-				return nil, fmt.Errorf("can't get next address: %w", err)
-			}
-			return result, nil
-		}
-		intPrev = *addrInt
+	next, err := allocator.Allocate()
+	if err != nil {
+		return nil, fmt.Errorf("can't get next address: %w", err)
 	}
-	return nil, fmt.Errorf("this error shouldn't occur")
+	// Addresses are returned in 16-byte form, the same way net.ParseIP does
+	result := next.As16()
+	return net.IP(result[:]), nil
 }
@@ -53,7 +53,7 @@ func TestNextIP(t *testing.T) {
 	t.Run("empty list", func(t *testing.T) {
 		_, network, _ := net.ParseCIDR("192.168.1.0/24")
 		addrList := []net.IP{}
-		nextAddr, _ := getNextIPAddress(addrList, *network)
+		nextAddr, _ := getNextIPAddress(addrList, nil, *network, LowestFree)
 		require.Equal(t, nextAddr, net.ParseIP("192.168.1.1"))
 	})
 
@@ -62,7 +62,7 @@ func TestNextIP(t *testing.T) {
 		addrList := []net.IP{
 			net.ParseIP("192.168.1.1"),
 		}
-		nextAddr, _ := getNextIPAddress(addrList, *network)
+		nextAddr, _ := getNextIPAddress(addrList, nil, *network, LowestFree)
 		require.Equal(t, nextAddr, net.ParseIP("192.168.1.2"))
 	})
 
@@ -71,7 +71,7 @@ func TestNextIP(t *testing.T) {
 		addrList := []net.IP{
 			net.ParseIP("192.168.1.2"),
 		}
-		nextAddr, _ := getNextIPAddress(addrList, *network)
+		nextAddr, _ := getNextIPAddress(addrList, nil, *network, LowestFree)
 		require.Equal(t, nextAddr, net.ParseIP("192.168.1.1"))
 	})
 
@@ -81,14 +81,14 @@ func TestNextIP(t *testing.T) {
 			net.ParseIP("192.168.1.1"),
 			net.ParseIP("192.168.1.5"),
 		}
-		nextAddr, _ := getNextIPAddress(addrList, *network)
+		nextAddr, _ := getNextIPAddress(addrList, nil, *network, LowestFree)
 		require.Equal(t, nextAddr, net.ParseIP("192.168.1.2"))
 	})
 
 	t.Run("overflow with /31 network", func(t *testing.T) {
 		_, network, _ := net.ParseCIDR("192.168.1.0/31")
 		addrList := []net.IP{}
-		_, err := getNextIPAddress(addrList, *network)
+		_, err := getNextIPAddress(addrList, nil, *network, LowestFree)
 		require.Error(t, err)
 	})
 
@@ -98,7 +98,7 @@ func TestNextIP(t *testing.T) {
 			net.ParseIP("192.168.1.1"),
 			net.ParseIP("192.168.1.2"),
 		}
-		_, err := getNextIPAddress(addrList, *network)
+		_, err := getNextIPAddress(addrList, nil, *network, LowestFree)
 		require.Error(t, err)
 	})
 
@@ -107,8 +107,33 @@ func TestNextIP(t *testing.T) {
 		addrList := []net.IP{
 			net.ParseIP("192.168.1.1"),
 		}
-		nextAddr, _ := getNextIPAddress(addrList, *network)
+		nextAddr, _ := getNextIPAddress(addrList, nil, *network, LowestFree)
 		require.Equal(t, nextAddr, net.ParseIP("192.168.1.2"))
 	})
 
+	t.Run("reserved ranges are skipped", func(t *testing.T) {
+		_, network, _ := net.ParseCIDR("192.168.1.0/24")
+		addrList := []net.IP{
+			net.ParseIP("192.168.1.1"),
+			net.ParseIP("192.168.1.25"),
+		}
+		reserved := []IPRange{
+			{Start: net.ParseIP("192.168.1.2"), End: net.ParseIP("192.168.1.20")},
+			{Start: net.ParseIP("192.168.1.10"), End: net.ParseIP("192.168.1.24")},
+		}
+		nextAddr, _ := getNextIPAddress(addrList, reserved, *network, LowestFree)
+		require.Equal(t, nextAddr, net.ParseIP("192.168.1.26"))
+	})
+
+	t.Run("overflow with reserved range", func(t *testing.T) {
+		_, network, _ := net.ParseCIDR("192.168.1.0/30")
+		addrList := []net.IP{
+			net.ParseIP("192.168.1.1"),
+		}
+		reserved := []IPRange{
+			{Start: net.ParseIP("192.168.1.2"), End: net.ParseIP("192.168.1.2")},
+		}
+		_, err := getNextIPAddress(addrList, reserved, *network, LowestFree)
+		require.Error(t, err)
+	})
 }
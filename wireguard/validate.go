@@ -0,0 +1,68 @@
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"unicode"
+)
+
+// ErrInvalidPeerName is returned for peer names which can't be kept in the comment of the peer section.
+var ErrInvalidPeerName = errors.New("peer name should be a single line without control characters")
+
+// ValidatePeerName checks that the name could be written as the comment of the peer section. A line break
+// would make the rest of the name look like metadata, and a blank line breaks writing the configuration.
+func ValidatePeerName(name string) error {
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return ErrInvalidPeerName
+		}
+	}
+	return nil
+}
+
+// peerLabel returns human readable name of the peer for error messages.
+func peerLabel(peer *Peer) string {
+	if peer.Name == "" {
+		return "peer " + peer.PublicKey
+	}
+	return fmt.Sprintf("peer %q", peer.Name)
+}
+
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// Validate checks that server configuration file is safe to work with: it is readable only
+// by its owner, and Check finds no problems with the interface or reserved ranges. Problems of
+// peers found by Check are returned as warnings, as the bot works with them and /doctor fixes
+// them. It returns all problems and warnings found.
+func (c *ConfigManager) Validate() ([]error, []error) {
+	info, err := os.Stat(c.ConfigFilePath)
+	if err != nil {
+		return []error{fmt.Errorf("can't access configuration file: %w", err)}, nil
+	}
+	if info.IsDir() {
+		return []error{fmt.Errorf("%s is a directory", c.ConfigFilePath)}, nil
+	}
+
+	problems := []error{}
+	if info.Mode().Perm()&0077 != 0 {
+		problems = append(problems, fmt.Errorf("configuration file is accessible by other users (mode %04o), it should be 0600", info.Mode().Perm()))
+	}
+
+	checked, err := c.Check()
+	if err != nil {
+		return append(problems, err), nil
+	}
+	warnings := []error{}
+	for _, problem := range checked {
+		if problem.Peer >= 0 {
+			warnings = append(warnings, problem)
+		} else {
+			problems = append(problems, problem)
+		}
+	}
+	return problems, warnings
+}
@@ -0,0 +1,113 @@
+package wireguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	validatePeerKey1 = "Dc6HJYJHhm//iEeQDnXDPPtQ1u9slnkDaflP0ar4ISE="
+	validatePeerKey2 = "Dq7pWRg3Us+s8KxsWbRCdSEePGda1bPDqsoEvygyjhk="
+	validatePeerKey3 = "BwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHyAhIiMkJSY="
+)
+
+func validateConfig(t *testing.T, content string, perm os.FileMode) ([]error, []error) {
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(path, []byte(content), perm))
+	require.NoError(t, os.Chmod(path, perm))
+	configManager := ConfigManager{ConfigFilePath: path}
+	return configManager.Validate()
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("valid config", func(t *testing.T) {
+		problems, warnings := validateConfig(t, testConfig+`
+# Alice
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.2/32
+
+# Bob
+[Peer]
+PublicKey  = `+validatePeerKey2+`
+AllowedIPs = 192.168.3.3/32, 10.10.0.0/24
+`, 0600)
+		require.Empty(t, problems)
+		require.Empty(t, warnings)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		configManager := ConfigManager{ConfigFilePath: filepath.Join(t.TempDir(), "wg0.conf")}
+		problems, _ := configManager.Validate()
+		require.Len(t, problems, 1)
+		require.ErrorIs(t, problems[0], os.ErrNotExist)
+	})
+
+	t.Run("unsafe permissions", func(t *testing.T) {
+		problems, _ := validateConfig(t, testConfig, 0644)
+		require.Len(t, problems, 1)
+		require.Contains(t, problems[0].Error(), "mode 0644")
+	})
+
+	t.Run("invalid interface", func(t *testing.T) {
+		problems, _ := validateConfig(t, `[Interface]
+Address    = 192.168.3.1
+PrivateKey = xxx
+`, 0600)
+		require.Len(t, problems, 2)
+		require.Contains(t, problems[0].Error(), "interface address")
+		require.Contains(t, problems[1].Error(), "private key")
+	})
+
+	t.Run("invalid and duplicate peers", func(t *testing.T) {
+		// Problems of peers don't keep the bot from working, so /doctor could fix them
+		problems, warnings := validateConfig(t, testConfig+`
+# Alice
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.0/30
+
+# Bob
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.2/32, invalid
+
+# Carol
+[Peer]
+PublicKey  = yyy
+AllowedIPs = 10.0.0.2/32
+`, 0600)
+		require.Empty(t, problems)
+		messages := []string{}
+		for _, warning := range warnings {
+			messages = append(messages, warning.Error())
+		}
+		require.Len(t, messages, 7)
+		require.Contains(t, messages[0], `AllowedIPs 192.168.3.0/30 of peer "Alice" include interface address`)
+		require.Equal(t, `AllowedIPs 192.168.3.0/30 of peer "Alice" can't be used: address 192.168.3.0 is a network address for network 192.168.3.0/24`, messages[1])
+		require.Equal(t, `peer "Bob" has the same public key as peer "Alice"`, messages[2])
+		require.Equal(t, `AllowedIPs 192.168.3.2/32 of peer "Bob" overlap with 192.168.3.0/30 of peer "Alice"`, messages[3])
+		require.Contains(t, messages[4], `peer "Bob" has invalid AllowedIPs "invalid"`)
+		require.Contains(t, messages[5], `peer "Carol" has invalid public key`)
+		require.Equal(t, `peer "Carol" has no address in interface subnet 192.168.3.0/24`, messages[6])
+	})
+
+	t.Run("reserved ranges", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "wg0.conf")
+		require.NoError(t, os.WriteFile(path, []byte(testConfig+`
+# Alice
+[Peer]
+PublicKey  = `+validatePeerKey1+`
+AllowedIPs = 192.168.3.5/32, 10.10.0.0/24
+`), 0600))
+		configManager := ConfigManager{ConfigFilePath: path, Reserved: []string{".2-.10", "10.0.0.1"}}
+		problems, warnings := configManager.Validate()
+		require.Len(t, problems, 1)
+		require.Contains(t, problems[0].Error(), `reserved range "10.0.0.1" doesn't belong to network 192.168.3.0/24`)
+		require.Len(t, warnings, 1)
+		require.Equal(t, `AllowedIPs 192.168.3.5/32 of peer "Alice" are in reserved range 192.168.3.2-192.168.3.10`, warnings[0].Error())
+	})
+}